@@ -0,0 +1,12 @@
+// Package assets carries the stock template and fonts inside the binary,
+// so certgen runs without them on disk. cmd/certgen registers FS as
+// "builtin", so TEMPLATE_IMAGE can be e.g.
+// builtin:templates/Certificate-in-Comparative-Religious-Studies.png.
+package assets
+
+import "embed"
+
+// FS holds the templates and the Helvetica TrueType faces.
+//
+//go:embed templates/*.png fonts/helvetica-255/*.ttf
+var FS embed.FS
@@ -0,0 +1,246 @@
+// Package certtest pins certificate layouts with golden images. It renders
+// a certificate deterministically, rasterized through the same layout code
+// as the PDF, and compares it against a PNG stored beside the test:
+//
+//	func TestLayout(t *testing.T) {
+//		certtest.Golden(t, "testdata/long-name.png", certtest.Options{
+//			EnvFile: "../.env",
+//		}, certtest.Record{Name: "Maximiliana Konstantinopoulou", RegNumber: "R-1"})
+//	}
+//
+// Run the tests with CERTTEST_UPDATE=1 to write the golden images after an
+// intended layout change, and review the new images before committing them.
+// A failed comparison leaves the rendered image and a diff beside the golden
+// one, as NAME.got.png and NAME.diff.png.
+//
+// Only the configuration in Options is used, never the environment, so the
+// images do not depend on the machine the tests run on.
+package certtest
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// UpdateEnv names the environment variable that makes Golden write the
+// golden images instead of comparing against them.
+const UpdateEnv = "CERTTEST_UPDATE"
+
+// Epoch is when records without an IssuedAt are issued, so their dates and
+// expiry lines do not change from run to run.
+var Epoch = time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+// Defaults for Options.
+const (
+	DefaultDPI       = 50
+	DefaultTolerance = 8
+	DefaultThreshold = 0.001
+)
+
+// Record is one recipient, as in a batch CSV.
+type Record struct {
+	Name      string
+	RegNumber string
+	Course    string
+	IssuedAt  time.Time // zero means Epoch
+	ExpiresAt time.Time // zero means VALIDITY_MONTHS after issue, or never
+	Fields    map[string]string
+}
+
+// Options configures rendering and comparison.
+type Options struct {
+	// EnvFile is a .env file with the configuration to render with. Paths
+	// in it are relative to the test's working directory.
+	EnvFile string
+	// Settings override the EnvFile, by setting name.
+	Settings map[string]string
+	// DPI is the raster resolution. Low resolutions keep golden images
+	// small and compare faster; DefaultDPI if zero.
+	DPI float64
+	// Tolerance is how far a colour channel may drift, out of 255, before a
+	// pixel counts as different; DefaultTolerance if zero.
+	Tolerance uint8
+	// Threshold is the fraction of pixels that may differ before the
+	// comparison fails; DefaultThreshold if zero.
+	Threshold float64
+}
+
+func (o Options) dpi() float64 {
+	if o.DPI > 0 {
+		return o.DPI
+	}
+	return DefaultDPI
+}
+
+func (o Options) tolerance() uint8 {
+	if o.Tolerance > 0 {
+		return o.Tolerance
+	}
+	return DefaultTolerance
+}
+
+func (o Options) threshold() float64 {
+	if o.Threshold > 0 {
+		return o.Threshold
+	}
+	return DefaultThreshold
+}
+
+// Render rasterizes rec's certificate with the configuration in opts.
+func Render(opts Options, rec Record) (image.Image, error) {
+	var vals map[string]string
+	if opts.EnvFile != "" {
+		var err error
+		if vals, err = godotenv.Read(opts.EnvFile); err != nil {
+			return nil, fmt.Errorf("certtest: loading %s: %w", opts.EnvFile, err)
+		}
+	}
+	cfg, err := certificate.LoadConfig(certificate.Layered(
+		certificate.MapSource(opts.Settings),
+		certificate.MapSource(vals),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("certtest: invalid configuration:\n%w", err)
+	}
+	gen, err := certificate.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("certtest: %w", err)
+	}
+	if rec.IssuedAt.IsZero() {
+		rec.IssuedAt = Epoch
+	}
+	return gen.RenderImage(certificate.Record(rec), opts.dpi())
+}
+
+// Diff is the outcome of comparing two images.
+type Diff struct {
+	Differing int         // pixels beyond the tolerance
+	Total     int         // pixels compared
+	Image     *image.RGBA // the wanted image faded, with differing pixels in red
+}
+
+// Fraction is the share of pixels that differ.
+func (d Diff) Fraction() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Differing) / float64(d.Total)
+}
+
+// Compare compares got with want pixel by pixel. A pixel differs when any
+// channel is more than tolerance apart. Images of different sizes cannot be
+// compared.
+func Compare(got, want image.Image, tolerance uint8) (Diff, error) {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Size() != wb.Size() {
+		return Diff{}, fmt.Errorf("image is %dx%d, golden is %dx%d", gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy())
+	}
+	d := Diff{Total: wb.Dx() * wb.Dy(), Image: image.NewRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy()))}
+	for y := range wb.Dy() {
+		for x := range wb.Dx() {
+			g := color.NRGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y)).(color.NRGBA)
+			w := color.NRGBAModel.Convert(want.At(wb.Min.X+x, wb.Min.Y+y)).(color.NRGBA)
+			if far(g.R, w.R, tolerance) || far(g.G, w.G, tolerance) || far(g.B, w.B, tolerance) || far(g.A, w.A, tolerance) {
+				d.Differing++
+				d.Image.Set(x, y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			// Faded to a quarter, so the red stands out against the layout
+			grey := uint8(191 + (uint16(w.R)+uint16(w.G)+uint16(w.B))/12)
+			d.Image.Set(x, y, color.RGBA{R: grey, G: grey, B: grey, A: 255})
+		}
+	}
+	return d, nil
+}
+
+func far(a, b, tolerance uint8) bool {
+	if a < b {
+		a, b = b, a
+	}
+	return a-b > tolerance
+}
+
+// Golden renders rec and compares it against the golden PNG at path,
+// failing t if more than opts.Threshold of the pixels differ. With
+// CERTTEST_UPDATE set, it writes the golden image instead.
+func Golden(t testing.TB, path string, opts Options, rec Record) {
+	t.Helper()
+	got, err := Render(opts, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(UpdateEnv) != "" {
+		if err := writePNG(path, got); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("certtest: wrote %s", path)
+		return
+	}
+
+	want, err := readPNG(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("certtest: no golden image %s; run with %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	d, err := Compare(got, want, opts.tolerance())
+	if err != nil {
+		t.Errorf("certtest: %s: %v", path, err)
+		if err := writePNG(base+".got.png", got); err != nil {
+			t.Error(err)
+		}
+		return
+	}
+	if d.Fraction() <= opts.threshold() {
+		// Leftovers from an earlier failure would mislead
+		os.Remove(base + ".got.png")
+		os.Remove(base + ".diff.png")
+		return
+	}
+	t.Errorf("certtest: %s: %d of %d pixels differ (%.3f%%, threshold %.3f%%); see %s.got.png and %s.diff.png",
+		path, d.Differing, d.Total, 100*d.Fraction(), 100*opts.threshold(), base, base)
+	for name, img := range map[string]image.Image{".got.png": got, ".diff.png": d.Image} {
+		if err := writePNG(base+name, img); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("certtest: %s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(png.Encode(f, img), f.Close())
+}
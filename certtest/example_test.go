@@ -0,0 +1,21 @@
+package certtest_test
+
+import (
+	"testing"
+
+	"github.com/Sathimantha/certificate_generator_go/certtest"
+)
+
+// The repository's own template, pinned as a downstream layout would be.
+// Run with CERTTEST_UPDATE=1 to write the golden images again.
+func TestGoldenLayout(t *testing.T) {
+	opts := certtest.Options{Settings: map[string]string{
+		"TEMPLATE_IMAGE": "../assets/templates/Certificate-in-Comparative-Religious-Studies.png",
+	}}
+	rec := certtest.Record{Name: "Maximiliana Konstantinopoulou", RegNumber: "R-1"}
+	certtest.Golden(t, "testdata/long-name.png", opts, rec)
+
+	// On A4 the template page is scaled to fit, as in the PDF
+	opts.Settings["PAGE_SIZE"] = "a4"
+	certtest.Golden(t, "testdata/long-name-a4.png", opts, rec)
+}
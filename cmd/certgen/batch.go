@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/batch"
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func cmdBatch(c *cli, args []string) error {
+	fset := c.flags("batch")
+	reportPath := fset.String("report", "", "write a per-record report with totals to `file` (.csv for CSV, JSON otherwise)")
+	metricsAddr := fset.String("metrics-addr", "", "serve Prometheus metrics on `address` while the batch runs")
+	imposePath := fset.String("impose", "", "also lay the issued certificates out n-up on print sheets (IMPOSE_*) in `file`")
+	coverPath := fset.String("cover", "", "also write an issuance summary PDF listing every certificate issued, with the Merkle root of their PDFs, to `file`")
+	validate := fset.String("validate", "", "check every row before issuing any: `fail` issues nothing if a row is invalid, skip issues only the valid rows")
+	validationReport := fset.String("validation-report", "", "write the problems -validate or -estimate find to `file` (.csv for CSV, JSON otherwise)")
+	maxFieldLength := fset.Int("max-field-length", 200, "with -validate or -estimate, the characters allowed in any one value; 0 for no limit")
+	estimate := fset.Bool("estimate", false, "issue nothing: check every row as -validate does, render a sample and report the expected count, size and duration")
+	estimateSample := fset.Int("estimate-sample", 20, "with -estimate, the `number` of rows rendered to time")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 1); err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+
+	if *estimate {
+		return c.estimate(fset.Arg(0), gen, *maxFieldLength, *validationReport, *estimateSample)
+	}
+
+	var checked *batch.Validation
+	switch *validate {
+	case "":
+	case "fail", "skip":
+		if checked, err = c.checkInput(fset.Arg(0), gen, *maxFieldLength, *validationReport, nil); err != nil {
+			return err
+		}
+		if !checked.OK() && *validate == "fail" {
+			return fmt.Errorf("%d of %d rows are invalid, nothing was issued", checked.Invalid, checked.Rows)
+		}
+	default:
+		return fmt.Errorf("-validate: want fail or skip, got %q", *validate)
+	}
+
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var im *certificate.Imposition
+	if *imposePath != "" {
+		if im, err = gen.NewImposition(); err != nil {
+			return err
+		}
+	}
+
+	in, writeback, err := c.openInput(fset.Arg(0), gen.Config().Location())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if checked != nil {
+		in = checked.Skip(in)
+	}
+
+	var m *metrics.Metrics
+	if *metricsAddr != "" {
+		m = metrics.New()
+		iss.OnResult = m.Observe
+		go func() {
+			if err := m.Serve(*metricsAddr); err != nil {
+				c.logger.Error("metrics server failed", "err", err)
+			}
+		}()
+		c.logger.Info("serving metrics", "url", "http://"+*metricsAddr+"/metrics")
+	}
+
+	var file batch.ReportWriter
+	if *reportPath != "" {
+		if file, err = batch.CreateReport(*reportPath); err != nil {
+			return err
+		}
+	}
+	var cover batch.ReportWriter
+	if *coverPath != "" {
+		// Named for the batch, or the input it was read from
+		title := fset.Arg(0)
+		if !strings.HasPrefix(title, "sheets:") {
+			title = filepath.Base(title)
+		}
+		cover = &coverReport{iss: iss, sheet: gen.NewCoverSheet(title), path: *coverPath, logger: c.logger}
+	}
+	history := batch.NewHistory(batch.HistoryPath(iss.OutputDir), fset.Arg(0), *reportPath)
+	report := batch.MultiReport(file, writeback, cover, history)
+
+	// A shutdown signal stops the batch between records; the report and
+	// registry still cover everything issued.
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	context.AfterFunc(ctx, stop) // a second signal kills the process
+
+	ctx, span := tracer.Start(ctx, "batch",
+		trace.WithAttributes(attribute.String("certgen.input", fset.Arg(0))))
+	defer span.End()
+
+	sum, unimposed, err := c.issueAll(ctx, iss, in, report, im, m)
+	if err != nil {
+		return err
+	}
+	if im != nil && im.Len() > 0 {
+		if err := im.WriteFile(*imposePath); err != nil {
+			return fmt.Errorf("writing imposition: %w", err)
+		}
+		c.logger.Info("imposition written", "path", *imposePath, "certificates", im.Len(),
+			"sheets", im.Sheets(), "per_sheet", im.PerSheet())
+	}
+
+	span.SetAttributes(
+		attribute.Int("certgen.total", sum.Total),
+		attribute.Int("certgen.failed", sum.Failed),
+	)
+	c.logger.Info("batch finished", "total", sum.Total, "succeeded", sum.Succeeded, "failed", sum.Failed)
+	if sum.Interrupted {
+		return fmt.Errorf("interrupted after %d certificates", sum.Total)
+	}
+	if sum.Failed > 0 {
+		return fmt.Errorf("%d of %d certificates failed", sum.Failed, sum.Total)
+	}
+	if unimposed > 0 {
+		return fmt.Errorf("%d issued certificates are missing from the imposition", unimposed)
+	}
+	return nil
+}
+
+// issueAll issues a certificate for every record of in, reporting each to
+// report and closing it with the totals. With im set, the issued
+// certificates are also imposed; unimposed counts those that could not be.
+// A cancelled ctx stops it between records, as an interrupted batch.
+// BATCH_CONCURRENCY records are issued at once, and BATCH_QUEUE and
+// MAX_MEMORY bound how far reading runs ahead. With m set, its queue depth
+// counts the records read ahead and being issued.
+func (c *cli) issueAll(ctx context.Context, iss *issuer.Issuer, in batch.Reader, report batch.ReportWriter, im *certificate.Imposition, m *metrics.Metrics) (sum batch.Summary, unimposed int, err error) {
+	p, err := c.pipeline()
+	if err != nil {
+		return sum, 0, err
+	}
+	p.Issue = iss.IssueContext
+	if m != nil {
+		p.Enqueue = func() func() { return m.Enqueue(1) }
+	}
+	p.Done = func(it batch.Item) error {
+		res := it.Result
+		if it.Err != nil {
+			res = issuer.Result{Name: it.Record.Name, RegNumber: it.Record.RegNumber, Error: it.Err.Error(), Stage: issuer.StageInput}
+			iss.Report(res)
+		}
+
+		sum.Total++
+		if res.OK() && im != nil {
+			rec := it.Record
+			// Printed as issued, including an earlier issue kept by
+			// OUTPUT_EXISTS=skip
+			if e, ok := iss.Registry.Get(res.RegNumber); ok {
+				rec.IssuedAt = e.IssuedAt
+			}
+			if err := im.Add(ctx, rec); err != nil {
+				unimposed++
+				c.logger.Error("certificate left out of the imposition", "row", it.Row, "reg_number", res.RegNumber, "err", err)
+			}
+		}
+		for _, w := range res.Warnings {
+			c.logger.Warn("certificate issued with a warning", "row", it.Row, "reg_number", res.RegNumber, "warning", w)
+		}
+		if res.OK() {
+			sum.Succeeded++
+		} else {
+			sum.Failed++
+			c.logger.Error("certificate failed", "row", it.Row, "reg_number", res.RegNumber, "err", res.Error)
+		}
+		if err := c.printResult(res); err != nil {
+			return err
+		}
+		if err := report.Write(batch.ReportRow{Row: it.Row, Result: res}); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		return nil
+	}
+
+	sum = batch.Summary{StartedAt: time.Now()}
+	sum.Interrupted, err = p.Run(ctx, in)
+	if err != nil {
+		return sum, unimposed, err
+	}
+	if sum.Interrupted {
+		c.logger.Warn("batch interrupted, stopped after the records being issued", "issued", sum.Total)
+	}
+	sum.FinishedAt = time.Now()
+
+	if err := report.Close(sum); err != nil {
+		return sum, unimposed, fmt.Errorf("writing report: %w", err)
+	}
+	return sum, unimposed, nil
+}
+
+// pipeline resolves $BATCH_CONCURRENCY, $BATCH_QUEUE and $MAX_MEMORY.
+func (c *cli) pipeline() (*batch.Pipeline, error) {
+	var errs []error
+	count := func(key, def string, least int) int {
+		v := c.lookup(key, def)
+		n, err := strconv.Atoi(v)
+		if err != nil || n < least {
+			errs = append(errs, fmt.Errorf("%s: want an integer of at least %d, got %q", key, least, v))
+		}
+		return n
+	}
+	p := &batch.Pipeline{
+		Concurrency: count("BATCH_CONCURRENCY", "1", 1),
+		Queue:       count("BATCH_QUEUE", "0", 0),
+	}
+	v := c.lookup("MAX_MEMORY", "0")
+	var err error
+	if p.MaxMemory, err = parseBytes(v); err != nil {
+		errs = append(errs, fmt.Errorf("MAX_MEMORY: want a size such as 512MiB or 2GB, got %q", v))
+	}
+	return p, errors.Join(errs...)
+}
+
+// parseBytes parses a size in bytes, with an optional unit: KB, MB and GB
+// are powers of 1000, KiB, MiB and GiB powers of 1024.
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	mult, ok := map[string]float64{
+		"": 1, "b": 1,
+		"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+		"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+	}[strings.ToLower(s[len(num):])]
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * mult), nil
+}
+
+// checkInput validates every row of the batch input arg before any is
+// issued, logging each problem and writing them to reportPath if it is set.
+// planned, if set, is given every row that plans without problems.
+func (c *cli) checkInput(arg string, gen *certificate.Generator, maxFieldLength int, reportPath string, planned func(row int, rec certificate.Record, p certificate.Plan)) (*batch.Validation, error) {
+	if arg == "-" {
+		return nil, errors.New("checking the input before issuing reads it twice, so it needs a file or sheet, not stdin")
+	}
+	in, _, err := c.openInput(arg, gen.Config().Location())
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	v, err := batch.Check(in, batch.CheckOptions{
+		MaxFieldLength: maxFieldLength,
+		Plan: func(rec certificate.Record) error {
+			p, err := gen.Plan(rec, c.outputDir())
+			if err == nil && planned != nil {
+				planned(in.Row(), rec, p)
+			}
+			return err
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range v.Problems {
+		c.logger.Warn("invalid row", "row", p.Row, "reg_number", p.RegNumber, "field", p.Field, "problem", p.Message)
+	}
+	c.logger.Info("batch validated", "rows", v.Rows, "invalid", v.Invalid)
+	if reportPath != "" {
+		if err := v.WriteFile(reportPath); err != nil {
+			return nil, fmt.Errorf("writing validation report: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// coverReport lists the certificates a batch issues on a cover sheet,
+// written when the batch closes its report.
+type coverReport struct {
+	iss    *issuer.Issuer
+	sheet  *certificate.CoverSheet
+	path   string
+	logger *slog.Logger
+}
+
+func (r *coverReport) Write(row batch.ReportRow) error {
+	if !row.OK() {
+		return nil
+	}
+	e := certificate.CoverEntry{Name: row.Name, RegNumber: row.RegNumber, SHA256: row.SHA256}
+	// Listed as issued, including an earlier issue kept by OUTPUT_EXISTS=skip
+	if reg, ok := r.iss.Registry.Get(row.RegNumber); ok {
+		e.IssuedAt = reg.IssuedAt
+		if e.SHA256 == "" {
+			e.SHA256 = reg.SHA256
+		}
+	}
+	return r.sheet.Add(e)
+}
+
+func (r *coverReport) Close(batch.Summary) error {
+	if r.sheet.Len() == 0 {
+		return nil
+	}
+	if err := r.sheet.WriteFile(r.path); err != nil {
+		return fmt.Errorf("cover sheet: %w", err)
+	}
+	r.logger.Info("cover sheet written", "path", r.path, "certificates", r.sheet.Len(), "merkle_root", r.sheet.MerkleRoot())
+	return nil
+}
+
+// openInput opens a batch input: a file, "-" for stdin, or
+// "sheets:SPREADSHEET_ID" for a Google Sheet, reading dates without a zone
+// in loc. For sheets with a SHEETS_WRITEBACK_COLUMN, writeback records each
+// row's outcome in the sheet.
+func (c *cli) openInput(arg string, loc *time.Location) (in batch.Reader, writeback batch.ReportWriter, err error) {
+	id, ok := strings.CutPrefix(arg, "sheets:")
+	if !ok {
+		in, err = batch.Open(arg, loc)
+		return in, nil, err
+	}
+	sheet, err := batch.OpenSheet(context.Background(), batch.SheetConfig{
+		SpreadsheetID:   id,
+		Range:           c.lookup("SHEETS_RANGE", ""),
+		Credentials:     c.lookup("GOOGLE_APPLICATION_CREDENTIALS", ""),
+		WritebackColumn: c.lookup("SHEETS_WRITEBACK_COLUMN", ""),
+		Location:        loc,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("sheet %s: %w", id, err)
+	}
+	return sheet, sheet.Writeback(), nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/delivery"
+	"github.com/Sathimantha/certificate_generator_go/internal/hook"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+	"github.com/Sathimantha/certificate_generator_go/internal/wallet"
+)
+
+// deliverySettings are the settings of the delivery sinks, hooks and their
+// retry policy. Secrets (SMTP_PASSWORD, UPLOAD_AUTHORIZATION) have no flags.
+var deliverySettings = []certificate.Setting{
+	{Key: "SMTP_ADDR"},
+	{Key: "SMTP_USERNAME"},
+	{Key: "SMTP_FROM"},
+	{Key: "EMAIL_SUBJECT", Default: delivery.DefaultSubject},
+	{Key: "EMAIL_BODY"},
+	{Key: "UPLOAD_URL"},
+	{Key: "APPLE_PASS_TYPE_ID"},
+	{Key: "APPLE_TEAM_ID"},
+	{Key: "APPLE_PASS_CERT"},
+	{Key: "APPLE_PASS_KEY"},
+	{Key: "APPLE_WWDR_CERT"},
+	{Key: "APPLE_PASS_ICON"},
+	{Key: "APPLE_PASS_ORGANIZATION", Default: "ISSUER_NAME"},
+	{Key: "APPLE_PASS_FOREGROUND", Default: "black"},
+	{Key: "APPLE_PASS_BACKGROUND", Default: "white"},
+	{Key: "APPLE_PASS_LABEL", Default: "gray"},
+	{Key: "GOOGLE_WALLET_ISSUER_ID"},
+	{Key: "GOOGLE_WALLET_CLASS_ID", Default: "certificate"},
+	{Key: "GOOGLE_WALLET_CREDENTIALS", Default: "GOOGLE_APPLICATION_CREDENTIALS"},
+	{Key: "GOOGLE_WALLET_ORGANIZATION", Default: "ISSUER_NAME"},
+	{Key: "GOOGLE_WALLET_LOGO_URL"},
+	{Key: "GOOGLE_WALLET_BACKGROUND", Default: "white"},
+	{Key: "GOOGLE_WALLET_ORIGINS"},
+	{Key: "HOOK_BEFORE"},
+	{Key: "HOOK_AFTER"},
+	{Key: "HOOK_TIMEOUT", Default: "30s"},
+	{Key: "HOOK_PLUGINS"},
+	{Key: "RETRY_ATTEMPTS", Default: strconv.Itoa(retry.Default.Attempts)},
+	{Key: "RETRY_BACKOFF", Default: retry.Default.Initial.String()},
+	{Key: "RETRY_MAX_BACKOFF", Default: retry.Default.Max.String()},
+	{Key: "RETRY_JITTER", Default: strconv.FormatFloat(retry.Default.Jitter, 'g', -1, 64)},
+}
+
+// sinks builds the delivery sinks configured in src: Apple Wallet passes
+// when APPLE_PASS_TYPE_ID is set, Google Wallet links when
+// GOOGLE_WALLET_ISSUER_ID is set, email when SMTP_ADDR is set, upload when
+// UPLOAD_URL is set. The wallets come first so emails can link to them.
+func sinks(src certificate.Source) ([]issuer.Sink, error) {
+	var out []issuer.Sink
+	if id := lookupIn(src, "APPLE_PASS_TYPE_ID", ""); id != "" {
+		a, err := applePasses(src, id)
+		if err != nil {
+			return nil, fmt.Errorf("Apple Wallet passes: %w", err)
+		}
+		out = append(out, a)
+	}
+	if id := lookupIn(src, "GOOGLE_WALLET_ISSUER_ID", ""); id != "" {
+		g, err := googlePasses(src, id)
+		if err != nil {
+			return nil, fmt.Errorf("Google Wallet passes: %w", err)
+		}
+		out = append(out, g)
+	}
+	if addr := lookupIn(src, "SMTP_ADDR", ""); addr != "" {
+		e, err := delivery.NewEmail(delivery.EmailConfig{
+			Addr:     addr,
+			Username: lookupIn(src, "SMTP_USERNAME", ""),
+			Password: lookupIn(src, "SMTP_PASSWORD", ""),
+			From:     lookupIn(src, "SMTP_FROM", ""),
+			Subject:  lookupIn(src, "EMAIL_SUBJECT", ""),
+			Body:     lookupIn(src, "EMAIL_BODY", ""),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("email delivery: %w", err)
+		}
+		out = append(out, e)
+	}
+	if url := lookupIn(src, "UPLOAD_URL", ""); url != "" {
+		u, err := delivery.NewUpload(url, lookupIn(src, "UPLOAD_AUTHORIZATION", ""))
+		if err != nil {
+			return nil, fmt.Errorf("upload delivery: %w", err)
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+func applePasses(src certificate.Source, passTypeID string) (*wallet.Apple, error) {
+	var errs []error
+	colorOf := func(key, def string) color.RGBA {
+		v := lookupIn(src, key, def)
+		c, err := certificate.ParseColor(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+		return c
+	}
+	cfg := wallet.AppleConfig{
+		PassTypeID:   passTypeID,
+		TeamID:       lookupIn(src, "APPLE_TEAM_ID", ""),
+		Organization: lookupIn(src, "APPLE_PASS_ORGANIZATION", lookupIn(src, "ISSUER_NAME", "Certificates")),
+		CertFile:     lookupIn(src, "APPLE_PASS_CERT", ""),
+		KeyFile:      lookupIn(src, "APPLE_PASS_KEY", ""),
+		WWDRFile:     lookupIn(src, "APPLE_WWDR_CERT", ""),
+		IconFile:     lookupIn(src, "APPLE_PASS_ICON", ""),
+		Foreground:   colorOf("APPLE_PASS_FOREGROUND", "black"),
+		Background:   colorOf("APPLE_PASS_BACKGROUND", "white"),
+		Label:        colorOf("APPLE_PASS_LABEL", "gray"),
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return wallet.NewApple(cfg)
+}
+
+func googlePasses(src certificate.Source, issuerID string) (*wallet.Google, error) {
+	v := lookupIn(src, "GOOGLE_WALLET_BACKGROUND", "white")
+	bg, err := certificate.ParseColor(v)
+	if err != nil {
+		return nil, fmt.Errorf("GOOGLE_WALLET_BACKGROUND: %w", err)
+	}
+	return wallet.NewGoogle(wallet.GoogleConfig{
+		IssuerID:        issuerID,
+		ClassID:         lookupIn(src, "GOOGLE_WALLET_CLASS_ID", "certificate"),
+		CredentialsFile: lookupIn(src, "GOOGLE_WALLET_CREDENTIALS", lookupIn(src, "GOOGLE_APPLICATION_CREDENTIALS", "")),
+		Organization:    lookupIn(src, "GOOGLE_WALLET_ORGANIZATION", lookupIn(src, "ISSUER_NAME", "Certificates")),
+		LogoURL:         lookupIn(src, "GOOGLE_WALLET_LOGO_URL", ""),
+		Background:      bg,
+		Origins:         strings.Fields(strings.ReplaceAll(lookupIn(src, "GOOGLE_WALLET_ORIGINS", ""), ",", " ")),
+	})
+}
+
+// hooks builds the hooks configured in src: the HOOK_BEFORE and HOOK_AFTER
+// commands, then the Go plugins listed in HOOK_PLUGINS, in order.
+func hooks(src certificate.Source) ([]issuer.Hook, error) {
+	var out []issuer.Hook
+	before, after := lookupIn(src, "HOOK_BEFORE", ""), lookupIn(src, "HOOK_AFTER", "")
+	if before != "" || after != "" {
+		v := lookupIn(src, "HOOK_TIMEOUT", "30s")
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("HOOK_TIMEOUT: want a duration such as 30s, got %q", v)
+		}
+		cmd, err := hook.NewCommand(before, after, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("hook command: %w", err)
+		}
+		out = append(out, cmd)
+	}
+	for _, path := range strings.Split(lookupIn(src, "HOOK_PLUGINS", ""), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		h, err := hook.OpenPlugin(path)
+		if err != nil {
+			return nil, fmt.Errorf("HOOK_PLUGINS: %w", err)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// retryPolicy reads the RETRY_* settings in src.
+func retryPolicy(src certificate.Source) (retry.Policy, error) {
+	var errs []error
+	dur := func(key string, def time.Duration) time.Duration {
+		v := lookupIn(src, key, def.String())
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%s: want a duration such as 2s, got %q", key, v))
+		}
+		return d
+	}
+	p := retry.Policy{
+		Initial: dur("RETRY_BACKOFF", retry.Default.Initial),
+		Max:     dur("RETRY_MAX_BACKOFF", retry.Default.Max),
+	}
+	v := lookupIn(src, "RETRY_ATTEMPTS", strconv.Itoa(retry.Default.Attempts))
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		errs = append(errs, fmt.Errorf("RETRY_ATTEMPTS: want a positive integer, got %q", v))
+	}
+	p.Attempts = n
+	v = lookupIn(src, "RETRY_JITTER", strconv.FormatFloat(retry.Default.Jitter, 'g', -1, 64))
+	j, err := strconv.ParseFloat(v, 64)
+	if err != nil || j < 0 || j > 1 {
+		errs = append(errs, fmt.Errorf("RETRY_JITTER: want a fraction from 0 to 1, got %q", v))
+	}
+	p.Jitter = j
+	return p, errors.Join(errs...)
+}
+
+// newIssuer assembles an issuer with the sinks and retry policy of src.
+func newIssuer(src certificate.Source, gen *certificate.Generator, reg *registry.Registry, dir string) (*issuer.Issuer, error) {
+	s, err := sinks(src)
+	if err != nil {
+		return nil, err
+	}
+	h, err := hooks(src)
+	if err != nil {
+		return nil, err
+	}
+	p, err := retryPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	return &issuer.Issuer{Gen: gen, Registry: reg, OutputDir: dir, Sinks: s, Hooks: h, Retry: p}, nil
+}
+
+func lookupIn(src certificate.Source, key, def string) string {
+	if v, ok := src(key); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// demoSample is one record of fake data that stresses part of the layout.
+type demoSample struct {
+	name string
+	rec  certificate.Record
+}
+
+// demoSamples are the demo records: an ordinary one, then the extremes
+// real batches contain. gradeField, if set, gets a score on every record.
+func demoSamples(gradeField string) []demoSample {
+	// September has the longest English month name, for the expiry line
+	longest := time.Date(2029, time.September, 30, 0, 0, 0, 0, time.UTC)
+	samples := []demoSample{
+		{"typical", certificate.Record{Name: "Jane Q. Sample", RegNumber: "DEMO-0001", Course: "Certificate Course"}},
+		{"long-name", certificate.Record{
+			Name:      "Maximiliana Alexandra Konstantinopoulou-Featherstonehaugh Vanderbilt",
+			RegNumber: "DEMO-0002",
+			Course:    "Certificate Course",
+		}},
+		{"diacritics", certificate.Record{Name: "Françoise Zoë Ångström-Núñez Škoda", RegNumber: "DEMO-0003", Course: "Cours de certification"}},
+		{"long-reg-number", certificate.Record{
+			Name:      "Wolfgang Mwangi-Thorsdottir",
+			RegNumber: "DEMO-2029-WWWWWWWW-0000000004",
+			Course:    "Certificate Course",
+			ExpiresAt: longest,
+		}},
+		{"short", certificate.Record{Name: "Li", RegNumber: "D5"}},
+	}
+	if gradeField != "" {
+		for i := range samples {
+			samples[i].rec.Fields = map[string]string{gradeField: "100"}
+		}
+	}
+	return samples
+}
+
+// demoResult is the outcome of one demo sample.
+type demoResult struct {
+	Sample   string   `json:"sample"`
+	Name     string   `json:"name"`
+	Path     string   `json:"path,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// cmdDemo renders the demo samples with the current configuration into a
+// directory of their own, without registering or delivering anything, and
+// reports every layout problem they run into.
+func cmdDemo(c *cli, args []string) error {
+	fset := c.flags("demo")
+	dir := fset.String("dir", "", "write the demo PDFs to `directory` (default \"demo\" in the output directory)")
+	strict := fset.Bool("strict", false, "fail on layout warnings as well as errors")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0); err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = filepath.Join(c.outputDir(), "demo")
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	failed, warned := 0, 0
+	for _, s := range demoSamples(gen.Config().Grade.Field) {
+		res := demoResult{Sample: s.name, Name: s.rec.Name}
+		plan, err := gen.Plan(s.rec, *dir)
+		res.Warnings = plan.Warnings
+		if err == nil {
+			res.Path = filepath.Join(*dir, "demo-"+s.name+".pdf")
+			err = renderFile(gen, s.rec, res.Path)
+		}
+		if err != nil {
+			res.Path, res.Error = "", err.Error()
+			failed++
+		}
+		if len(res.Warnings) > 0 {
+			warned++
+		}
+
+		switch {
+		case c.jsonOut:
+			if err := json.NewEncoder(c.stdout).Encode(res); err != nil {
+				return err
+			}
+		case !c.quiet:
+			if res.Path != "" {
+				fmt.Fprintf(c.stdout, "%-16s %s\n", s.name, res.Path)
+			} else {
+				fmt.Fprintf(c.stdout, "%-16s FAILED\n", s.name)
+			}
+			for _, w := range res.Warnings {
+				fmt.Fprintf(c.stdout, "  warning: %s\n", w)
+			}
+			if res.Error != "" {
+				fmt.Fprintf(c.stdout, "  error:   %s\n", strings.ReplaceAll(res.Error, "\n", "\n           "))
+			}
+		}
+	}
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d demo certificates failed", failed)
+	case *strict && warned > 0:
+		return fmt.Errorf("%d demo certificates have layout warnings", warned)
+	}
+	return nil
+}
+
+// renderFile renders rec to path, replacing any earlier demo.
+func renderFile(gen *certificate.Generator, rec certificate.Record, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = gen.Render(f, rec)
+	return errors.Join(err, f.Close())
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+//go:embed designer
+var designerFiles embed.FS
+
+// designerServer serves the layout designer UI and converts the layout the
+// user drags into config file entries.
+type designerServer struct {
+	envFile string
+	source  func() (certificate.Source, error)
+	rec     certificate.Record
+	logger  *slog.Logger
+}
+
+// designerLayout is exchanged with the UI. All positions and sizes are in
+// mm; the height of a text box is its font size, because the generator uses
+// the point size as the cell height.
+type designerLayout struct {
+	PageWidth  float64                `json:"page_width"`
+	PageHeight float64                `json:"page_height"`
+	Inset      float64                `json:"inset"`
+	Fit        string                 `json:"fit"`
+	Template   bool                   `json:"template"`
+	DPI        float64                `json:"dpi"`
+	Fields     []certificate.FieldBox `json:"fields"`
+}
+
+func cmdDesigner(c *cli, args []string) error {
+	fset := c.flags("designer")
+	addr := fset.String("addr", "localhost:8081", "listen `address`")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0, 2); err != nil {
+		return err
+	}
+
+	d := &designerServer{envFile: c.envFile, source: c.source, rec: sampleRecord(fset), logger: c.logger}
+	return d.run(*addr)
+}
+
+func (d *designerServer) run(addr string) error {
+	static, err := fs.Sub(designerFiles, "designer")
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.HandleFunc("GET /api/layout", d.handleLayout)
+	mux.HandleFunc("GET /api/template", d.handleTemplate)
+	mux.HandleFunc("POST /api/export", d.handleExport)
+	mux.HandleFunc("POST /api/save", d.handleSave)
+
+	d.logger.Info("layout designer listening", "url", "http://"+addr+"/", "config", d.envFile)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (d *designerServer) config() (certificate.Config, error) {
+	src, err := d.source()
+	if err != nil {
+		return certificate.Config{}, err
+	}
+	return certificate.LoadConfig(src)
+}
+
+func (d *designerServer) handleLayout(w http.ResponseWriter, r *http.Request) {
+	cfg, err := d.config()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	gen, err := certificate.New(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	boxes, err := gen.FieldBoxes(d.rec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	width, height := cfg.PageSize()
+	writeJSON(w, designerLayout{
+		PageWidth:  width,
+		PageHeight: height,
+		Inset:      cfg.TemplateInset,
+		Fit:        strings.ToLower(cfg.TemplateFit),
+		Template:   cfg.TemplateImage != "",
+		DPI:        cfg.DPI,
+		Fields:     boxes,
+	})
+}
+
+func (d *designerServer) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	cfg, err := d.config()
+	if err != nil || cfg.TemplateImage == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeFile(w, r, cfg.TemplateImage)
+}
+
+// handleExport returns the config file with the posted layout applied, as a
+// download.
+func (d *designerServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	content, err := d.apply(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(d.envFile)))
+	fmt.Fprint(w, content)
+}
+
+// handleSave writes the posted layout back into the config file, which a
+// running preview server picks up immediately.
+func (d *designerServer) handleSave(w http.ResponseWriter, r *http.Request) {
+	content, err := d.apply(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := os.WriteFile(d.envFile, []byte(content), 0o644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.logger.Info("layout saved", "config", d.envFile)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply decodes a layout posted by the UI and merges it into the current
+// config file contents.
+func (d *designerServer) apply(w http.ResponseWriter, r *http.Request) (string, error) {
+	var layout designerLayout
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&layout); err != nil {
+		return "", fmt.Errorf("invalid layout: %w", err)
+	}
+	cfg, err := d.config()
+	if err != nil {
+		return "", err
+	}
+
+	set := map[string]string{}
+	for _, b := range layout.Fields {
+		switch b.Field {
+		case "NAME", "REG":
+			f := cfg.Name
+			if b.Field == "REG" {
+				f = cfg.Reg
+			}
+			f.Size = b.Size
+			set[b.Field+"_LEFT"] = mm(b.X)
+			set[b.Field+"_TOP"] = mm(f.TopForBox(b.Y))
+			set[b.Field+"_SIZE"] = mm(b.Size)
+			if f.LineHeight > 0 {
+				set[b.Field+"_LINE_HEIGHT"] = mm(b.H)
+			}
+		case "QR":
+			set["QR_LEFT"] = mm(b.X)
+			set["QR_TOP"] = mm(b.Y)
+			if cfg.QR.SizeMM > 0 {
+				set["QR_SIZE_MM"] = mm(b.W)
+			} else {
+				set["QR_SIZE"] = strconv.Itoa(int(b.W*cfg.DPI/25.4 + 0.5))
+			}
+		}
+	}
+
+	existing, err := os.ReadFile(d.envFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return updateEnv(string(existing), set), nil
+}
+
+func mm(v float64) string {
+	return strconv.FormatFloat(float64(int(v*10+0.5))/10, 'f', -1, 64)
+}
+
+var envLine = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=`)
+
+// updateEnv rewrites the assignments of the keys in set inside a .env file,
+// keeping comments, ordering and every other line, and appends keys that
+// were not present.
+func updateEnv(content string, set map[string]string) string {
+	var out strings.Builder
+	done := map[string]bool{}
+
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := sc.Text()
+		if m := envLine.FindStringSubmatch(line); m != nil {
+			if v, ok := set[m[1]]; ok {
+				line = m[1] + "=" + v
+				done[m[1]] = true
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	var added []string
+	for k := range set {
+		if !done[k] {
+			added = append(added, k)
+		}
+	}
+	if len(added) > 0 {
+		slices.Sort(added)
+		out.WriteString("\n# Layout exported by the certgen designer\n")
+		for _, k := range added {
+			out.WriteString(k + "=" + set[k] + "\n")
+		}
+	}
+	return out.String()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/batch"
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// batchEstimate is what certgen batch -estimate expects of a batch.
+type batchEstimate struct {
+	Rows    int `json:"rows"`
+	Invalid int `json:"invalid"`
+	Issued  int `json:"issued"`  // certificates the batch would write
+	Kept    int `json:"kept"`    // valid rows whose existing PDF OUTPUT_EXISTS=skip keeps
+	Sampled int `json:"sampled"` // rows rendered to measure
+
+	AverageBytes int64         `json:"average_bytes"`
+	TotalBytes   int64         `json:"total_bytes"`
+	AverageTime  time.Duration `json:"average_ns"`
+	// Duration is the rendering time of the whole batch at Concurrency
+	// records at once. Delivery is not included.
+	Duration    time.Duration `json:"duration_ns"`
+	Concurrency int           `json:"concurrency"`
+
+	Problems []batch.Problem `json:"problems"`
+}
+
+// estimate checks every row of the batch input arg as -validate does,
+// renders a random sample of up to sample valid rows to memory, and
+// extrapolates the batch's output count, size and rendering time from
+// them. Nothing is written but the validation report, if asked for.
+func (c *cli) estimate(arg string, gen *certificate.Generator, maxFieldLength int, reportPath string, sample int) error {
+	if sample < 1 {
+		return fmt.Errorf("-estimate-sample: want at least 1, got %d", sample)
+	}
+	p, err := c.pipeline()
+	if err != nil {
+		return err
+	}
+
+	// A reservoir sample, so rows from all over the batch are rendered
+	var (
+		picked  []certificate.Record
+		planned int
+		skips   []int // rows whose existing PDF would be kept
+	)
+	v, err := c.checkInput(arg, gen, maxFieldLength, reportPath, func(row int, rec certificate.Record, plan certificate.Plan) {
+		if plan.Skip {
+			skips = append(skips, row)
+			return
+		}
+		planned++
+		if len(picked) < sample {
+			picked = append(picked, rec)
+		} else if i := rand.IntN(planned); i < sample {
+			picked[i] = rec
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	est := batchEstimate{Rows: v.Rows, Invalid: v.Invalid, Concurrency: p.Concurrency, Problems: v.Problems}
+	for _, row := range skips {
+		if v.Valid(row) {
+			est.Kept++
+		}
+	}
+	est.Issued = v.Rows - v.Invalid - est.Kept
+	if len(picked) > 0 {
+		// Fonts and the template are loaded on the first render; it is
+		// not what the rest of the batch costs
+		if _, err := gen.Render(io.Discard, picked[0]); err != nil {
+			return fmt.Errorf("rendering a sample certificate: %w", err)
+		}
+		var bytes int64
+		start := time.Now()
+		for _, rec := range picked {
+			res, err := gen.Render(io.Discard, rec)
+			if err != nil {
+				return fmt.Errorf("rendering sample certificate %s: %w", rec.RegNumber, err)
+			}
+			bytes += res.Size
+		}
+		elapsed := time.Since(start)
+		est.Sampled = len(picked)
+		est.AverageBytes = bytes / int64(len(picked))
+		est.AverageTime = elapsed / time.Duration(len(picked))
+		est.TotalBytes = est.AverageBytes * int64(est.Issued)
+		est.Duration = est.AverageTime * time.Duration(est.Issued) / time.Duration(max(p.Concurrency, 1))
+	}
+
+	switch {
+	case c.jsonOut:
+		if err := json.NewEncoder(c.stdout).Encode(est); err != nil {
+			return err
+		}
+	case !c.quiet:
+		fmt.Fprintf(c.stdout, "rows:        %d (%d invalid)\n", est.Rows, est.Invalid)
+		fmt.Fprintf(c.stdout, "would issue: %d certificates", est.Issued)
+		if est.Kept > 0 {
+			fmt.Fprintf(c.stdout, ", keeping %d existing", est.Kept)
+		}
+		fmt.Fprintln(c.stdout)
+		if est.Sampled > 0 {
+			fmt.Fprintf(c.stdout, "sample:      %d rendered, %s and %s each on average\n",
+				est.Sampled, formatBytes(est.AverageBytes), est.AverageTime.Round(time.Millisecond/10))
+			fmt.Fprintf(c.stdout, "total size:  about %s\n", formatBytes(est.TotalBytes))
+			fmt.Fprintf(c.stdout, "duration:    about %s rendering at BATCH_CONCURRENCY=%d, not counting delivery\n",
+				est.Duration.Round(time.Second), est.Concurrency)
+		}
+		for _, p := range v.Problems {
+			fmt.Fprintf(c.stdout, "row %d: %s\n", p.Row, p.Message)
+		}
+	}
+	return nil
+}
+
+// formatBytes formats n bytes with a binary unit, such as "3.2 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+func cmdGenerate(c *cli, args []string) error {
+	fset := c.flags("generate")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 2); err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res := iss.Issue(certificate.Record{Name: fset.Arg(0), RegNumber: fset.Arg(1)})
+	for _, w := range res.Warnings {
+		c.logger.Warn("certificate issued with a warning", "reg_number", res.RegNumber, "warning", w)
+	}
+	if err := c.printResult(res); err != nil {
+		return err
+	}
+	if !res.OK() {
+		return errors.New(res.Error)
+	}
+	return nil
+}
+
+// issuer prepares the output directory, registry, delivery sinks and
+// tracing for issuing. done closes the registry and flushes traces.
+func (c *cli) issuer(gen *certificate.Generator) (iss *issuer.Issuer, done func(), err error) {
+	dir := c.outputDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	reg, err := c.openRegistry()
+	if err != nil {
+		return nil, nil, err
+	}
+	flush, err := c.setupTracing()
+	if err != nil {
+		reg.Close()
+		return nil, nil, err
+	}
+	iss, err = newIssuer(c.src, gen, reg, dir)
+	if err != nil {
+		flush()
+		reg.Close()
+		return nil, nil, err
+	}
+	return iss, func() { flush(); reg.Close() }, nil
+}
+
+// printResult reports one issued certificate: a JSON object with --json,
+// the output path otherwise, nothing with --quiet.
+func (c *cli) printResult(res issuer.Result) error {
+	switch {
+	case c.jsonOut:
+		return json.NewEncoder(c.stdout).Encode(res)
+	case !c.quiet && res.OK():
+		_, err := fmt.Fprintln(c.stdout, res.Path)
+		return err
+	}
+	return nil
+}
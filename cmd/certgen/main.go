@@ -0,0 +1,408 @@
+// Command certgen generates, serves, verifies and revokes certificates.
+//
+//	certgen generate [flags] NAME REG_NUMBER
+//	certgen batch    [flags] FILE | sheets:SPREADSHEET_ID
+//	certgen serve    [flags]
+//	certgen worker   [flags]
+//	certgen watch    [flags] INBOX
+//	certgen verify   [flags] REG_NUMBER [PDF]
+//	certgen revoke   [flags] REG_NUMBER
+//	certgen resend   [flags] [REG_NUMBER...]
+//	certgen repair   [flags] [REG_NUMBER...]
+//	certgen preview  [flags] [NAME REG_NUMBER]
+//	certgen designer [flags] [NAME REG_NUMBER]
+//	certgen validate [flags] [NAME REG_NUMBER]
+//	certgen demo     [flags]
+//
+// Every configuration setting can be given, in order of precedence, as a
+// command-line flag (NAME_SIZE as --name-size), an environment variable, an
+// entry in the .env file, or left to its default. With -tenant NAME the
+// settings in $TENANTS_DIR/NAME.env take precedence over all of those. Run
+// "certgen help COMMAND" for the flags of a command.
+//
+// certgen batch and watch stream their input and write reports as results
+// arrive, so batches of any size run in bounded memory: BATCH_CONCURRENCY
+// records are issued at once, at most BATCH_QUEUE are read ahead, and
+// reading pauses while the heap is above MAX_MEMORY (a size such as
+// 512MiB), which is also the Go runtime's soft memory limit.
+//
+// Asset settings (TEMPLATE_IMAGE, FONT_FILE, FONT_BOLD_FILE and catalog
+// signatures) may also be https URLs, cached in ASSET_CACHE_DIR for
+// ASSET_CACHE_TTL, or builtin:PATH for the stock template and fonts built
+// into certgen, e.g. builtin:fonts/helvetica-255/Helvetica.ttf.
+//
+// certgen serve re-reads the .env and tenant files on SIGHUP or POST
+// /admin/reload, and keeps serving with the old configuration if the new
+// one is invalid.
+//
+// certgen serve also serves a dashboard for operations staff at /admin/,
+// logged into with an admin API key as the password. It lists the batch
+// runs that certgen batch and watch record in <output dir>/batches.jsonl.
+//
+// certgen repair checks every issued PDF against the SHA-256 in the
+// registry and regenerates the missing or altered ones in place. A PDF is
+// dated when it was issued, so with the same configuration and, via
+// -input, the batch file it came from, the regenerated PDF is the issued
+// one byte for byte.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	_ "time/tzdata" // TIMEZONE works on hosts without a zone database
+
+	"github.com/joho/godotenv"
+
+	"github.com/Sathimantha/certificate_generator_go/assets"
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+	"github.com/Sathimantha/certificate_generator_go/internal/tenant"
+)
+
+// command is one certgen subcommand.
+type command struct {
+	name    string
+	args    string // synopsis of the positional arguments
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because the help command refers to the table.
+	commands = []command{
+		{"generate", "NAME REG_NUMBER", "generate one certificate", cmdGenerate},
+		{"batch", "FILE | sheets:SPREADSHEET_ID", "generate a certificate for every row of a CSV, Excel or JSON file or Google Sheet", cmdBatch},
+		{"serve", "", "serve the HTTP issuance and verification API", cmdServe},
+		{"worker", "", "issue certificates for jobs from a NATS JetStream queue", cmdWorker},
+		{"watch", "INBOX", "issue certificates for every batch file dropped into a directory", cmdWatch},
+		{"verify", "REG_NUMBER [PDF]", "check a certificate against the registry", cmdVerify},
+		{"revoke", "REG_NUMBER", "revoke an issued certificate", cmdRevoke},
+		{"resend", "[REG_NUMBER...]", "deliver issued certificates again, by default those whose email failed or bounced", cmdResend},
+		{"repair", "[REG_NUMBER...]", "regenerate issued certificates whose PDF is missing or does not match the registry", cmdRepair},
+		{"preview", "[NAME REG_NUMBER]", "serve a live-reloading sample certificate", cmdPreview},
+		{"designer", "[NAME REG_NUMBER]", "serve the drag-and-drop layout designer", cmdDesigner},
+		{"validate", "[NAME REG_NUMBER]", "check configuration and layout without writing anything", cmdValidate},
+		{"demo", "", "render sample certificates with extreme names and numbers to catch layout problems", cmdDemo},
+		{"help", "[COMMAND]", "show help for a command", cmdHelp},
+	}
+}
+
+func init() {
+	certificate.RegisterAssetFS("builtin", assets.FS)
+}
+
+func main() {
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr}
+	if err := c.run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "certgen:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// cli holds the state shared by all subcommands: output streams and the
+// common flags.
+type cli struct {
+	stdout, stderr io.Writer
+
+	envFile    string
+	tenantName string
+	quiet      bool
+	verbose    bool
+	jsonOut    bool
+	overrides  map[string]string // settings given as flags
+
+	src    certificate.Source
+	base   certificate.Source // src without the tenant overlay
+	tenant *tenant.Tenant
+	logger *slog.Logger
+}
+
+func (c *cli) run(args []string) error {
+	if len(args) == 0 {
+		c.usage(c.stderr)
+		return errors.New("missing command")
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok {
+		c.usage(c.stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(c, args[1:])
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (c *cli) usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: certgen COMMAND [flags] [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nRun \"certgen help COMMAND\" for the flags of a command.")
+}
+
+// flags returns a FlagSet for the named command with the common flags
+// registered.
+func (c *cli) flags(name string) *flag.FlagSet {
+	cmd, _ := lookupCommand(name)
+	fset := flag.NewFlagSet("certgen "+name, flag.ContinueOnError)
+	fset.SetOutput(c.stderr)
+	c.overrides = map[string]string{}
+	fset.Var(c.setting("OUTPUT_DIR"), "out", "output `directory`, same as $OUTPUT_DIR (default \"output\")")
+	fset.StringVar(&c.envFile, "env", ".env", "`file` to load configuration from, if present")
+	fset.StringVar(&c.tenantName, "tenant", "", "act for the tenant configured in `name`.env in $TENANTS_DIR")
+	fset.BoolVar(&c.quiet, "quiet", false, "suppress all output except errors")
+	fset.BoolVar(&c.verbose, "verbose", false, "log debug messages too, same as LOG_LEVEL=debug")
+	fset.BoolVar(&c.jsonOut, "json", false, "print machine-readable JSON on stdout")
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "usage: certgen %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		fset.PrintDefaults()
+	}
+	return fset
+}
+
+// settingFlags registers a flag for every configuration setting, plus the
+// registry, API, delivery, logging and tracing settings that live outside
+// the generator config. API_KEYS has no flag so secrets stay out of process
+// listings.
+func (c *cli) settingFlags(fset *flag.FlagSet) {
+	settings := append(certificate.Settings(),
+		certificate.Setting{Key: "REGISTRY_PATH", Default: "<output dir>/registry.jsonl"},
+		certificate.Setting{Key: "LOG_LEVEL", Default: "info"},
+		certificate.Setting{Key: "LOG_FORMAT", Default: "text"},
+		certificate.Setting{Key: "TENANTS_DIR", Default: "tenants"},
+		certificate.Setting{Key: "API_KEYS_FILE"},
+		certificate.Setting{Key: "RATE_LIMIT", Default: "0, unlimited"},
+		certificate.Setting{Key: "RATE_LIMIT_BURST", Default: "20"},
+		certificate.Setting{Key: "KEY_RATE_LIMIT", Default: "0, unlimited"},
+		certificate.Setting{Key: "KEY_RATE_LIMIT_BURST", Default: "10"},
+		certificate.Setting{Key: "MAX_CONCURRENT_GENERATIONS", Default: "number of CPUs"},
+		certificate.Setting{Key: "SHUTDOWN_TIMEOUT", Default: "30s"},
+		certificate.Setting{Key: "BATCH_CONCURRENCY", Default: "1"},
+		certificate.Setting{Key: "BATCH_QUEUE", Default: "2×BATCH_CONCURRENCY"},
+		certificate.Setting{Key: "MAX_MEMORY", Default: "0, unlimited"},
+		certificate.Setting{Key: "IDEMPOTENCY_TTL", Default: "24h"},
+		certificate.Setting{Key: "QUEUE_URL", Default: "nats://localhost:4222"},
+		certificate.Setting{Key: "QUEUE_STREAM", Default: "CERTGEN"},
+		certificate.Setting{Key: "QUEUE_SUBJECT", Default: "certgen.jobs"},
+		certificate.Setting{Key: "QUEUE_CONSUMER", Default: "certgen"},
+		certificate.Setting{Key: "QUEUE_DEAD_LETTER_SUBJECT", Default: "certgen.dead"},
+		certificate.Setting{Key: "QUEUE_MAX_ATTEMPTS", Default: "5"},
+		certificate.Setting{Key: "QUEUE_ACK_WAIT", Default: "2m"},
+		certificate.Setting{Key: "SHEETS_RANGE", Default: "the first sheet"},
+		certificate.Setting{Key: "SHEETS_WRITEBACK_COLUMN"},
+		certificate.Setting{Key: "GOOGLE_APPLICATION_CREDENTIALS"},
+		certificate.Setting{Key: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+		certificate.Setting{Key: "OTEL_SERVICE_NAME", Default: "certgen"},
+	)
+	settings = append(settings, deliverySettings...)
+	for _, st := range settings {
+		v := c.setting(st.Key)
+		v.isBool = st.Bool
+		usage := "override " + st.Key
+		if st.Default != "" {
+			usage += " (default " + st.Default + ")"
+		}
+		fset.Var(v, strings.ToLower(strings.ReplaceAll(st.Key, "_", "-")), usage)
+	}
+}
+
+// settingValue is a flag that sets a configuration key in the overrides
+// layer, the highest-precedence configuration source.
+type settingValue struct {
+	c      *cli
+	key    string
+	isBool bool
+}
+
+func (c *cli) setting(key string) *settingValue {
+	return &settingValue{c: c, key: key}
+}
+
+func (v *settingValue) String() string {
+	if v == nil || v.c == nil {
+		return ""
+	}
+	return v.c.overrides[v.key]
+}
+
+func (v *settingValue) Set(s string) error {
+	v.c.overrides[v.key] = s
+	return nil
+}
+
+func (v *settingValue) IsBoolFlag() bool { return v.isBool }
+
+// parse parses args and loads the configuration source and logger. nargs
+// lists the accepted numbers of positional arguments; without it any number
+// is accepted.
+func (c *cli) parse(fset *flag.FlagSet, args []string, nargs ...int) error {
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if c.quiet && c.verbose {
+		return errors.New("-quiet and -verbose are mutually exclusive")
+	}
+	ok := len(nargs) == 0
+	for _, n := range nargs {
+		ok = ok || fset.NArg() == n
+	}
+	if !ok {
+		fset.Usage()
+		return errors.New("wrong number of arguments")
+	}
+
+	src, err := c.source()
+	if err != nil {
+		return err
+	}
+	c.src, c.base = src, src
+	if c.tenantName != "" {
+		t, err := tenant.Find(c.tenantsDir(), c.tenantName)
+		if err != nil {
+			return err
+		}
+		c.tenant = &t
+		c.src = t.Source(src)
+	}
+	c.logger, err = newLogger(c.src, c.quiet, c.verbose)
+	return err
+}
+
+// tenantsDir resolves $TENANTS_DIR.
+func (c *cli) tenantsDir() string {
+	if v, ok := c.base("TENANTS_DIR"); ok {
+		return v
+	}
+	return "tenants"
+}
+
+// source layers flags over the process environment over the .env file. The
+// file is re-read on every call, so servers can pick up edits.
+func (c *cli) source() (certificate.Source, error) {
+	vals, err := godotenv.Read(c.envFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading %s: %w", c.envFile, err)
+	}
+	return certificate.Layered(
+		certificate.MapSource(c.overrides),
+		os.LookupEnv,
+		certificate.MapSource(vals),
+	), nil
+}
+
+// lookup returns a setting that is not part of the generator config, such
+// as OUTPUT_DIR, or def.
+func (c *cli) lookup(key, def string) string {
+	return lookupIn(c.src, key, def)
+}
+
+// generator validates the configuration and builds a Generator. Nothing is
+// produced if any setting is invalid.
+func (c *cli) generator() (*certificate.Generator, error) {
+	return newGenerator(c.src, c.logger)
+}
+
+func newGenerator(src certificate.Source, logger *slog.Logger) (*certificate.Generator, error) {
+	cfg, err := certificate.LoadConfig(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return certificate.New(cfg, certificate.WithLogger(logger))
+}
+
+// outputDir resolves -out, $OUTPUT_DIR or the default, and the tenant's
+// directory within it with -tenant.
+func (c *cli) outputDir() string {
+	if c.tenant != nil {
+		return c.tenant.OutputDir(c.baseOutputDir())
+	}
+	return c.baseOutputDir()
+}
+
+func (c *cli) baseOutputDir() string {
+	if v, ok := c.base("OUTPUT_DIR"); ok {
+		return v
+	}
+	return "output"
+}
+
+// registryPath resolves $REGISTRY_PATH, which defaults to registry.jsonl in
+// the output directory. Tenants never share the deployment's registry.
+func (c *cli) registryPath() string {
+	if c.tenant != nil {
+		return c.tenant.RegistryPath(c.baseOutputDir())
+	}
+	return c.lookup("REGISTRY_PATH", filepath.Join(c.outputDir(), "registry.jsonl"))
+}
+
+// openRegistry opens the registry at registryPath.
+func (c *cli) openRegistry() (*registry.Registry, error) {
+	return openRegistry(c.registryPath())
+}
+
+func openRegistry(path string) (*registry.Registry, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return registry.Open(path)
+}
+
+// sampleRecord returns the record named on the command line, or a sample.
+func sampleRecord(fset *flag.FlagSet) certificate.Record {
+	if fset.NArg() == 2 {
+		return certificate.Record{Name: fset.Arg(0), RegNumber: fset.Arg(1)}
+	}
+	return certificate.Record{Name: "Jane Q. Sample", RegNumber: "SAMPLE-0001"}
+}
+
+func cmdHelp(c *cli, args []string) error {
+	if len(args) == 0 {
+		c.usage(c.stdout)
+		return nil
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok || cmd.name == "help" {
+		c.usage(c.stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	// Every command prints its usage, flags included, for -h.
+	c.stderr = c.stdout
+	err := cmd.run(c, []string{"-h"})
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// newLogger honours LOG_LEVEL/LOG_FORMAT; quiet raises the level so only
+// errors reach stderr, verbose lowers it to debug.
+func newLogger(src certificate.Source, quiet, verbose bool) (*slog.Logger, error) {
+	level, _ := src("LOG_LEVEL")
+	format, _ := src("LOG_FORMAT")
+	switch {
+	case quiet:
+		level = "error"
+	case verbose:
+		level = "debug"
+	}
+	return certificate.NewLogger(os.Stderr, level, format)
+}
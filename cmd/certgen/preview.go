@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// previewPollInterval is how often the config file and template image are
+// checked for changes.
+const previewPollInterval = 500 * time.Millisecond
+
+// previewServer renders a sample certificate with the current configuration
+// on every request and tells the browser when its inputs change.
+type previewServer struct {
+	envFile string
+	source  func() (certificate.Source, error)
+	rec     certificate.Record
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	version int
+	stamps  map[string]fileStamp
+	lastErr string
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+func cmdPreview(c *cli, args []string) error {
+	fset := c.flags("preview")
+	addr := fset.String("addr", "localhost:8080", "listen `address`")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0, 2); err != nil {
+		return err
+	}
+
+	p := &previewServer{
+		envFile: c.envFile,
+		source:  c.source,
+		rec:     sampleRecord(fset),
+		logger:  c.logger,
+		stamps:  map[string]fileStamp{},
+	}
+	return p.run(*addr)
+}
+
+func (p *previewServer) run(addr string) error {
+	p.poll()
+	go func() {
+		for range time.Tick(previewPollInterval) {
+			p.poll()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", p.handleIndex)
+	mux.HandleFunc("GET /preview.pdf", p.handlePDF)
+	mux.HandleFunc("GET /status", p.handleStatus)
+
+	p.logger.Info("preview server listening", "url", "http://"+addr+"/", "config", p.envFile)
+	return http.ListenAndServe(addr, mux)
+}
+
+// generator builds a Generator from the config file as it is right now.
+func (p *previewServer) generator() (*certificate.Generator, error) {
+	src, err := p.source()
+	if err != nil {
+		return nil, err
+	}
+	return newGenerator(src, p.logger)
+}
+
+// poll bumps the version whenever the config file or the template image it
+// points at changes, so open browser tabs reload.
+func (p *previewServer) poll() {
+	watched := []string{p.envFile}
+	if src, err := p.source(); err == nil {
+		if tmpl, ok := src("TEMPLATE_IMAGE"); ok {
+			watched = append(watched, tmpl)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := len(watched) != len(p.stamps)
+	next := make(map[string]fileStamp, len(watched))
+	for _, path := range watched {
+		var st fileStamp
+		if fi, err := os.Stat(path); err == nil {
+			st = fileStamp{fi.ModTime(), fi.Size()}
+		}
+		next[path] = st
+		if prev, ok := p.stamps[path]; !ok || prev != st {
+			changed = true
+		}
+	}
+	p.stamps = next
+	if changed {
+		p.version++
+		p.logger.Debug("preview inputs changed", "version", p.version)
+	}
+}
+
+func (p *previewServer) handlePDF(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	gen, err := p.generator()
+	if err == nil {
+		_, err = gen.Render(&buf, p.rec)
+	}
+
+	p.mu.Lock()
+	p.lastErr = ""
+	if err != nil {
+		p.lastErr = err.Error()
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		p.logger.Warn("preview render failed", "err", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+func (p *previewServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	status := struct {
+		Version int    `json:"version"`
+		Error   string `json:"error,omitempty"`
+	}{p.version, p.lastErr}
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (p *previewServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, previewPage)
+}
+
+const previewPage = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>certgen preview</title>
+<style>
+  html, body { margin: 0; height: 100%; font-family: sans-serif; }
+  #bar { padding: 6px 10px; background: #222; color: #ddd; font-size: 13px; }
+  #err { color: #ff7070; white-space: pre-wrap; }
+  iframe { border: 0; width: 100%; height: calc(100% - 32px); }
+</style>
+</head>
+<body>
+<div id="bar">certgen preview — <span id="ver"></span> <span id="err"></span></div>
+<iframe id="pdf" src="preview.pdf"></iframe>
+<script>
+let version = null;
+async function check() {
+  try {
+    const s = await (await fetch("status")).json();
+    if (version !== null && s.version !== version) {
+      document.getElementById("pdf").src = "preview.pdf?v=" + s.version;
+    }
+    version = s.version;
+    document.getElementById("ver").textContent = "revision " + s.version;
+    document.getElementById("err").textContent = s.error || "";
+  } catch (e) {
+    document.getElementById("err").textContent = "server unreachable";
+  }
+}
+check();
+setInterval(check, 1000);
+</script>
+</body>
+</html>
+`
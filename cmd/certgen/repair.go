@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/batch"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+func cmdRepair(c *cli, args []string) error {
+	fset := c.flags("repair")
+	c.settingFlags(fset)
+	input := fset.String("input", "", "batch `file` the certificates were issued from, for the extra fields the registry does not keep")
+	revoked := fset.Bool("revoked", false, "repair revoked certificates too")
+	dryRun := fset.Bool("dry-run", false, "list the missing and altered PDFs without regenerating them")
+	if err := c.parse(fset, args); err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var entries []registry.Entry
+	if fset.NArg() > 0 {
+		for _, reg := range fset.Args() {
+			e, ok := iss.Registry.Get(reg)
+			if !ok {
+				return fmt.Errorf("%w: %s", registry.ErrNotFound, reg)
+			}
+			entries = append(entries, e)
+		}
+	} else {
+		for _, e := range iss.Registry.All() {
+			if *revoked || !e.Revoked() {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	// Only damaged certificates are regenerated
+	type damaged struct {
+		entry  registry.Entry
+		damage string
+	}
+	var todo []damaged
+	for _, e := range entries {
+		damage, err := issuer.Inspect(e)
+		if err != nil {
+			return fmt.Errorf("certificate %s: %w", e.RegNumber, err)
+		}
+		if damage != "" {
+			todo = append(todo, damaged{e, damage})
+		}
+	}
+	c.logger.Info("registry checked", "certificates", len(entries), "damaged", len(todo))
+
+	var fields map[string]map[string]string
+	if *input != "" && len(todo) > 0 && !*dryRun {
+		if fields, err = c.inputFields(*input, gen.Config().Location()); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	enc := json.NewEncoder(c.stdout)
+	failed := 0
+	for _, d := range todo {
+		if *dryRun {
+			switch {
+			case c.jsonOut:
+				enc.Encode(issuer.Repair{RegNumber: d.entry.RegNumber, Path: d.entry.Path, Damage: d.damage})
+			case !c.quiet:
+				fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", d.entry.RegNumber, d.damage, d.entry.Path)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		f := fields[d.entry.RegNumber]
+		if fields != nil && f == nil {
+			c.logger.Warn("not in the input file, regenerating without its fields", "reg_number", d.entry.RegNumber, "input", *input)
+		}
+		rep := iss.Repair(ctx, d.entry, d.damage, f)
+		if rep.Warning != "" {
+			c.logger.Warn("repairing with another configuration", "reg_number", rep.RegNumber, "warning", rep.Warning)
+		}
+		outcome := "restored"
+		switch {
+		case rep.Error != "":
+			failed++
+			outcome = "failed"
+			c.logger.Error("repair failed", "reg_number", rep.RegNumber, "err", rep.Error)
+		case !rep.Restored:
+			outcome = "changed"
+			c.logger.Warn("regenerated PDF differs from the one issued; registry updated",
+				"reg_number", rep.RegNumber, "sha256", rep.SHA256)
+		}
+		switch {
+		case c.jsonOut:
+			enc.Encode(rep)
+		case !c.quiet:
+			fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\n", rep.RegNumber, rep.Damage, outcome, rep.Path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d certificates could not be repaired", failed, len(todo))
+	}
+	return ctx.Err()
+}
+
+// inputFields reads the extra fields of every record in the batch input
+// arg, by registration number.
+func (c *cli) inputFields(arg string, loc *time.Location) (map[string]map[string]string, error) {
+	in, _, err := c.openInput(arg, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	fields := map[string]map[string]string{}
+	for {
+		rec, err := in.Next()
+		if errors.Is(err, io.EOF) {
+			return fields, nil
+		}
+		var rowErr *batch.RowError
+		if errors.As(err, &rowErr) {
+			continue // the rows that were issued were readable
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.RegNumber != "" && rec.Fields != nil {
+			fields[rec.RegNumber] = rec.Fields
+		}
+	}
+}
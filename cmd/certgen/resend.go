@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+func cmdResend(c *cli, args []string) error {
+	fset := c.flags("resend")
+	c.settingFlags(fset)
+	statuses := fset.String("status", registry.DeliveryFailed+","+registry.DeliveryBounced,
+		"without REG_NUMBER, resend certificates whose delivery `status` is one of these")
+	sink := fset.String("sink", "email", "delivery `sink` to send through again")
+	to := fset.String("to", "", "send to this `address` instead; only with one REG_NUMBER")
+	dryRun := fset.Bool("dry-run", false, "list the certificates and recipients without sending")
+	if err := c.parse(fset, args); err != nil {
+		return err
+	}
+	if *to != "" && fset.NArg() != 1 {
+		return errors.New("-to needs exactly one REG_NUMBER")
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	targets := fset.Args()
+	if len(targets) == 0 {
+		want := strings.Split(*statuses, ",")
+		for _, e := range iss.Registry.All() {
+			d, ok := e.Deliveries[*sink]
+			if ok && !e.Revoked() && slices.Contains(want, d.Status) {
+				targets = append(targets, e.RegNumber)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	enc := json.NewEncoder(c.stdout)
+	failed := 0
+	for _, reg := range targets {
+		if *dryRun {
+			e, _ := iss.Registry.Get(reg)
+			d := e.Deliveries[*sink]
+			if *to != "" {
+				d.To = *to
+			}
+			if !c.quiet {
+				fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", reg, d.To, d.Status)
+			}
+			continue
+		}
+
+		d, err := iss.Resend(ctx, reg, *sink, *to)
+		if err != nil {
+			failed++
+			c.logger.Error("resend failed", "reg_number", reg, "sink", *sink, "err", err)
+		}
+		switch {
+		case c.jsonOut:
+			enc.Encode(struct {
+				RegNumber string `json:"reg_number"`
+				issuer.Delivery
+			}{reg, d})
+		case !c.quiet && err == nil:
+			fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", reg, d.To, d.Location)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d certificates could not be resent", failed, len(targets))
+	}
+	return nil
+}
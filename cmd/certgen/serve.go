@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/auth"
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/metrics"
+	"github.com/Sathimantha/certificate_generator_go/internal/server"
+	"github.com/Sathimantha/certificate_generator_go/internal/tenant"
+)
+
+func cmdServe(c *cli, args []string) error {
+	fset := c.flags("serve")
+	addr := fset.String("addr", "localhost:8080", "listen `address`")
+	noAuth := fset.Bool("no-auth", false, "serve without API keys (only on trusted networks)")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0); err != nil {
+		return err
+	}
+
+	limits, err := c.limits()
+	if err != nil {
+		return err
+	}
+	timeout, err := c.shutdownTimeout()
+	if err != nil {
+		return err
+	}
+	idemTTL, err := time.ParseDuration(c.lookup("IDEMPOTENCY_TTL", server.DefaultIdempotencyTTL.String()))
+	if err != nil {
+		return fmt.Errorf("IDEMPOTENCY_TTL: %w", err)
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	tenants, keys, closeTenants, err := c.tenantIssuers()
+	if err != nil {
+		return err
+	}
+	defer closeTenants()
+
+	opts := []server.Option{
+		server.WithReloader(c.reloader(iss, tenants)),
+		server.WithMetrics(metrics.New()),
+		server.WithLimits(limits),
+		server.WithTenants(tenants),
+		server.WithIdempotencyTTL(idemTTL),
+	}
+	baseKeys, err := c.apiKeys()
+	if err == nil {
+		keys, err = baseKeys.Merge(keys)
+	}
+	switch {
+	case err != nil:
+		return err
+	case keys.Len() > 0:
+		opts = append(opts, server.WithAuth(keys))
+	case !*noAuth:
+		return errors.New("no API keys configured: set API_KEYS or API_KEYS_FILE, or pass --no-auth")
+	default:
+		c.logger.Warn("serving without authentication")
+	}
+
+	c.logger.Info("api server listening", "addr", *addr, "output", iss.OutputDir,
+		"tenants", len(tenants), "api_keys", keys.Len())
+	api := server.New(iss, c.logger, opts...)
+	go reloadOnHangup(c, api)
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return serveGracefully(c, srv, timeout)
+}
+
+// reloader rebuilds the served issuers from the .env and tenant files as
+// they are now, so layout and delivery fixes go live without a restart.
+// Each keeps the registry and output directory it was started with, and
+// the set of tenants and API keys is fixed at startup.
+func (c *cli) reloader(iss *issuer.Issuer, tenants map[string]*issuer.Issuer) server.Reloader {
+	return func() (*issuer.Issuer, map[string]*issuer.Issuer, error) {
+		base, err := c.source()
+		if err != nil {
+			return nil, nil, err
+		}
+		src := base
+		if c.tenant != nil {
+			t, err := tenant.Find(c.tenantsDir(), c.tenantName)
+			if err != nil {
+				return nil, nil, err
+			}
+			src = t.Source(base)
+		}
+		next, err := reissuer(src, c.logger, iss)
+		if err != nil {
+			return nil, nil, err
+		}
+		nextTenants := make(map[string]*issuer.Issuer, len(tenants))
+		for name, old := range tenants {
+			t, err := tenant.Find(c.tenantsDir(), name)
+			if err == nil {
+				nextTenants[name], err = reissuer(t.Source(base), c.logger.With("tenant", name), old)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("tenant %s: %w", name, err)
+			}
+		}
+		return next, nextTenants, nil
+	}
+}
+
+// reissuer builds an issuer for src that replaces old.
+func reissuer(src certificate.Source, logger *slog.Logger, old *issuer.Issuer) (*issuer.Issuer, error) {
+	gen, err := newGenerator(src, logger)
+	if err != nil {
+		return nil, err
+	}
+	return newIssuer(src, gen, old.Registry, old.OutputDir)
+}
+
+// reloadOnHangup reloads the configuration of api on every SIGHUP.
+func reloadOnHangup(c *cli, api *server.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		c.logger.Info("SIGHUP received, reloading configuration")
+		if err := api.Reload(); err != nil {
+			c.logger.Error("configuration reload failed, keeping the current one", "err", err)
+		}
+	}
+}
+
+// tenantIssuers builds an issuer for every tenant in $TENANTS_DIR, each
+// with its own output directory and registry, and collects the API keys
+// the tenant files define. Nothing is loaded with -tenant, which serves
+// that one tenant as the default, or when the default directory is absent.
+func (c *cli) tenantIssuers() (issuers map[string]*issuer.Issuer, keys *auth.Keyring, done func(), err error) {
+	issuers, keys = map[string]*issuer.Issuer{}, &auth.Keyring{}
+	done = func() {
+		for _, iss := range issuers {
+			iss.Registry.Close()
+		}
+	}
+
+	dir := c.tenantsDir()
+	if _, explicit := c.base("TENANTS_DIR"); c.tenant != nil || !explicit && !exists(dir) {
+		return issuers, keys, done, nil
+	}
+	tenants, err := tenant.Load(dir, c.baseOutputDir(), c.registryPath())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, t := range tenants {
+		iss, tkeys, err := c.tenantIssuer(t)
+		if err == nil {
+			issuers[t.Name] = iss
+			keys, err = keys.Merge(tkeys)
+		}
+		if err != nil {
+			done()
+			return nil, nil, nil, fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+	}
+	return issuers, keys, done, nil
+}
+
+// tenantIssuer builds the issuer for t and returns the API keys bound to it.
+func (c *cli) tenantIssuer(t tenant.Tenant) (*issuer.Issuer, *auth.Keyring, error) {
+	keys, err := auth.ParseString(t.Values["API_KEYS"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("API_KEYS: %w", err)
+	}
+	gen, err := newGenerator(t.Source(c.base), c.logger.With("tenant", t.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := t.OutputDir(c.baseOutputDir())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	reg, err := openRegistry(t.RegistryPath(c.baseOutputDir()))
+	if err != nil {
+		return nil, nil, err
+	}
+	iss, err := newIssuer(t.Source(c.base), gen, reg, dir)
+	if err != nil {
+		reg.Close()
+		return nil, nil, err
+	}
+	return iss, keys.ForTenant(t.Name), nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// apiKeys loads the keys in $API_KEYS and the file named by $API_KEYS_FILE.
+func (c *cli) apiKeys() (*auth.Keyring, error) {
+	keys, err := auth.ParseString(c.lookup("API_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("API_KEYS: %w", err)
+	}
+	path := c.lookup("API_KEYS_FILE", "")
+	if path == "" {
+		return keys, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("API_KEYS_FILE: %w", err)
+	}
+	defer f.Close()
+	fromFile, err := auth.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys.Merge(fromFile)
+}
+
+// limits reads the server's rate limits and concurrency cap. Generations
+// default to one per CPU, which keeps memory bounded under bursts.
+func (c *cli) limits() (server.Limits, error) {
+	var errs []error
+	num := func(key, def string) float64 {
+		v, err := strconv.ParseFloat(c.lookup(key, def), 64)
+		if err != nil || v < 0 {
+			errs = append(errs, fmt.Errorf("%s: want a non-negative number, got %q", key, c.lookup(key, def)))
+		}
+		return v
+	}
+	l := server.Limits{
+		Rate:          num("RATE_LIMIT", "0"),
+		Burst:         int(num("RATE_LIMIT_BURST", "20")),
+		KeyRate:       num("KEY_RATE_LIMIT", "0"),
+		KeyBurst:      int(num("KEY_RATE_LIMIT_BURST", "10")),
+		MaxConcurrent: int(num("MAX_CONCURRENT_GENERATIONS", strconv.Itoa(runtime.NumCPU()))),
+	}
+	return l, errors.Join(errs...)
+}
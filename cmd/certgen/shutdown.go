@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownSignals ask certgen to stop after finishing the work in hand.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// shutdownTimeout resolves $SHUTDOWN_TIMEOUT, how long in-flight work may
+// take to finish after a shutdown signal.
+func (c *cli) shutdownTimeout() (time.Duration, error) {
+	v := c.lookup("SHUTDOWN_TIMEOUT", "30s")
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("SHUTDOWN_TIMEOUT: want a duration such as 30s, got %q", v)
+	}
+	return d, nil
+}
+
+// serveGracefully runs srv until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to timeout for in-flight requests, so no
+// generation is cut off mid-PDF. The caller closes registries afterwards.
+func serveGracefully(c *cli, srv *http.Server, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process
+
+	c.logger.Info("shutting down, draining in-flight requests", "timeout", timeout)
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	c.logger.Info("shutdown complete")
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var tracer = otel.Tracer("github.com/Sathimantha/certificate_generator_go/cmd/certgen")
+
+// setupTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set, e.g. to http://localhost:4318. shutdown flushes pending spans.
+// Without an endpoint tracing stays a no-op.
+func (c *cli) setupTracing() (shutdown func(), err error) {
+	endpoint := c.lookup("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if endpoint == "" {
+		return func() {}, nil
+	}
+
+	// As with the standard variable, the endpoint is a base URL that the
+	// traces path is appended to.
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT: %w", err)
+	}
+	u.Path = path.Join(u.Path, "v1/traces")
+	exp, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", c.lookup("OTEL_SERVICE_NAME", "certgen")),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	c.logger.Debug("exporting traces", "endpoint", endpoint)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			c.logger.Warn("flushing traces failed", "err", err)
+		}
+	}, nil
+}
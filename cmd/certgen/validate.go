@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// cmdValidate is the pre-flight check: it loads and validates the
+// configuration, then plans a record (the sample one if none is given) and
+// reports what would be generated without writing anything.
+func cmdValidate(c *cli, args []string) error {
+	fset := c.flags("validate")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0, 2); err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	plan, err := gen.Plan(sampleRecord(fset), c.outputDir())
+
+	switch {
+	case c.jsonOut:
+		out := struct {
+			certificate.Plan
+			Errors []string `json:"errors,omitempty"`
+		}{Plan: plan}
+		if err != nil {
+			out.Errors = strings.Split(err.Error(), "\n")
+		}
+		if err := json.NewEncoder(c.stdout).Encode(out); err != nil {
+			return err
+		}
+	case !c.quiet:
+		action := "would write"
+		if plan.Skip {
+			action = "would skip existing"
+		}
+		fmt.Fprintf(c.stdout, "%s %s\n", action, plan.OutputPath)
+		fmt.Fprintf(c.stdout, "  page:       %.2fx%.2f mm\n", plan.PageWidth, plan.PageHeight)
+		fmt.Fprintf(c.stdout, "  verify url: %s\n", plan.VerifyURL)
+		for _, w := range plan.Warnings {
+			fmt.Fprintf(c.stdout, "  warning:    %s\n", w)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("validation found problems:\n%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+func cmdVerify(c *cli, args []string) error {
+	fset := c.flags("verify")
+	fset.Var(c.setting("REGISTRY_PATH"), "registry-path", "override REGISTRY_PATH (default <output dir>/registry.jsonl)")
+	if err := c.parse(fset, args, 1, 2); err != nil {
+		return err
+	}
+
+	reg, err := c.openRegistry()
+	if err != nil {
+		return err
+	}
+	defer reg.Close()
+
+	var sum string
+	if fset.NArg() == 2 {
+		if sum, err = certificate.FileSHA256(fset.Arg(1)); err != nil {
+			return err
+		}
+	}
+	v := reg.Verify(fset.Arg(0), sum)
+
+	switch {
+	case c.jsonOut:
+		if err := json.NewEncoder(c.stdout).Encode(v); err != nil {
+			return err
+		}
+	case !c.quiet:
+		fmt.Fprintf(c.stdout, "%s: %s\n", v.RegNumber, v.Status)
+		if e := v.Entry; e != nil {
+			fmt.Fprintf(c.stdout, "  name:   %s\n  issued: %s\n", e.Name, e.IssuedAt.Format(time.DateOnly))
+			if e.ExpiresAt != nil {
+				fmt.Fprintf(c.stdout, "  expires: %s\n", e.ExpiresAt.Format(time.DateOnly))
+			}
+			if e.Revoked() {
+				fmt.Fprintf(c.stdout, "  revoked: %s %s\n", e.RevokedAt.Format(time.DateOnly), e.RevokeReason)
+			}
+			for _, sink := range slices.Sorted(maps.Keys(e.Deliveries)) {
+				d := e.Deliveries[sink]
+				fmt.Fprintf(c.stdout, "  %s: %s %s %s\n", sink, d.Status, d.To, d.Error)
+			}
+		}
+	}
+	if !v.Valid() {
+		return fmt.Errorf("certificate %s is %s", v.RegNumber, v.Status)
+	}
+	return nil
+}
+
+func cmdRevoke(c *cli, args []string) error {
+	fset := c.flags("revoke")
+	fset.Var(c.setting("REGISTRY_PATH"), "registry-path", "override REGISTRY_PATH (default <output dir>/registry.jsonl)")
+	reason := fset.String("reason", "", "reason recorded with the revocation")
+	if err := c.parse(fset, args, 1); err != nil {
+		return err
+	}
+
+	reg, err := c.openRegistry()
+	if err != nil {
+		return err
+	}
+	defer reg.Close()
+
+	e, err := reg.Revoke(fset.Arg(0), *reason, time.Now())
+	if err != nil {
+		return err
+	}
+	c.logger.Info("certificate revoked", "reg_number", e.RegNumber, "reason", e.RevokeReason)
+	if c.jsonOut {
+		return json.NewEncoder(c.stdout).Encode(e)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/batch"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// watchedExts are the batch files an inbox picks up.
+var watchedExts = map[string]bool{".csv": true, ".xlsx": true, ".json": true, ".jsonl": true, ".ndjson": true}
+
+func cmdWatch(c *cli, args []string) error {
+	fset := c.flags("watch")
+	interval := fset.Duration("interval", 5*time.Second, "how often to look for new files")
+	processedDir := fset.String("processed", "", "move files whose every row was issued to `directory` (default INBOX/processed)")
+	failedDir := fset.String("failed", "", "move files with failed rows, or that cannot be read, to `directory` (default INBOX/failed)")
+	reportsDir := fset.String("reports", "", "write a CSV report per file to `directory` (default INBOX/reports)")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 1); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval: must be positive, got %s", *interval)
+	}
+
+	inbox := fset.Arg(0)
+	if st, err := os.Stat(inbox); err != nil {
+		return err
+	} else if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", inbox)
+	}
+	w := &watcher{c: c, inbox: inbox, processed: *processedDir, failed: *failedDir, reports: *reportsDir}
+	for dir, def := range map[*string]string{&w.processed: "processed", &w.failed: "failed", &w.reports: "reports"} {
+		if *dir == "" {
+			*dir = filepath.Join(inbox, def)
+		}
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+	w.iss = iss
+
+	// A shutdown signal stops the file in hand between records; it stays in
+	// the inbox and is picked up again on the next start.
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	context.AfterFunc(ctx, stop) // a second signal kills the process
+
+	c.logger.Info("watching inbox", "dir", inbox, "interval", *interval,
+		"processed", w.processed, "failed", w.failed, "reports", w.reports)
+	tick := time.NewTicker(*interval)
+	defer tick.Stop()
+	for {
+		if err := w.scan(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			c.logger.Info("watch stopped")
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// watcher processes the batch files dropped into an inbox directory.
+type watcher struct {
+	c                          *cli
+	iss                        *issuer.Issuer
+	inbox                      string
+	processed, failed, reports string
+	seen                       map[string]fileState
+}
+
+// fileState is what a scan saw of a file. A file is only processed once a
+// scan sees it unchanged, so one still being copied in is left alone.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// scan processes the files of the inbox that have settled since the last
+// scan.
+func (w *watcher) scan(ctx context.Context) error {
+	entries, err := os.ReadDir(w.inbox)
+	if err != nil {
+		return err
+	}
+	seen := map[string]fileState{}
+	for _, e := range entries {
+		name := e.Name()
+		// Skips hidden files and the lock files Excel leaves beside open
+		// workbooks
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~$") ||
+			!watchedExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed since the listing
+		}
+		st := fileState{info.Size(), info.ModTime()}
+		if prev, ok := w.seen[name]; !ok || prev != st {
+			seen[name] = st
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := w.process(ctx, name); err != nil {
+			return err
+		}
+	}
+	w.seen = seen
+	return nil
+}
+
+// process issues the certificates of the inbox file name, writes its report
+// and moves it out of the inbox. Only a failure to move it is returned:
+// left in the inbox, it would be issued again.
+func (w *watcher) process(ctx context.Context, name string) error {
+	c := w.c
+	path := filepath.Join(w.inbox, name)
+	// Timestamped, so files dropped again under the same name stay apart
+	stamped := time.Now().Format("20060102-150405") + "-" + name
+	base := strings.TrimSuffix(stamped, filepath.Ext(stamped))
+	c.logger.Info("processing inbox file", "file", name)
+
+	in, err := batch.Open(path, w.iss.Gen.Config().Location())
+	if err != nil {
+		c.logger.Error("inbox file cannot be read", "file", name, "err", err)
+		if werr := os.WriteFile(filepath.Join(w.reports, base+".error.txt"), []byte(err.Error()+"\n"), 0o644); werr != nil {
+			c.logger.Error("writing report", "file", name, "err", werr)
+		}
+		return w.move(path, w.failed, stamped)
+	}
+	reportPath := filepath.Join(w.reports, base+".csv")
+	report, err := batch.CreateReport(reportPath)
+	if err == nil {
+		var sum batch.Summary
+		history := batch.NewHistory(batch.HistoryPath(w.iss.OutputDir), filepath.Join(w.inbox, name), reportPath)
+		sum, _, err = c.issueAll(ctx, w.iss, in, batch.MultiReport(report, history), nil, nil)
+		if sum.Interrupted {
+			in.Close()
+			c.logger.Warn("inbox file interrupted, leaving it in the inbox", "file", name, "issued", sum.Succeeded)
+			return nil
+		}
+		if err == nil && sum.Failed > 0 {
+			err = fmt.Errorf("%d of %d certificates failed", sum.Failed, sum.Total)
+		}
+		c.logger.Info("inbox file finished", "file", name, "total", sum.Total,
+			"succeeded", sum.Succeeded, "failed", sum.Failed, "report", reportPath)
+	}
+	if cerr := in.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		c.logger.Error("inbox file failed", "file", name, "err", err)
+		return w.move(path, w.failed, stamped)
+	}
+	return w.move(path, w.processed, stamped)
+}
+
+func (w *watcher) move(path, dir, name string) error {
+	if err := os.Rename(path, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("moving %s out of the inbox: %w", filepath.Base(path), err)
+	}
+	return nil
+}
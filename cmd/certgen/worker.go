@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/metrics"
+	"github.com/Sathimantha/certificate_generator_go/internal/queue"
+)
+
+func cmdWorker(c *cli, args []string) error {
+	fset := c.flags("worker")
+	metricsAddr := fset.String("metrics-addr", "", "serve Prometheus metrics on `address`")
+	c.settingFlags(fset)
+	if err := c.parse(fset, args, 0); err != nil {
+		return err
+	}
+
+	maxAttempts, err := strconv.Atoi(c.lookup("QUEUE_MAX_ATTEMPTS", "5"))
+	if err != nil || maxAttempts < 1 {
+		return fmt.Errorf("QUEUE_MAX_ATTEMPTS: want a positive integer, got %q", c.lookup("QUEUE_MAX_ATTEMPTS", "5"))
+	}
+	ackWait, err := time.ParseDuration(c.lookup("QUEUE_ACK_WAIT", "2m"))
+	if err != nil {
+		return fmt.Errorf("QUEUE_ACK_WAIT: %w", err)
+	}
+	limits, err := c.limits()
+	if err != nil {
+		return err
+	}
+	backoff, err := retryPolicy(c.src)
+	if err != nil {
+		return err
+	}
+
+	gen, err := c.generator()
+	if err != nil {
+		return err
+	}
+	iss, done, err := c.issuer(gen)
+	if err != nil {
+		return err
+	}
+	defer done()
+	tenants, _, closeTenants, err := c.tenantIssuers()
+	if err != nil {
+		return err
+	}
+	defer closeTenants()
+
+	var m *metrics.Metrics
+	if *metricsAddr != "" {
+		m = metrics.New()
+		iss.OnResult = m.Observe
+		for _, t := range tenants {
+			t.OnResult = m.Observe
+		}
+		go func() {
+			if err := m.Serve(*metricsAddr); err != nil {
+				c.logger.Error("metrics server failed", "err", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	cfg := queue.NATSConfig{
+		URL:               c.lookup("QUEUE_URL", "nats://localhost:4222"),
+		Stream:            c.lookup("QUEUE_STREAM", "CERTGEN"),
+		Subject:           c.lookup("QUEUE_SUBJECT", "certgen.jobs"),
+		Consumer:          c.lookup("QUEUE_CONSUMER", "certgen"),
+		DeadLetterSubject: c.lookup("QUEUE_DEAD_LETTER_SUBJECT", "certgen.dead"),
+		AckWait:           ackWait,
+	}
+	src, err := queue.OpenNATS(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w := &queue.Worker{
+		Source: src,
+		Issuer: func(tenant string) (*issuer.Issuer, error) {
+			if tenant == "" {
+				return iss, nil
+			}
+			if t, ok := tenants[tenant]; ok {
+				return t, nil
+			}
+			return nil, fmt.Errorf("unknown tenant %q", tenant)
+		},
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		Concurrency: max(limits.MaxConcurrent, 1),
+		Logger:      c.logger,
+		Metrics:     m,
+	}
+	c.logger.Info("worker consuming", "url", cfg.URL, "stream", cfg.Stream, "subject", cfg.Subject,
+		"concurrency", w.Concurrency, "tenants", len(tenants))
+	err = w.Run(ctx)
+	c.logger.Info("worker stopped")
+	return err
+}
@@ -0,0 +1,242 @@
+// Package auth authenticates API requests with API keys that carry scopes.
+//
+// Keys are configured as "id:secret:scope,scope" entries, separated by
+// whitespace or semicolons in API_KEYS or one per line in API_KEYS_FILE.
+// The secret may be given as "sha256:HEX" so that only its hash is stored:
+//
+//	ci:s3cr3t:generate,verify
+//	ops:sha256:9f86d081884c7d65...:admin
+//
+// Clients send the secret as "Authorization: Bearer SECRET", in an
+// X-API-Key header, or as the password of HTTP Basic auth, for webhooks
+// that can only be given credentials in their URL.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scope is a permission granted to a key.
+type Scope string
+
+// Scopes. ScopeAdmin implies every other scope.
+const (
+	ScopeGenerate Scope = "generate"
+	ScopeVerify   Scope = "verify"
+	ScopeRevoke   Scope = "revoke"
+	ScopeWebhook  Scope = "webhook" // email provider notifications
+	ScopeAdmin    Scope = "admin"
+)
+
+var knownScopes = []Scope{ScopeGenerate, ScopeVerify, ScopeRevoke, ScopeWebhook, ScopeAdmin}
+
+// scopeList names knownScopes for messages, as "a, b or c".
+func scopeList() string {
+	names := make([]string, len(knownScopes))
+	for i, s := range knownScopes {
+		names[i] = string(s)
+	}
+	last := len(names) - 1
+	return strings.Join(names[:last], ", ") + " or " + names[last]
+}
+
+// Key is one configured API key. A key with a Tenant can only act for
+// that tenant.
+type Key struct {
+	ID     string
+	Scopes []Scope
+	Tenant string
+	hash   [sha256.Size]byte
+}
+
+// Allows reports whether k grants scope.
+func (k Key) Allows(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// Keyring is the set of keys accepted by the API. It is read-only after
+// parsing and safe for concurrent use.
+type Keyring struct {
+	keys []Key
+}
+
+// Parse reads key entries from r: one per line or separated by semicolons,
+// with blank lines and #-comments ignored.
+func Parse(r io.Reader) (*Keyring, error) {
+	kr := &Keyring{}
+	var errs []error
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		for _, entry := range strings.FieldsFunc(text, func(r rune) bool {
+			return r == ';' || r == ' ' || r == '\t'
+		}) {
+			k, err := parseKey(entry)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			if slices.ContainsFunc(kr.keys, func(o Key) bool { return o.ID == k.ID }) {
+				errs = append(errs, fmt.Errorf("line %d: duplicate key id %q", line, k.ID))
+				continue
+			}
+			kr.keys = append(kr.keys, k)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// ParseString is Parse for an inline value such as $API_KEYS.
+func ParseString(s string) (*Keyring, error) {
+	return Parse(strings.NewReader(s))
+}
+
+func parseKey(entry string) (Key, error) {
+	id, rest, ok := strings.Cut(entry, ":")
+	i := strings.LastIndex(rest, ":")
+	if !ok || id == "" || i <= 0 {
+		return Key{}, fmt.Errorf("key %q: want id:secret:scopes", id)
+	}
+	secret, scopes := rest[:i], rest[i+1:]
+
+	k := Key{ID: id}
+	if hexHash, ok := strings.CutPrefix(secret, "sha256:"); ok {
+		b, err := hex.DecodeString(hexHash)
+		if err != nil || len(b) != sha256.Size {
+			return Key{}, fmt.Errorf("key %q: sha256 secret must be 64 hex digits", id)
+		}
+		copy(k.hash[:], b)
+	} else {
+		k.hash = sha256.Sum256([]byte(secret))
+	}
+
+	for _, s := range strings.Split(scopes, ",") {
+		scope := Scope(strings.ToLower(strings.TrimSpace(s)))
+		if !slices.Contains(knownScopes, scope) {
+			return Key{}, fmt.Errorf("key %q: unknown scope %q (want %s)", id, s, scopeList())
+		}
+		k.Scopes = append(k.Scopes, scope)
+	}
+	return k, nil
+}
+
+// Merge returns a keyring holding the keys of both, or an error if an id
+// appears in both.
+func (kr *Keyring) Merge(other *Keyring) (*Keyring, error) {
+	out := &Keyring{keys: slices.Clone(kr.keys)}
+	for _, k := range other.keys {
+		if _, ok := out.byID(k.ID); ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		out.keys = append(out.keys, k)
+	}
+	return out, nil
+}
+
+// ForTenant returns a copy of kr with every key bound to tenant.
+func (kr *Keyring) ForTenant(tenant string) *Keyring {
+	out := &Keyring{keys: slices.Clone(kr.keys)}
+	for i := range out.keys {
+		out.keys[i].Tenant = tenant
+	}
+	return out
+}
+
+// Len returns the number of keys.
+func (kr *Keyring) Len() int {
+	return len(kr.keys)
+}
+
+func (kr *Keyring) byID(id string) (Key, bool) {
+	for _, k := range kr.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Lookup returns the key whose secret is secret. Every key is compared in
+// constant time so timing does not reveal which one nearly matched.
+func (kr *Keyring) Lookup(secret string) (Key, bool) {
+	sum := sha256.Sum256([]byte(secret))
+	var found Key
+	ok := false
+	for _, k := range kr.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash[:]) == 1 {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+// ── Middleware ──────────────────────────────────────────────────────────────
+
+type ctxKey struct{}
+
+// FromContext returns the key that authenticated the request, if any.
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(Key)
+	return k, ok
+}
+
+// Require wraps next so it only runs for requests bearing a key with scope.
+// It answers 401 without a valid key and 403 when the key lacks the scope.
+// A nil keyring disables authentication.
+func (kr *Keyring) Require(scope Scope, next http.Handler) http.Handler {
+	return kr.require(scope, `Bearer realm="certgen"`, next)
+}
+
+// RequireLogin is Require for pages people open in a browser: it challenges
+// for HTTP Basic auth, so the browser asks for the key as a password.
+func (kr *Keyring) RequireLogin(scope Scope, next http.Handler) http.Handler {
+	return kr.require(scope, `Basic realm="certgen", charset="UTF-8"`, next)
+}
+
+func (kr *Keyring) require(scope Scope, challenge string, next http.Handler) http.Handler {
+	if kr == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-API-Key")
+		if _, pass, ok := r.BasicAuth(); ok {
+			secret = pass
+		}
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			secret = strings.TrimSpace(bearer)
+		}
+		k, ok := kr.Lookup(secret)
+		switch {
+		case secret == "" || !ok:
+			w.Header().Set("WWW-Authenticate", challenge)
+			deny(w, http.StatusUnauthorized, "missing or invalid API key")
+		case !k.Allows(scope):
+			deny(w, http.StatusForbidden, fmt.Sprintf("API key %q lacks the %s scope", k.ID, scope))
+		default:
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, k)))
+		}
+	})
+}
+
+func deny(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
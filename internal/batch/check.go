@@ -0,0 +1,170 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// CheckOptions configures Check.
+type CheckOptions struct {
+	MaxFieldLength int // characters allowed in any one value; 0 for no limit
+	// Plan, if set, is asked about every well-formed record, e.g. whether
+	// its certificate can be laid out. Each line of its error is a problem.
+	Plan func(certificate.Record) error
+}
+
+// Problem is one reason a row would not be issued.
+type Problem struct {
+	Row       int    `json:"row"`
+	RegNumber string `json:"reg_number,omitempty"`
+	Field     string `json:"field,omitempty"`
+	Message   string `json:"problem"`
+}
+
+// Validation is the outcome of checking a whole batch before any of it is
+// issued.
+type Validation struct {
+	Rows     int       `json:"rows"`
+	Invalid  int       `json:"invalid"`
+	Problems []Problem `json:"problems"`
+
+	invalid map[int]string // problems by row
+}
+
+// Check reads every row of r and reports the problems that would fail or
+// spoil a row's certificate: malformed rows, empty names, reg numbers that
+// repeat an earlier row's, email fields that are not addresses and values
+// longer than opts.MaxFieldLength. Only a failure to read r is returned as
+// an error.
+func Check(r Reader, opts CheckOptions) (*Validation, error) {
+	v := &Validation{Problems: []Problem{}, invalid: map[int]string{}}
+	firstRow := map[string]int{} // reg numbers seen, by the row they were first on
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		v.Rows++
+		row := r.Row()
+		add := func(field, format string, args ...any) {
+			v.add(Problem{Row: row, RegNumber: rec.RegNumber, Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			add("", "%v", rowErr.Err)
+			continue
+		case err != nil:
+			return v, err
+		}
+
+		if first, ok := firstRow[rec.RegNumber]; ok {
+			add("reg_number", "reg_number %s repeats row %d", rec.RegNumber, first)
+		} else {
+			firstRow[rec.RegNumber] = row
+		}
+		if email := rec.Fields["email"]; email != "" {
+			if _, err := mail.ParseAddress(email); err != nil {
+				add("email", "email %q is not an address", email)
+			}
+		}
+		if opts.MaxFieldLength > 0 {
+			vals := map[string]string{"name": rec.Name, "reg_number": rec.RegNumber, "course": rec.Course}
+			maps.Copy(vals, rec.Fields)
+			for _, k := range slices.Sorted(maps.Keys(vals)) {
+				if n := utf8.RuneCountInString(vals[k]); n > opts.MaxFieldLength {
+					add(k, "%s is %d characters, over the limit of %d", k, n, opts.MaxFieldLength)
+				}
+			}
+		}
+		if opts.Plan != nil {
+			if err := opts.Plan(rec); err != nil {
+				for _, line := range strings.Split(err.Error(), "\n") {
+					add("", "%s", line)
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *Validation) add(p Problem) {
+	if _, ok := v.invalid[p.Row]; ok {
+		v.invalid[p.Row] += "; " + p.Message
+	} else {
+		v.Invalid++
+		v.invalid[p.Row] = p.Message
+	}
+	v.Problems = append(v.Problems, p)
+}
+
+// OK reports whether every row passed.
+func (v *Validation) OK() bool { return len(v.Problems) == 0 }
+
+// Valid reports whether row passed.
+func (v *Validation) Valid(row int) bool {
+	_, bad := v.invalid[row]
+	return !bad
+}
+
+// Skip reads r, a fresh read of the checked input, with each invalid row
+// turned into a *RowError carrying its problems, so only valid rows are
+// issued and the rest are reported as failed.
+func (v *Validation) Skip(r Reader) Reader {
+	return &skipReader{Reader: r, invalid: v.invalid}
+}
+
+type skipReader struct {
+	Reader
+	invalid map[int]string
+}
+
+func (s *skipReader) Next() (certificate.Record, error) {
+	rec, err := s.Reader.Next()
+	if err != nil {
+		return rec, err
+	}
+	if msg, ok := s.invalid[s.Row()]; ok {
+		return rec, &RowError{s.Row(), errors.New(msg)}
+	}
+	return rec, nil
+}
+
+// WriteFile writes the validation report to path: one CSV row per problem
+// for .csv, JSON otherwise.
+func (v *Validation) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return closeWriter(f, enc.Encode(v))
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"row", "reg_number", "field", "problem"})
+	for _, p := range v.Problems {
+		cw.Write([]string{strconv.Itoa(p.Row), p.RegNumber, p.Field, p.Message})
+	}
+	cw.Flush()
+	err = cw.Error()
+	if err == nil {
+		_, err = fmt.Fprintf(f, "# summary: rows=%d invalid=%d\n", v.Rows, v.Invalid)
+	}
+	return closeWriter(f, err)
+}
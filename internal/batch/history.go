@@ -0,0 +1,111 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// HistoryFile is the batch run history kept in an issuer's output
+// directory, beside its registry: one JSON line per run, appended as each
+// run finishes.
+const HistoryFile = "batches.jsonl"
+
+// HistoryPath is the batch run history of the output directory dir.
+func HistoryPath(dir string) string {
+	return filepath.Join(dir, HistoryFile)
+}
+
+// maxRunFailures bounds the failures a Run keeps; the run's report has
+// them all.
+const maxRunFailures = 50
+
+// Run is one batch run in the history.
+type Run struct {
+	Source string `json:"source"`           // the input file or spreadsheet
+	Report string `json:"report,omitempty"` // the run's report file, if it wrote one
+	Summary
+	// Failures are the first rows that failed, so a run can be looked
+	// into without its report.
+	Failures []RunFailure `json:"failures,omitempty"`
+}
+
+// RunFailure is a row of a Run that was not issued.
+type RunFailure struct {
+	Row       int    `json:"row"`
+	RegNumber string `json:"reg_number,omitempty"`
+	Error     string `json:"error"`
+}
+
+// NewHistory returns a ReportWriter that appends the run to the history
+// at path when it is closed. source and report name the run's input and
+// report file.
+func NewHistory(path, source, report string) ReportWriter {
+	return &history{path: path, run: Run{Source: source, Report: report}}
+}
+
+type history struct {
+	path string
+	run  Run
+}
+
+func (h *history) Write(row ReportRow) error {
+	if !row.OK() && len(h.run.Failures) < maxRunFailures {
+		h.run.Failures = append(h.run.Failures, RunFailure{Row: row.Row, RegNumber: row.RegNumber, Error: row.Error})
+	}
+	return nil
+}
+
+func (h *history) Close(s Summary) error {
+	h.run.Summary = s
+	b, err := json.Marshal(h.run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("batch history: %w", err)
+	}
+	_, err = f.Write(append(b, '\n'))
+	return closeWriter(f, err)
+}
+
+// ReadHistory returns the last limit runs of the history at path, newest
+// first; limit 0 returns them all. A history that does not exist yet has
+// no runs.
+func ReadHistory(path string, limit int) ([]Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Run
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("batch history %s line %d: %w", path, line, err)
+		}
+		runs = append(runs, r)
+		if limit > 0 && len(runs) > limit {
+			runs = runs[1:]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(runs)
+	return runs, nil
+}
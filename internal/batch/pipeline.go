@@ -0,0 +1,195 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// Item is one row that went through a Pipeline.
+type Item struct {
+	Row    int
+	Record certificate.Record
+	Result issuer.Result // zero for a malformed row
+	Err    *RowError     // why the row is malformed; it was not issued
+}
+
+// Pipeline issues the records of a Reader: one goroutine reads, Concurrency
+// issue, and the results come back in input order. What is in flight is
+// bounded, by Queue and by MaxMemory, so a batch of any size runs in about
+// the same memory; nothing is kept once Done has seen it.
+type Pipeline struct {
+	Concurrency int // records issued at once; 1 if zero
+	// Queue is how many records may be read ahead of the oldest one still
+	// being issued, including those in flight; 2×Concurrency if zero.
+	Queue int
+	// MaxMemory is the heap size, in bytes, above which reading pauses
+	// until issuing frees memory; 0 for no limit. It is also set as the
+	// Go runtime's soft memory limit while the pipeline runs.
+	MaxMemory int64
+
+	// Issue issues one well-formed record. It is called concurrently.
+	Issue func(context.Context, certificate.Record) issuer.Result
+	// Done receives every row in input order, one at a time. An error
+	// stops the pipeline.
+	Done func(Item) error
+	// Enqueue, if set, is called for every row read, and the function it
+	// returns once the row is done with. Between the two the row is read
+	// ahead or being issued, as a queue depth gauge counts it.
+	Enqueue func() (done func())
+}
+
+// Run reads in until its end, an error, or ctx is cancelled. Cancelling
+// ctx stops it between records: those being issued finish and are passed
+// to Done, those read ahead are not issued. interrupted reports whether ctx
+// stopped it before the end of in.
+func (p *Pipeline) Run(ctx context.Context, in Reader) (interrupted bool, err error) {
+	workers := max(p.Concurrency, 1)
+	queue := p.Queue
+	if queue <= 0 {
+		queue = 2 * workers
+	}
+	queue = max(queue, workers)
+	if p.MaxMemory > 0 {
+		prev := debug.SetMemoryLimit(p.MaxMemory)
+		defer debug.SetMemoryLimit(prev)
+	}
+
+	type job struct {
+		Item
+		done    chan struct{}
+		dropped bool   // read, but ctx was cancelled before it was issued
+		dequeue func() // from Enqueue
+	}
+	var (
+		jobs    = make(chan *job, queue)     // to the issuing goroutines
+		ordered = make(chan *job, queue)     // to Done, in input order
+		slots   = make(chan struct{}, queue) // one per job in flight
+		stop    = make(chan struct{})        // Done failed
+		// Set by the reader, read once it has closed ordered
+		readErr   error
+		cancelled bool
+	)
+
+	// Reader
+	go func() {
+		defer close(jobs)
+		defer close(ordered)
+		for {
+			if ctx.Err() != nil {
+				cancelled = true
+				return
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			p.waitMemory(ctx, slots)
+			rec, err := in.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			j := &job{Item: Item{Row: in.Row(), Record: rec}, done: make(chan struct{}), dequeue: func() {}}
+			if p.Enqueue != nil {
+				j.dequeue = p.Enqueue()
+			}
+			var rowErr *RowError
+			switch {
+			case errors.As(err, &rowErr):
+				j.Err = rowErr
+				close(j.done)
+			case err != nil:
+				readErr = err
+				j.dequeue()
+				return
+			default:
+				jobs <- j
+			}
+			ordered <- j
+		}
+	}()
+
+	// Issuers
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				// A cancelled batch stops between records
+				if ctx.Err() != nil {
+					j.dropped = true
+				} else {
+					j.Result = p.Issue(ctx, j.Record)
+				}
+				close(j.done)
+			}
+		}()
+	}
+
+	// Done, in input order
+	for j := range ordered {
+		<-j.done
+		if j.dropped {
+			interrupted = true
+		} else if err == nil {
+			if err = p.Done(j.Item); err != nil {
+				close(stop)
+			}
+		}
+		j.dequeue()
+		<-slots
+	}
+	wg.Wait()
+	interrupted = interrupted || cancelled
+	if err != nil {
+		return interrupted, err
+	}
+	return interrupted, readErr
+}
+
+// memoryPoll is how often a paused reader checks the heap again.
+const memoryPoll = 20 * time.Millisecond
+
+// waitMemory blocks while the heap is above MaxMemory and records are in
+// flight whose completion could bring it down. With nothing in flight but
+// the record about to be read, waiting would never end, so reading goes on.
+func (p *Pipeline) waitMemory(ctx context.Context, slots chan struct{}) {
+	if p.MaxMemory <= 0 {
+		return
+	}
+	collected := false
+	for heapBytes() > uint64(p.MaxMemory) && len(slots) > 1 && ctx.Err() == nil {
+		if !collected {
+			// What is over the limit may only be garbage
+			runtime.GC()
+			collected = true
+			continue
+		}
+		time.Sleep(memoryPoll)
+	}
+}
+
+// heapBytes is the memory occupied by heap objects, live or not yet swept.
+func heapBytes() uint64 {
+	s := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(s)
+	if s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s[0].Value.Uint64()
+}
@@ -0,0 +1,299 @@
+// Package batch reads recipient lists for batch generation.
+//
+// Input is CSV with a header row, an Excel workbook (.xlsx) whose first
+// worksheet has one, a JSON array of objects, or JSON Lines.
+// Column names are case-insensitive; "name" and "reg_number" are required,
+// "course", "issued_at" and "expires_at" are optional, and every other
+// column is passed through in Record.Fields.
+package batch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// Reader yields records one at a time, so arbitrarily large inputs are never
+// held in memory.
+type Reader interface {
+	// Next returns the next record, or io.EOF after the last one. A
+	// *RowError reports a malformed row; reading may continue after it.
+	Next() (certificate.Record, error)
+	// Row returns the 1-based data row of the record Next last returned,
+	// not counting a header row.
+	Row() int
+	Close() error
+}
+
+// RowError describes a row that could not be turned into a record.
+type RowError struct {
+	Row int // 1-based data row, not counting a CSV header
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Column aliases accepted for the well-known record fields.
+var aliases = map[string]string{
+	"name":                "name",
+	"full_name":           "name",
+	"reg_number":          "reg_number",
+	"reg":                 "reg_number",
+	"reg_no":              "reg_number",
+	"registration_number": "reg_number",
+	"course":              "course",
+	"issued_at":           "issued_at",
+	"issue_date":          "issued_at",
+	"date":                "issued_at",
+	"expires_at":          "expires_at",
+	"expires":             "expires_at",
+	"expiry":              "expires_at",
+	"expiry_date":         "expires_at",
+	"valid_until":         "expires_at",
+}
+
+// Open opens path, choosing the format by extension: .json, .jsonl and
+// .ndjson are JSON, .xlsx is Excel, anything else is CSV. "-" reads CSV
+// from stdin. Dates given without a zone are read in loc, the TIMEZONE
+// certificates are issued in; nil means the local time zone.
+func Open(path string, loc *time.Location) (Reader, error) {
+	if path == "-" {
+		return NewCSVReader(os.Stdin, loc)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		return openXLSX(path, loc)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Reader
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson":
+		r, err = NewJSONReader(f, loc)
+	default:
+		r, err = NewCSVReader(f, loc)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewCSVReader reads CSV with a header row from r, with dates without a
+// zone in loc. If r is an io.Closer it is closed by Close.
+func NewCSVReader(r io.Reader, loc *time.Location) (Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty input, expected a header row")
+		}
+		return nil, err
+	}
+	for i, h := range header {
+		header[i] = normalizeColumn(strings.TrimPrefix(h, "\ufeff"))
+	}
+	return &csvReader{src: r, r: cr, header: header, loc: loc}, nil
+}
+
+type csvReader struct {
+	src    io.Reader
+	r      *csv.Reader
+	header []string
+	row    int
+	loc    *time.Location
+}
+
+func (c *csvReader) Next() (certificate.Record, error) {
+	cols, err := c.r.Read()
+	if err == io.EOF {
+		return certificate.Record{}, io.EOF
+	}
+	c.row++
+	if err != nil {
+		return certificate.Record{}, &RowError{c.row, err}
+	}
+
+	vals := make(map[string]string, len(cols))
+	for i, v := range cols {
+		if i < len(c.header) && c.header[i] != "" {
+			vals[c.header[i]] = v
+		}
+	}
+	rec, err := toRecord(vals, c.loc)
+	if err != nil {
+		return rec, &RowError{c.row, err}
+	}
+	return rec, nil
+}
+
+func (c *csvReader) Row() int { return c.row }
+
+func (c *csvReader) Close() error {
+	if cl, ok := c.src.(io.Closer); ok && c.src != os.Stdin {
+		return cl.Close()
+	}
+	return nil
+}
+
+// NewJSONReader reads either a JSON array of objects or a stream of
+// objects (JSON Lines) from r, with dates without a zone in loc.
+func NewJSONReader(r io.Reader, loc *time.Location) (Reader, error) {
+	br := bufio.NewReader(r)
+	j := &jsonReader{src: r, dec: json.NewDecoder(br), loc: loc}
+
+	// Peek past whitespace to tell an array from a stream of objects.
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return j, nil
+			}
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			if _, err := j.dec.Token(); err != nil {
+				return nil, err
+			}
+		}
+		return j, nil
+	}
+}
+
+type jsonReader struct {
+	src io.Reader
+	dec *json.Decoder
+	row int
+	loc *time.Location
+}
+
+func (j *jsonReader) Next() (certificate.Record, error) {
+	if !j.dec.More() {
+		return certificate.Record{}, io.EOF
+	}
+	j.row++
+
+	var obj map[string]json.RawMessage
+	if err := j.dec.Decode(&obj); err != nil {
+		// The decoder cannot resynchronise after a syntax error.
+		return certificate.Record{}, fmt.Errorf("row %d: %w", j.row, err)
+	}
+
+	vals := make(map[string]string, len(obj))
+	for k, raw := range obj {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = strings.TrimSpace(string(raw)) // numbers, booleans
+			if s == "null" {
+				s = ""
+			}
+		}
+		vals[normalizeColumn(k)] = s
+	}
+	rec, err := toRecord(vals, j.loc)
+	if err != nil {
+		return rec, &RowError{j.row, err}
+	}
+	return rec, nil
+}
+
+func (j *jsonReader) Row() int { return j.row }
+
+func (j *jsonReader) Close() error {
+	if cl, ok := j.src.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+func normalizeColumn(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(s)
+	if canon, ok := aliases[s]; ok {
+		return canon
+	}
+	return s
+}
+
+// toRecord maps normalized columns onto a Record, reading dates without a
+// zone in loc.
+func toRecord(vals map[string]string, loc *time.Location) (certificate.Record, error) {
+	rec := certificate.Record{Fields: map[string]string{}}
+	var issued, expires string
+	for k, v := range vals {
+		v = strings.TrimSpace(v)
+		switch k {
+		case "name":
+			rec.Name = v
+		case "reg_number":
+			rec.RegNumber = v
+		case "course":
+			rec.Course = v
+		case "issued_at":
+			issued = v
+		case "expires_at":
+			expires = v
+		default:
+			rec.Fields[k] = v
+		}
+	}
+
+	// Parsed after the loop so a bad date still reports a complete record.
+	if issued != "" {
+		t, err := parseDate(issued, loc)
+		if err != nil {
+			return rec, fmt.Errorf("issued_at: %w", err)
+		}
+		rec.IssuedAt = t
+	}
+	if expires != "" {
+		t, err := parseDate(expires, loc)
+		if err != nil {
+			return rec, fmt.Errorf("expires_at: %w", err)
+		}
+		rec.ExpiresAt = t
+	}
+
+	switch {
+	case rec.Name == "":
+		return rec, errors.New("missing name")
+	case rec.RegNumber == "":
+		return rec, errors.New("missing reg_number")
+	}
+	return rec, nil
+}
+
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD or RFC 3339 date", s)
+}
@@ -0,0 +1,192 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// Summary holds the aggregate counts of a batch run.
+type Summary struct {
+	Total      int       `json:"total"`
+	Succeeded  int       `json:"succeeded"`
+	Failed     int       `json:"failed"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Interrupted is set when a shutdown signal stopped the batch before
+	// the end of its input.
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// ReportRow is one record's line in the report.
+type ReportRow struct {
+	Row int `json:"row"`
+	issuer.Result
+}
+
+// ReportWriter writes a batch report as results arrive, so reports of huge
+// batches are never held in memory. Close writes the summary.
+type ReportWriter interface {
+	Write(ReportRow) error
+	Close(Summary) error
+}
+
+// CreateReport creates a report file at path: CSV for .csv, JSON otherwise.
+func CreateReport(path string) (ReportWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return NewCSVReport(f), nil
+	}
+	return NewJSONReport(f), nil
+}
+
+// MultiReport writes every row to each of ws, skipping nil writers.
+func MultiReport(ws ...ReportWriter) ReportWriter {
+	var m multiReport
+	for _, w := range ws {
+		if w != nil {
+			m = append(m, w)
+		}
+	}
+	return m
+}
+
+type multiReport []ReportWriter
+
+func (m multiReport) Write(row ReportRow) error {
+	var errs []error
+	for _, w := range m {
+		errs = append(errs, w.Write(row))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiReport) Close(s Summary) error {
+	var errs []error
+	for _, w := range m {
+		errs = append(errs, w.Close(s))
+	}
+	return errors.Join(errs...)
+}
+
+// NewJSONReport writes {"results": [...], "summary": {...}} to w. If w is
+// an io.Closer it is closed by Close.
+func NewJSONReport(w io.Writer) ReportWriter {
+	return &jsonReport{w: w}
+}
+
+type jsonReport struct {
+	w io.Writer
+	n int
+}
+
+func (r *jsonReport) Write(row ReportRow) error {
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if r.n == 0 {
+		sep = "{\"results\": [\n  "
+	}
+	r.n++
+	_, err = fmt.Fprintf(r.w, "%s%s", sep, b)
+	return err
+}
+
+func (r *jsonReport) Close(s Summary) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	head := "\n],\n"
+	if r.n == 0 {
+		head = "{\"results\": [],\n"
+	}
+	_, err = fmt.Fprintf(r.w, "%s\"summary\": %s}\n", head, b)
+	return closeWriter(r.w, err)
+}
+
+// NewCSVReport writes one CSV row per record followed by "# summary"
+// comment lines. If w is an io.Closer it is closed by Close.
+func NewCSVReport(w io.Writer) ReportWriter {
+	cw := csv.NewWriter(w)
+	r := &csvReport{w: w, cw: cw}
+	r.err = cw.Write([]string{"row", "name", "reg_number", "status", "stage", "code", "path", "sha256", "verify_url", "duration_ms", "deliveries", "linkedin_url", "error"})
+	return r
+}
+
+type csvReport struct {
+	w   io.Writer
+	cw  *csv.Writer
+	err error
+}
+
+func (r *csvReport) Write(row ReportRow) error {
+	if r.err != nil {
+		return r.err
+	}
+	status := "ok"
+	switch {
+	case !row.OK():
+		status = "failed"
+	case row.Skipped:
+		status = "skipped"
+	}
+	r.err = r.cw.Write([]string{
+		strconv.Itoa(row.Row), row.Name, row.RegNumber, status, row.Stage, row.Code, row.Path, row.SHA256,
+		row.VerifyURL, strconv.FormatFloat(row.DurationMS, 'f', 3, 64), deliveries(row.Deliveries), row.LinkedIn, row.Error,
+	})
+	return r.err
+}
+
+func (r *csvReport) Close(s Summary) error {
+	r.cw.Flush()
+	err := r.err
+	if err == nil {
+		err = r.cw.Error()
+	}
+	if err == nil {
+		_, err = fmt.Fprintf(r.w, "# summary: total=%d succeeded=%d failed=%d started=%s finished=%s interrupted=%t\n",
+			s.Total, s.Succeeded, s.Failed, s.StartedAt.Format(time.RFC3339), s.FinishedAt.Format(time.RFC3339), s.Interrupted)
+	}
+	return closeWriter(r.w, err)
+}
+
+// deliveries summarises delivery outcomes as "email:ok upload:failed(3)",
+// with the attempt count when more than one was needed.
+func deliveries(ds []issuer.Delivery) string {
+	parts := make([]string, len(ds))
+	for i, d := range ds {
+		status := "ok"
+		if !d.OK {
+			status = "failed"
+		}
+		parts[i] = d.Sink + ":" + status
+		if d.Attempts > 1 {
+			parts[i] += "(" + strconv.Itoa(d.Attempts) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func closeWriter(w io.Writer, err error) error {
+	if c, ok := w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
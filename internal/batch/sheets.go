@@ -0,0 +1,287 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// sheetsAPI is the Google Sheets REST endpoint.
+const sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+// SheetConfig selects a Google Sheet to read recipients from.
+type SheetConfig struct {
+	SpreadsheetID string
+	// Range in A1 notation, e.g. "Enrolled!A1:F". The first row of the
+	// range is the header. Defaults to the first sheet.
+	Range string
+	// Credentials is a service-account JSON key file. Without it the
+	// application default credentials are used.
+	Credentials string
+	// WritebackColumn, e.g. "H", receives each row's status, and the
+	// column after it the certificate's location. Empty disables writing.
+	WritebackColumn string
+	// Location is the zone of dates given without one; nil means the
+	// local time zone.
+	Location *time.Location
+}
+
+// Sheet reads a Google Sheet through the Sheets API as a batch Reader. The
+// sheet must be shared with the service account.
+type Sheet struct {
+	cfg    SheetConfig
+	client *http.Client
+	ctx    context.Context
+
+	sheet    string // quoted sheet name from the resolved range
+	firstRow int    // spreadsheet row number of the header
+	header   []string
+	rows     [][]string
+	next     int // index into rows
+}
+
+// OpenSheet fetches the configured range. The whole range is read at once:
+// sheets are limited to a few million cells and one request is kinder to
+// API quotas than paging.
+func OpenSheet(ctx context.Context, cfg SheetConfig) (*Sheet, error) {
+	if cfg.SpreadsheetID == "" {
+		return nil, errors.New("missing spreadsheet ID")
+	}
+	if cfg.Range == "" {
+		cfg.Range = "A:ZZ"
+	}
+	if cfg.WritebackColumn != "" && columnIndex(cfg.WritebackColumn) < 0 {
+		return nil, fmt.Errorf("invalid writeback column %q", cfg.WritebackColumn)
+	}
+
+	scope := "https://www.googleapis.com/auth/spreadsheets.readonly"
+	if cfg.WritebackColumn != "" {
+		scope = "https://www.googleapis.com/auth/spreadsheets"
+	}
+	client, err := sheetsClient(ctx, cfg.Credentials, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sheet{cfg: cfg, client: client, ctx: ctx}
+	if err := s.fetch(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func sheetsClient(ctx context.Context, credentials, scope string) (*http.Client, error) {
+	if credentials == "" {
+		c, err := google.DefaultClient(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("google credentials: %w", err)
+		}
+		return c, nil
+	}
+	key, err := os.ReadFile(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("google credentials: %w", err)
+	}
+	jwt, err := google.JWTConfigFromJSON(key, scope)
+	if err != nil {
+		return nil, fmt.Errorf("google credentials %s: %w", credentials, err)
+	}
+	return jwt.Client(ctx), nil
+}
+
+// valueRange is the Sheets API representation of a block of cells.
+type valueRange struct {
+	Range  string     `json:"range"`
+	Values [][]string `json:"values"`
+}
+
+func (s *Sheet) fetch() error {
+	u := sheetsAPI + url.PathEscape(s.cfg.SpreadsheetID) + "/values/" + url.PathEscape(s.cfg.Range) +
+		"?majorDimension=ROWS&valueRenderOption=FORMATTED_VALUE"
+	var vr valueRange
+	if err := s.call(http.MethodGet, u, nil, &vr); err != nil {
+		return err
+	}
+	if len(vr.Values) == 0 {
+		return errors.New("empty sheet, expected a header row")
+	}
+
+	// The resolved range ("'Enrolled'!A1:F200") says where the header is.
+	sheet, cells, _ := strings.Cut(vr.Range, "!")
+	s.sheet = sheet
+	s.firstRow = 1
+	if m := a1Row.FindStringSubmatch(cells); m != nil {
+		s.firstRow, _ = strconv.Atoi(m[1])
+	}
+
+	s.header = make([]string, len(vr.Values[0]))
+	for i, h := range vr.Values[0] {
+		s.header[i] = normalizeColumn(h)
+	}
+	s.rows = vr.Values[1:]
+	return nil
+}
+
+var a1Row = regexp.MustCompile(`^[A-Za-z]*(\d+)`)
+
+// Next implements Reader. Blank rows are skipped.
+func (s *Sheet) Next() (certificate.Record, error) {
+	for s.next < len(s.rows) {
+		cols := s.rows[s.next]
+		s.next++
+		if blank(cols) {
+			continue
+		}
+		vals := make(map[string]string, len(cols))
+		for i, v := range cols {
+			if i < len(s.header) && s.header[i] != "" {
+				vals[s.header[i]] = v
+			}
+		}
+		rec, err := toRecord(vals, s.cfg.Location)
+		if err != nil {
+			return rec, &RowError{s.next, err}
+		}
+		return rec, nil
+	}
+	return certificate.Record{}, io.EOF
+}
+
+// Row implements Reader.
+func (s *Sheet) Row() int { return s.next }
+
+// Close implements Reader.
+func (s *Sheet) Close() error { return nil }
+
+func blank(cols []string) bool {
+	for _, c := range cols {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Writeback returns a ReportWriter that records each row's status and
+// certificate location in the sheet's writeback columns when it is closed,
+// or nil if no writeback column is configured. Locations prefer an upload
+// URL over the local path.
+func (s *Sheet) Writeback() ReportWriter {
+	if s.cfg.WritebackColumn == "" {
+		return nil
+	}
+	return &sheetReport{s: s}
+}
+
+type sheetReport struct {
+	s    *Sheet
+	data []valueRange
+}
+
+func (r *sheetReport) Write(row ReportRow) error {
+	status, location := "issued", row.Path
+	if !row.OK() {
+		status = "failed: " + row.Error
+	}
+	for _, d := range row.Deliveries {
+		if d.OK && strings.HasPrefix(d.Location, "http") {
+			location = d.Location
+		}
+	}
+
+	col := columnIndex(r.s.cfg.WritebackColumn)
+	n := r.s.firstRow + row.Row // the header occupies firstRow
+	r.data = append(r.data, valueRange{
+		Range:  fmt.Sprintf("%s!%s%d:%s%d", r.s.sheet, columnName(col), n, columnName(col+1), n),
+		Values: [][]string{{status, location}},
+	})
+	return nil
+}
+
+// Close sends every update in one batchUpdate call.
+func (r *sheetReport) Close(Summary) error {
+	if len(r.data) == 0 {
+		return nil
+	}
+	body := struct {
+		ValueInputOption string       `json:"valueInputOption"`
+		Data             []valueRange `json:"data"`
+	}{"RAW", r.data}
+	u := sheetsAPI + url.PathEscape(r.s.cfg.SpreadsheetID) + "/values:batchUpdate"
+	if err := r.s.call(http.MethodPost, u, body, nil); err != nil {
+		return fmt.Errorf("sheet writeback: %w", err)
+	}
+	return nil
+}
+
+func (s *Sheet) call(method, u string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(string(b))
+	}
+	req, err := http.NewRequestWithContext(s.ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sheets API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		return fmt.Errorf("sheets API: %s: %s", resp.Status, apiErr.Error.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// columnIndex converts a column name such as "A" or "AB" to a 0-based
+// index, or -1.
+func columnIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	n := 0
+	for _, r := range strings.ToUpper(name) {
+		if r < 'A' || r > 'Z' {
+			return -1
+		}
+		n = n*26 + int(r-'A') + 1
+	}
+	return n - 1
+}
+
+func columnName(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
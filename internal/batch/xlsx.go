@@ -0,0 +1,376 @@
+package batch
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// XLSX is read straight from the Office Open XML parts, so no spreadsheet
+// library is needed: the first worksheet, its shared strings, and enough of
+// its styles to tell dates from numbers.
+
+// NewXLSXReader reads the first worksheet of the Excel workbook in r, of
+// size bytes, with the first non-empty row as the header. Rows are decoded
+// one at a time, with dates without a zone in loc. If r is an io.Closer it
+// is closed by Close.
+func NewXLSXReader(r io.ReaderAt, size int64, loc *time.Location) (Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX workbook: %w", err)
+	}
+	x := &xlsxReader{src: r, parts: map[string]*zip.File{}, loc: loc}
+	for _, f := range zr.File {
+		x.parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	sheet, err := x.firstSheet()
+	if err != nil {
+		return nil, err
+	}
+	if err := x.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	if err := x.readStyles(); err != nil {
+		return nil, err
+	}
+
+	f, ok := x.parts[sheet]
+	if !ok {
+		return nil, fmt.Errorf("XLSX: worksheet %s is missing", sheet)
+	}
+	if x.sheet, err = f.Open(); err != nil {
+		return nil, err
+	}
+	x.dec = xml.NewDecoder(x.sheet)
+
+	for {
+		row, cells, err := x.nextRow()
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty input, expected a header row")
+		}
+		if err != nil {
+			x.sheet.Close()
+			return nil, err
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		x.headerRow = row
+		for col, v := range cells {
+			for len(x.header) <= col {
+				x.header = append(x.header, "")
+			}
+			x.header[col] = normalizeColumn(v)
+		}
+		return x, nil
+	}
+}
+
+type xlsxReader struct {
+	src      io.ReaderAt
+	parts    map[string]*zip.File
+	strings  []string
+	dates    map[int]bool // style indexes formatted as dates
+	date1904 bool
+
+	sheet     io.ReadCloser
+	dec       *xml.Decoder
+	header    []string
+	headerRow int
+	row       int
+	loc       *time.Location
+}
+
+// Next returns the record of the next non-empty row.
+func (x *xlsxReader) Next() (certificate.Record, error) {
+	for {
+		row, cells, err := x.nextRow()
+		if err != nil {
+			return certificate.Record{}, err
+		}
+		// Rows are numbered as the spreadsheet shows them, less the header
+		x.row = row - x.headerRow
+		if len(cells) == 0 {
+			continue
+		}
+		vals := make(map[string]string, len(cells))
+		for col, v := range cells {
+			if col < len(x.header) && x.header[col] != "" {
+				vals[x.header[col]] = v
+			}
+		}
+		rec, err := toRecord(vals, x.loc)
+		if err != nil {
+			return rec, &RowError{x.row, err}
+		}
+		return rec, nil
+	}
+}
+
+func (x *xlsxReader) Row() int { return x.row }
+
+func (x *xlsxReader) Close() error {
+	var err error
+	if x.sheet != nil {
+		err = x.sheet.Close()
+	}
+	if cl, ok := x.src.(io.Closer); ok {
+		err = errors.Join(err, cl.Close())
+	}
+	return err
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Style  int      `xml:"s,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// xlsxText is rich or plain text, in shared strings and inline cells.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// nextRow decodes the next <row> of the worksheet into its non-empty cell
+// values by 0-based column, with its 1-based row number.
+func (x *xlsxReader) nextRow() (int, map[int]string, error) {
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, nil, io.EOF
+			}
+			return 0, nil, fmt.Errorf("XLSX worksheet: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row struct {
+			Num   int        `xml:"r,attr"`
+			Cells []xlsxCell `xml:"c"`
+		}
+		if err := x.dec.DecodeElement(&row, &start); err != nil {
+			return 0, nil, fmt.Errorf("XLSX worksheet: %w", err)
+		}
+		if row.Num == 0 {
+			row.Num = x.headerRow + x.row + 1 // r is optional
+		}
+		cells := map[int]string{}
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				m := reCellRef.FindStringSubmatch(c.Ref)
+				if m == nil {
+					return 0, nil, fmt.Errorf("XLSX worksheet: bad cell reference %q", c.Ref)
+				}
+				col = columnIndex(m[1])
+			}
+			if v := x.value(c); strings.TrimSpace(v) != "" {
+				cells[col] = v
+			}
+		}
+		return row.Num, cells, nil
+	}
+}
+
+// value is the text of c, with dates as YYYY-MM-DD, or with a time of day
+// when they have one.
+func (x *xlsxReader) value(c xlsxCell) string {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(x.strings) {
+			return ""
+		}
+		return x.strings[i]
+	case "inlineStr":
+		return c.Inline.String()
+	case "b":
+		if c.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "", "n":
+		f, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return c.Value
+		}
+		if x.dates[c.Style] {
+			return x.date(f)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return c.Value // str, e
+}
+
+// date converts a spreadsheet serial date. Serials have no zone, so they
+// are counted in UTC, where every day has 24 hours, and read in loc later.
+func (x *xlsxReader) date(serial float64) string {
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if x.date1904 {
+		epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days, frac := math.Modf(serial)
+	t := epoch.AddDate(0, 0, int(days)).Add(time.Duration(math.Round(frac*86400)) * time.Second)
+	if frac == 0 {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// reCellRef splits a cell reference such as "AB12" into its column.
+var reCellRef = regexp.MustCompile(`^([A-Za-z]+)\d*$`)
+
+func (x *xlsxReader) decodePart(name string, v any) (bool, error) {
+	f, ok := x.parts[name]
+	if !ok {
+		return false, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return true, err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return true, fmt.Errorf("XLSX %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// firstSheet finds the part of the workbook's first worksheet.
+func (x *xlsxReader) firstSheet() (string, error) {
+	var wb struct {
+		Pr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if ok, err := x.decodePart("xl/workbook.xml", &wb); err != nil || !ok {
+		return "", errors.Join(err, errors.New("not an XLSX workbook: xl/workbook.xml is missing"))
+	}
+	x.date1904 = wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true"
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("XLSX: the workbook has no worksheets")
+	}
+
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if _, err := x.decodePart("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, r := range rels.Rels {
+		if r.ID == wb.Sheets[0].ID {
+			if t, ok := strings.CutPrefix(r.Target, "/"); ok {
+				return t, nil
+			}
+			return path.Join("xl", r.Target), nil
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+func (x *xlsxReader) readSharedStrings() error {
+	var sst struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if _, err := x.decodePart("xl/sharedStrings.xml", &sst); err != nil {
+		return err
+	}
+	x.strings = make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		x.strings[i] = si.String()
+	}
+	return nil
+}
+
+// Built-in number formats that are dates or times.
+var builtinDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true, 50: true, 51: true, 52: true, 53: true, 54: true, 55: true,
+	56: true, 57: true, 58: true,
+}
+
+// reFormatLiteral matches the parts of a number format that are not
+// placeholders: quoted text, escapes and [colors] or [conditions].
+var reFormatLiteral = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+func (x *xlsxReader) readStyles() error {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Xfs []struct {
+			NumFmt int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if _, err := x.decodePart("xl/styles.xml", &styles); err != nil {
+		return err
+	}
+	isDate := map[int]bool{}
+	for id := range builtinDateFormats {
+		isDate[id] = true
+	}
+	for _, f := range styles.NumFmts {
+		code := strings.ToLower(reFormatLiteral.ReplaceAllString(f.Code, ""))
+		isDate[f.ID] = strings.ContainsAny(code, "yd") || strings.Contains(code, "h")
+	}
+	x.dates = map[int]bool{}
+	for i, xf := range styles.Xfs {
+		x.dates[i] = isDate[xf.NumFmt]
+	}
+	return nil
+}
+
+// openXLSX opens the workbook at path.
+func openXLSX(path string, loc *time.Location) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewXLSXReader(f, st.Size(), loc)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,217 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Asset settings (TEMPLATE_IMAGE, FONT_FILE, FONT_BOLD_FILE and course
+// signatures) name a file in one of three ways:
+//
+//   - a path on disk;
+//   - an https URL, fetched into ASSET_CACHE_DIR and fetched again once it
+//     is older than ASSET_CACHE_TTL, if it has changed;
+//   - NAME:PATH, a file in the fs.FS a program registered as NAME with
+//     RegisterAssetFS, such as an embed.FS, so a binary can carry its own.
+//
+// Either way the rest of the generator sees a file on disk: LoadConfig
+// replaces URLs and FS references with the cached copy.
+
+var (
+	assetFSMu sync.RWMutex
+	assetFS   = map[string]fs.FS{}
+)
+
+// RegisterAssetFS makes the files of fsys available to asset settings as
+// "name:path". Registering a name again replaces its FS.
+func RegisterAssetFS(name string, fsys fs.FS) {
+	assetFSMu.Lock()
+	defer assetFSMu.Unlock()
+	assetFS[name] = fsys
+}
+
+func lookupAssetFS(ref string) (fs.FS, string, bool) {
+	name, p, ok := strings.Cut(ref, ":")
+	// One letter is a Windows drive, never an FS
+	if !ok || len(name) < 2 {
+		return nil, "", false
+	}
+	assetFSMu.RLock()
+	defer assetFSMu.RUnlock()
+	fsys, ok := assetFS[name]
+	return fsys, p, ok
+}
+
+// isAssetRef reports whether ref names an asset somewhere other than disk.
+func isAssetRef(ref string) bool {
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return true
+	}
+	_, _, ok := lookupAssetFS(ref)
+	return ok
+}
+
+// maxAssetSize bounds a fetched asset, so a misconfigured URL cannot fill
+// the disk.
+const maxAssetSize = 64 << 20
+
+var assetClient = &http.Client{Timeout: 30 * time.Second}
+
+// resolveAssets replaces the asset references of cfg with local copies.
+func (cfg *Config) resolveAssets() []error {
+	var errs []error
+	for key, ref := range map[string]*string{"TEMPLATE_IMAGE": &cfg.TemplateImage, "FONT_FILE": &cfg.FontFile, "FONT_BOLD_FILE": &cfg.FontBoldFile} {
+		p, err := cfg.resolveAsset(*ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+		// Cleared on error, so Validate does not report it missing again
+		*ref = p
+	}
+	return errs
+}
+
+// resolveAsset returns the path on disk of the asset ref.
+func (cfg Config) resolveAsset(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "https://"):
+		return cfg.fetchAsset(ref)
+	case strings.HasPrefix(ref, "http://"):
+		return "", fmt.Errorf("%s: assets are only fetched over https", ref)
+	}
+	fsys, p, ok := lookupAssetFS(ref)
+	if !ok {
+		return ref, nil
+	}
+	data, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		return "", err
+	}
+	// Named by content, so a changed asset is never mistaken for the
+	// cached fonts of the old one
+	sum := sha256.Sum256(data)
+	dest := filepath.Join(cfg.assetCacheDir(), "fs", hex.EncodeToString(sum[:8])+"-"+sanitize(path.Base(p)))
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	_, err = writeAtomic(dest, nil, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	return dest, err
+}
+
+// assetMeta is kept beside a fetched asset to revalidate it.
+type assetMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// fetchAsset downloads rawURL into the cache, unless the cached copy is
+// younger than AssetCacheTTL or the server says it has not changed. When
+// the server cannot be reached, a cached copy is used however old it is.
+func (cfg Config) fetchAsset(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	name := sanitize(path.Base(u.Path))
+	if name == "" || name == "." || name == "/" {
+		name = "asset"
+	}
+	dest := filepath.Join(cfg.assetCacheDir(), "url", hex.EncodeToString(sum[:8])+"-"+name)
+	metaPath := dest + ".json"
+
+	var meta assetMeta
+	_, statErr := os.Stat(dest)
+	cached := statErr == nil
+	if b, err := os.ReadFile(metaPath); err == nil && cached {
+		json.Unmarshal(b, &meta)
+		if time.Since(meta.FetchedAt) < cfg.AssetCacheTTL {
+			return dest, nil
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if cached {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := assetClient.Do(req)
+	if err != nil {
+		if cached {
+			return dest, nil
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+	case resp.StatusCode == http.StatusOK:
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return "", err
+		}
+		_, err = writeAtomic(dest, nil, func(w io.Writer) error {
+			n, err := io.Copy(w, io.LimitReader(resp.Body, maxAssetSize+1))
+			if err == nil && n > maxAssetSize {
+				err = fmt.Errorf("larger than %d MiB", maxAssetSize>>20)
+			}
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", rawURL, err)
+		}
+		meta = assetMeta{URL: rawURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	case cached && resp.StatusCode >= 500:
+		// The server's trouble; the copy we have is the best there is
+		return dest, nil
+	default:
+		return "", fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+
+	meta.FetchedAt = time.Now()
+	b, err := json.Marshal(meta)
+	if err == nil {
+		err = os.WriteFile(metaPath, b, 0o644)
+	}
+	return dest, err
+}
+
+// assetCacheDir is AssetCacheDir, defaulting to the user's cache
+// directory, or the system's temporary one when there is none.
+func (cfg Config) assetCacheDir() string {
+	if cfg.AssetCacheDir != "" {
+		return cfg.AssetCacheDir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "certgen", "assets")
+}
@@ -0,0 +1,369 @@
+package certificate
+
+import "fmt"
+
+// Aztec text modes. Each char is encoded in one of them, switched between
+// by latch codes; bytes none of them has go in binary shift runs.
+const (
+	aztecUpper = iota
+	aztecLower
+	aztecMixed
+	aztecDigit
+	aztecPunct
+)
+
+// aztecCodes are the codes of the characters each mode has.
+var aztecCodes = func() [5]map[byte]int {
+	var codes [5]map[byte]int
+	for m := range codes {
+		codes[m] = map[byte]int{}
+	}
+	for c := byte('A'); c <= 'Z'; c++ {
+		codes[aztecUpper][c] = int(c-'A') + 2
+		codes[aztecLower][c+'a'-'A'] = int(c-'A') + 2
+	}
+	for c := byte('0'); c <= '9'; c++ {
+		codes[aztecDigit][c] = int(c-'0') + 2
+	}
+	codes[aztecDigit][','], codes[aztecDigit]['.'] = 12, 13
+	for _, m := range []int{aztecUpper, aztecLower, aztecMixed, aztecDigit} {
+		codes[m][' '] = 1
+	}
+	for i, c := range "\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x1b\x1c\x1d\x1e\x1f@\\^_`|~\x7f" {
+		codes[aztecMixed][byte(c)] = i + 2
+	}
+	codes[aztecPunct]['\r'] = 1
+	for i, c := range `!"#$%&'()*+,-./:;<=>?[]{}` {
+		codes[aztecPunct][byte(c)] = i + 6
+	}
+	return codes
+}()
+
+// aztecLatch is the code for the next mode on the way from one mode to
+// another, for the latches there are no direct codes for.
+var aztecLatch = [4]map[int][2]int{ // {next mode, code}
+	aztecUpper: {aztecLower: {aztecLower, 28}, aztecMixed: {aztecMixed, 29}, aztecDigit: {aztecDigit, 30}},
+	aztecLower: {aztecUpper: {aztecDigit, 30}, aztecMixed: {aztecMixed, 29}, aztecDigit: {aztecDigit, 30}},
+	aztecMixed: {aztecUpper: {aztecUpper, 29}, aztecLower: {aztecLower, 28}, aztecDigit: {aztecUpper, 29}},
+	aztecDigit: {aztecUpper: {aztecUpper, 14}, aztecLower: {aztecUpper, 14}, aztecMixed: {aztecUpper, 14}},
+}
+
+// aztecFields are the Galois fields of the check words, by word size.
+var aztecFields = map[int]*galoisField{
+	4:  newGaloisField(0x13, 16),
+	6:  newGaloisField(0x43, 64),
+	8:  newGaloisField(0x12d, 256),
+	10: newGaloisField(0x409, 1024),
+	12: newGaloisField(0x1069, 4096),
+}
+
+// bitWriter collects bits, most significant first.
+type bitWriter []bool
+
+func (b *bitWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+// encodeAztec encodes content in the smallest Aztec symbol that holds it
+// with at least checkPercent of its capacity for check words, without a
+// quiet zone.
+func encodeAztec(content []byte, checkPercent int) ([][]bool, error) {
+	bits := aztecText(content)
+	checkBits := len(bits)*checkPercent/100 + 11
+
+	// Compact symbols of 1 to 4 layers, then full-range ones of 4 to 32
+	var compact bool
+	var layers, wordSize, capacity int
+	var stuffed bitWriter
+	for i := 0; ; i++ {
+		if i > 32 {
+			return nil, fmt.Errorf("%d bytes do not fit in the largest symbol", len(content))
+		}
+		compact, layers = i <= 3, i
+		if compact {
+			layers = i + 1
+		}
+		capacity = aztecCapacity(layers, compact)
+		if len(bits)+checkBits > capacity {
+			continue
+		}
+		if ws := aztecWordSize(layers); stuffed == nil || ws != wordSize {
+			wordSize, stuffed = ws, aztecStuff(bits, ws)
+		}
+		if compact && len(stuffed) > wordSize*64 {
+			continue // more words than the mode message can count
+		}
+		if len(stuffed)+checkBits <= capacity-capacity%wordSize {
+			break
+		}
+	}
+	words := len(stuffed) / wordSize
+	data := aztecCheck(stuffed, capacity, wordSize)
+
+	var mode bitWriter
+	if compact {
+		mode.write(layers-1, 2)
+		mode.write(words-1, 6)
+		mode = aztecCheck(mode, 28, 4)
+	} else {
+		mode.write(layers-1, 5)
+		mode.write(words-1, 11)
+		mode = aztecCheck(mode, 40, 4)
+	}
+	return aztecDraw(data, mode, layers, compact), nil
+}
+
+// aztecText encodes content in the text modes, latching to the mode of
+// each character, shifting to punctuation, and falling back on binary
+// shift for the bytes no mode has.
+func aztecText(content []byte) bitWriter {
+	var bits bitWriter
+	mode := aztecUpper
+	emit := func(code int) {
+		if mode == aztecDigit {
+			bits.write(code, 4)
+		} else {
+			bits.write(code, 5)
+		}
+	}
+	latch := func(to int) {
+		for mode != to {
+			next := aztecLatch[mode][to]
+			emit(next[1])
+			mode = next[0]
+		}
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if code, ok := aztecCodes[mode][c]; ok {
+			emit(code)
+			continue
+		}
+		if code, ok := aztecCodes[aztecPunct][c]; ok {
+			emit(0) // P/S
+			bits.write(code, 5)
+			continue
+		}
+		if m := aztecModeOf(c); m >= 0 {
+			latch(m)
+			emit(aztecCodes[m][c])
+			continue
+		}
+		// B/S, then the run's length and bytes
+		if mode == aztecDigit {
+			latch(aztecUpper)
+		}
+		n := 1
+		for i+n < len(content) && n < 2078 && aztecModeOf(content[i+n]) < 0 {
+			n++
+		}
+		emit(31)
+		if n <= 31 {
+			bits.write(n, 5)
+		} else {
+			bits.write(0, 5)
+			bits.write(n-31, 11)
+		}
+		for _, b := range content[i : i+n] {
+			bits.write(int(b), 8)
+		}
+		i += n - 1
+	}
+	return bits
+}
+
+// aztecModeOf is the mode with c, other than punctuation, or -1.
+func aztecModeOf(c byte) int {
+	for _, m := range []int{aztecUpper, aztecLower, aztecDigit, aztecMixed, aztecPunct} {
+		if _, ok := aztecCodes[m][c]; ok {
+			return m
+		}
+	}
+	return -1
+}
+
+// aztecCapacity is the number of bits symbols of the given layers hold.
+func aztecCapacity(layers int, compact bool) int {
+	if compact {
+		return (88 + 16*layers) * layers
+	}
+	return (112 + 16*layers) * layers
+}
+
+func aztecWordSize(layers int) int {
+	switch {
+	case layers <= 2:
+		return 6
+	case layers <= 8:
+		return 8
+	case layers <= 22:
+		return 10
+	}
+	return 12
+}
+
+// aztecStuff splits bits into words, padding the last one with ones, and
+// stuffs a bit into every word otherwise all zeros or all ones, which
+// are kept for erasures.
+func aztecStuff(bits bitWriter, wordSize int) bitWriter {
+	var out bitWriter
+	mask := 1<<wordSize - 2
+	for i := 0; i < len(bits); i += wordSize {
+		word := 0
+		for j := range wordSize {
+			if i+j >= len(bits) || bits[i+j] {
+				word |= 1 << (wordSize - 1 - j)
+			}
+		}
+		switch word & mask {
+		case mask:
+			out.write(word&mask, wordSize)
+			i--
+		case 0:
+			out.write(word|1, wordSize)
+			i--
+		default:
+			out.write(word, wordSize)
+		}
+	}
+	return out
+}
+
+// aztecCheck appends check words to the words of bits up to totalBits,
+// padding the front with zeros to a whole number of words.
+func aztecCheck(bits bitWriter, totalBits, wordSize int) bitWriter {
+	words := make([]int, len(bits)/wordSize)
+	for i := range words {
+		for j := range wordSize {
+			if bits[i*wordSize+j] {
+				words[i] |= 1 << (wordSize - 1 - j)
+			}
+		}
+	}
+	words = append(words, aztecFields[wordSize].checkWords(words, totalBits/wordSize-len(words))...)
+	out := make(bitWriter, totalBits%wordSize, totalBits)
+	for _, w := range words {
+		out.write(w, wordSize)
+	}
+	return out
+}
+
+// aztecDraw lays out the data and mode message around the bull's eye,
+// with the reference grid of full-range symbols.
+func aztecDraw(data, mode bitWriter, layers int, compact bool) [][]bool {
+	base := 14 + layers*4
+	if compact {
+		base = 11 + layers*4
+	}
+	// Full-range symbols have a reference grid line every 16 modules from
+	// the center, which the data has to skip
+	align := make([]int, base)
+	size := base
+	if compact {
+		for i := range align {
+			align[i] = i
+		}
+	} else {
+		size = base + 1 + 2*((base/2-1)/15)
+		origin, center := base/2, size/2
+		for i := range origin {
+			offset := i + i/15
+			align[origin-i-1] = center - offset - 1
+			align[origin+i] = center + offset + 1
+		}
+	}
+	modules := newModules(size)
+	set := func(x, y int) { modules[y][x] = true }
+
+	// Each layer is four two-module-wide strips, clockwise from the top left
+	for i, offset := 0, 0; i < layers; i++ {
+		row := (layers-i)*4 + 12
+		if compact {
+			row = (layers-i)*4 + 9
+		}
+		for j := range row {
+			for k := range 2 {
+				at := offset + j*2 + k
+				if data[at] {
+					set(align[i*2+k], align[i*2+j])
+				}
+				if data[at+row*2] {
+					set(align[i*2+j], align[base-1-i*2-k])
+				}
+				if data[at+row*4] {
+					set(align[base-1-i*2-k], align[base-1-i*2-j])
+				}
+				if data[at+row*6] {
+					set(align[base-1-i*2-j], align[i*2+k])
+				}
+			}
+		}
+		offset += row * 8
+	}
+
+	center := size / 2
+	if compact {
+		for i := range 7 {
+			at := center - 3 + i
+			if mode[i] {
+				set(at, center-5)
+			}
+			if mode[i+7] {
+				set(center+5, at)
+			}
+			if mode[20-i] {
+				set(at, center+5)
+			}
+			if mode[27-i] {
+				set(center-5, at)
+			}
+		}
+	} else {
+		for i := range 10 {
+			at := center - 5 + i + i/5
+			if mode[i] {
+				set(at, center-7)
+			}
+			if mode[i+10] {
+				set(center+7, at)
+			}
+			if mode[29-i] {
+				set(at, center+7)
+			}
+			if mode[39-i] {
+				set(center-7, at)
+			}
+		}
+		for i, j := 0, 0; i < base/2-1; i, j = i+15, j+16 {
+			for k := center & 1; k < size; k += 2 {
+				set(center-j, k)
+				set(center+j, k)
+				set(k, center-j)
+				set(k, center+j)
+			}
+		}
+	}
+
+	// The bull's eye, with the orientation marks at its corners
+	eye := 7
+	if compact {
+		eye = 5
+	}
+	for i := 0; i < eye; i += 2 {
+		for j := center - i; j <= center+i; j++ {
+			set(j, center-i)
+			set(j, center+i)
+			set(center-i, j)
+			set(center+i, j)
+		}
+	}
+	set(center-eye, center-eye)
+	set(center-eye+1, center-eye)
+	set(center-eye, center-eye+1)
+	set(center+eye, center-eye)
+	set(center+eye, center-eye+1)
+	set(center+eye, center+eye-1)
+	return modules
+}
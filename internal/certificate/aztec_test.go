@@ -0,0 +1,164 @@
+package certificate
+
+import (
+	"strings"
+	"testing"
+)
+
+// bits parses a bit string of X for ones and . for zeros, ignoring spaces.
+func bits(s string) bitWriter {
+	var b bitWriter
+	for _, c := range strings.ReplaceAll(s, " ", "") {
+		b = append(b, c == 'X')
+	}
+	return b
+}
+
+func bitString(b []bool) string {
+	var s strings.Builder
+	for _, v := range b {
+		if v {
+			s.WriteByte('X')
+		} else {
+			s.WriteByte('.')
+		}
+	}
+	return s.String()
+}
+
+func TestAztecText(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		// The ISO/IEC 24778 example: C, L/L o d e space, D/L 2, U/L D, P/S !
+		{"Code 2D!", "..X.. XXX.. X.... ..X.X ..XX. ....X XXXX. .X.. XXX. ..X.X ..... ..XX."},
+		{"A\xe9", "...X. XXXXX ....X XXX.X..X"},           // B/S, length 1, the byte
+		{"1\xe9", "XXXX. ..XX XXX. XXXXX ....X XXX.X..X"}, // digit mode has no B/S: U/L first
+	}
+	for _, tt := range tests {
+		if got, want := bitString(aztecText([]byte(tt.content))), bitString(bits(tt.want)); got != want {
+			t.Errorf("%q: bits\n%s, want\n%s", tt.content, got, want)
+		}
+	}
+}
+
+func TestAztecStuff(t *testing.T) {
+	tests := []struct {
+		wordSize int
+		in, want string
+	}{
+		{5, ".X.X. X.X.X .X.X.", ".X.X. X.X.X .X.X."},
+		{5, ".X.X. ..... .X.X", ".X.X. ....X ..X.X"},
+		{6, ".X.X.. XXXXXX ...... ..X.XX", ".X.X.. XXXXX. X..... ...X.X XXXXX."},
+	}
+	for _, tt := range tests {
+		if got, want := bitString(aztecStuff(bits(tt.in), tt.wordSize)), bitString(bits(tt.want)); got != want {
+			t.Errorf("%s in %d-bit words: %s, want %s", tt.in, tt.wordSize, got, want)
+		}
+	}
+}
+
+// TestAztecModeMessage checks the layer and word counts and their check
+// words against the vectors of ZXing's encoder tests.
+func TestAztecModeMessage(t *testing.T) {
+	tests := []struct {
+		compact       bool
+		layers, words int
+		want          string
+	}{
+		{true, 2, 29, ".X .XXX.. ...X XX.. ..X .XX. .XX.X"},
+		{false, 21, 660, "X.X.. .X.X..X..XX .XXX ..X.. .XXX. .X... ..XXX"},
+		{false, 32, 4096, "XXXXX XXXXXXXXXXX X.X. ..... XXX.X ..X.. X.XXX"},
+	}
+	for _, tt := range tests {
+		var mode bitWriter
+		if tt.compact {
+			mode.write(tt.layers-1, 2)
+			mode.write(tt.words-1, 6)
+			mode = aztecCheck(mode, 28, 4)
+		} else {
+			mode.write(tt.layers-1, 5)
+			mode.write(tt.words-1, 11)
+			mode = aztecCheck(mode, 40, 4)
+		}
+		if got, want := bitString(mode), bitString(bits(tt.want)); got != want {
+			t.Errorf("%d layers, %d words: %s, want %s", tt.layers, tt.words, got, want)
+		}
+	}
+}
+
+// TestAztecCompact encodes the specification's example in a one-layer
+// compact symbol and reads its mode message back off the matrix.
+func TestAztecCompact(t *testing.T) {
+	const want = `
+...XXX..X...XX.
+....XX...XX...X
+X.XX....X...X..
+.XXXXXXXXXXXX..
+XXXX.......XXXX
+...X.XXXXX.XXX.
+X..X.X...X.XX..
+..XX.X.X.X.X..X
+..XX.X...X.X.X.
+.X.X.XXXXX.X...
+X..X.......X..X
+X..XXXXXXXXXXX.
+.X...XX...X....
+...XX...XX.XX..
+X..XXX.X.X.....`
+	content := []byte("Code 2D!")
+	modules, err := encodeAztec(content, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]string, len(modules))
+	for y, row := range modules {
+		rows[y] = bitString(row)
+	}
+	if got := strings.Join(rows, "\n"); got != strings.TrimSpace(want) {
+		t.Fatalf("symbol\n%s\nwant\n%s", got, strings.TrimSpace(want))
+	}
+
+	// 58 bits stuff into 10 six-bit words; one layer holds 17
+	stuffed := aztecStuff(aztecText(content), 6)
+	if len(stuffed) != 60 {
+		t.Fatalf("%d stuffed bits, want 60", len(stuffed))
+	}
+	data := aztecCheck(stuffed, aztecCapacity(1, true), 6)
+	checkSyndromes(t, aztecFields[6], words(data[len(data)%6:], 6), 7)
+
+	// The mode message runs clockwise around the bull's eye, seven bits a
+	// side from the top left: one layer, 10 data words.
+	var mode bitWriter
+	c := len(modules) / 2
+	for i := range 7 {
+		mode = append(mode, modules[c-5][c-3+i])
+	}
+	for i := range 7 {
+		mode = append(mode, modules[c-3+i][c+5])
+	}
+	for i := range 7 {
+		mode = append(mode, modules[c+5][c+3-i])
+	}
+	for i := range 7 {
+		mode = append(mode, modules[c+3-i][c-5])
+	}
+	if got, want := bitString(mode[:8]), ".. ..X..X"; got != bitString(bits(want)) {
+		t.Errorf("mode message starts %s, want %s", got, want)
+	}
+	checkSyndromes(t, aztecFields[4], words(mode, 4), 5)
+}
+
+// words splits bits into words of size bits.
+func words(b bitWriter, size int) []int {
+	w := make([]int, len(b)/size)
+	for i := range w {
+		for j := range size {
+			if b[i*size+j] {
+				w[i] |= 1 << (size - 1 - j)
+			}
+		}
+	}
+	return w
+}
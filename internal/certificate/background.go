@@ -0,0 +1,108 @@
+package certificate
+
+import (
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Frame styles for BORDER_STYLE.
+const (
+	BorderSingle = "single"
+	BorderDouble = "double" // a thin second line inside the frame
+)
+
+// BackgroundConfig is the page the generator draws itself beneath the
+// template, so certificates without a TEMPLATE_IMAGE still look finished:
+// a solid color or a gradient, and a frame around the page. A template
+// covers the background, and a full-page one the frame too.
+type BackgroundConfig struct {
+	Color    color.RGBA // transparent for a plain page
+	Gradient string     // GradientNone, or how Color blends into ColorEnd
+	ColorEnd color.RGBA
+
+	BorderWidth float64 // mm; 0 for no frame
+	BorderColor color.RGBA
+	BorderInset float64 // mm from the page edge to the outside of the frame
+	BorderStyle string  // BorderSingle or BorderDouble
+}
+
+func (b BackgroundConfig) validate(pageWidth, pageHeight float64, fail func(format string, args ...any)) {
+	switch strings.ToLower(b.Gradient) {
+	case "", GradientNone:
+	case GradientHorizontal, GradientVertical, GradientDiagonal, GradientRadial:
+		if b.Color.A == 0 || b.ColorEnd.A == 0 {
+			fail("BACKGROUND_GRADIENT: needs both BACKGROUND_COLOR and BACKGROUND_COLOR_END")
+		}
+	default:
+		fail("BACKGROUND_GRADIENT: %q is not one of none, horizontal, vertical, diagonal, radial", b.Gradient)
+	}
+
+	if b.BorderWidth < 0 {
+		fail("BORDER_WIDTH: must not be negative, got %g", b.BorderWidth)
+	}
+	if b.BorderInset < 0 {
+		fail("BORDER_INSET: must not be negative, got %g", b.BorderInset)
+	}
+	switch strings.ToLower(b.BorderStyle) {
+	case "", BorderSingle, BorderDouble:
+	default:
+		fail("BORDER_STYLE: %q is not one of single, double", b.BorderStyle)
+	}
+	extent := b.BorderInset + b.BorderWidth
+	if strings.EqualFold(b.BorderStyle, BorderDouble) {
+		extent += b.BorderWidth + b.BorderWidth/3
+	}
+	if b.BorderWidth > 0 && 2*extent >= math.Min(pageWidth, pageHeight) {
+		fail("BORDER_INSET: a %g mm frame %g mm in leaves nothing of a %.1fx%.1f mm page",
+			b.BorderWidth, b.BorderInset, pageWidth, pageHeight)
+	}
+}
+
+// draw paints the background and frame over the whole page.
+func (b BackgroundConfig) draw(pdf renderer, pageWidth, pageHeight float64) {
+	c1, c2 := b.Color, b.ColorEnd
+	grad := func(x1, y1, x2, y2 float64) {
+		// Gradient vectors run from the lower-left corner (0, 0) to the
+		// upper-right (1, 1)
+		pdf.LinearGradient(0, 0, pageWidth, pageHeight,
+			int(c1.R), int(c1.G), int(c1.B), int(c2.R), int(c2.G), int(c2.B), x1, y1, x2, y2)
+	}
+	switch strings.ToLower(b.Gradient) {
+	case GradientHorizontal:
+		grad(0, 0, 1, 0)
+	case GradientVertical:
+		grad(0, 1, 0, 0)
+	case GradientDiagonal:
+		grad(0, 1, 1, 0)
+	case GradientRadial:
+		// To the corners, as QR_GRADIENT's
+		pdf.RadialGradient(0, 0, pageWidth, pageHeight,
+			int(c1.R), int(c1.G), int(c1.B), int(c2.R), int(c2.G), int(c2.B), 0.5, 0.5, 0.5, 0.5, math.Sqrt2/2)
+	default:
+		if c1.A != 0 {
+			pdf.SetFillColor(int(c1.R), int(c1.G), int(c1.B))
+			pdf.Rect(0, 0, pageWidth, pageHeight, "F")
+		}
+	}
+
+	if b.BorderWidth <= 0 {
+		return
+	}
+	w, in := b.BorderWidth, b.BorderInset
+	pdf.SetDrawColor(int(b.BorderColor.R), int(b.BorderColor.G), int(b.BorderColor.B))
+	pdf.SetLineWidth(w)
+	// Strokes are centred on the path, so the frame is drawn half its width in
+	pdf.Rect(in+w/2, in+w/2, pageWidth-2*in-w, pageHeight-2*in-w, "D")
+	if strings.EqualFold(b.BorderStyle, BorderDouble) {
+		thin := w / 3
+		in += 2 * w
+		pdf.SetLineWidth(thin)
+		pdf.Rect(in+thin/2, in+thin/2, pageWidth-2*in-thin, pageHeight-2*in-thin, "D")
+	}
+}
+
+// drawn reports whether there is any background to draw.
+func (b BackgroundConfig) drawn() bool {
+	return b.Color.A != 0 || b.BorderWidth > 0
+}
@@ -0,0 +1,63 @@
+package certificate
+
+import "strings"
+
+// Blend modes for the *_BLEND settings. Each combines a field's colors
+// with the template art beneath instead of covering it.
+const (
+	BlendNormal   = "normal"   // cover what is beneath (default)
+	BlendMultiply = "multiply" // darken like ink: white disappears, so a seal's white box does
+	BlendScreen   = "screen"   // lighten: black disappears
+	BlendOverlay  = "overlay"  // multiply the art's darks and screen its lights
+	BlendDarken   = "darken"   // keep the darker of the field and the art
+	BlendLighten  = "lighten"  // keep the lighter of the field and the art
+)
+
+// blendModes are the blend modes by the names PDF gives them.
+var blendModes = map[string]string{
+	BlendNormal:   "Normal",
+	BlendMultiply: "Multiply",
+	BlendScreen:   "Screen",
+	BlendOverlay:  "Overlay",
+	BlendDarken:   "Darken",
+	BlendLighten:  "Lighten",
+}
+
+// Blend is how a field is painted over what is beneath it: at Opacity, from
+// 0 for invisible to 1 for solid, combined by Mode.
+type Blend struct {
+	Opacity float64
+	Mode    string // Blend*; empty is BlendNormal
+}
+
+func (b Blend) opaque() bool {
+	return b.Opacity >= 1 && (b.Mode == "" || strings.EqualFold(b.Mode, BlendNormal))
+}
+
+// draw paints what fn draws on pdf as b says.
+func (b Blend) draw(pdf renderer, fn func()) {
+	if b.opaque() {
+		fn()
+		return
+	}
+	pdf.SetAlpha(b.Opacity, blendModes[strings.ToLower(b.Mode)])
+	fn()
+	pdf.SetAlpha(1, blendModes[BlendNormal])
+}
+
+func (b Blend) validate(prefix string, fail func(string, ...any)) {
+	if b.Opacity < 0 || b.Opacity > 1 {
+		fail("%s_OPACITY: must be between 0 and 1, got %g", prefix, b.Opacity)
+	}
+	if _, ok := blendModes[strings.ToLower(b.Mode)]; !ok && b.Mode != "" {
+		fail("%s_BLEND: %q is not one of normal, multiply, screen, overlay, darken, lighten", prefix, b.Mode)
+	}
+}
+
+// blend reads prefix_OPACITY and prefix_BLEND.
+func (l *loader) blend(prefix string) Blend {
+	return Blend{
+		Opacity: l.float(prefix+"_OPACITY", 1),
+		Mode:    l.str(prefix+"_BLEND", BlendNormal),
+	}
+}
@@ -0,0 +1,52 @@
+package certificate
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// namedColors are the color names accepted wherever a color is.
+var namedColors = map[string]color.RGBA{
+	"black":       {A: 255},
+	"white":       {R: 255, G: 255, B: 255, A: 255},
+	"gray":        {R: 128, G: 128, B: 128, A: 255},
+	"grey":        {R: 128, G: 128, B: 128, A: 255},
+	"silver":      {R: 192, G: 192, B: 192, A: 255},
+	"red":         {R: 255, A: 255},
+	"maroon":      {R: 128, A: 255},
+	"green":       {G: 128, A: 255},
+	"blue":        {B: 255, A: 255},
+	"navy":        {B: 128, A: 255},
+	"gold":        {R: 212, G: 175, B: 55, A: 255},
+	"transparent": {},
+}
+
+// ParseColor reads a color as #RRGGBB, #RRGGBBAA, rgb(R,G,B) or one of
+// the named colors, case-insensitively.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+	if inner, ok := strings.CutPrefix(s, "rgb("); ok && strings.HasSuffix(inner, ")") {
+		return parseRGB(strings.TrimSuffix(inner, ")"))
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	if ok && (len(hex) == 6 || len(hex) == 8) {
+		if n, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			if len(hex) == 6 {
+				n = n<<8 | 0xff
+			}
+			return color.RGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+		}
+	}
+	names := make([]string, 0, len(namedColors))
+	for name := range namedColors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return color.RGBA{}, fmt.Errorf("%q is not #RRGGBB, #RRGGBBAA, rgb(R,G,B) or one of %s", s, strings.Join(names, ", "))
+}
@@ -0,0 +1,786 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Source looks up a configuration value by its environment-style key
+// (e.g. "NAME_SIZE"). os.LookupEnv is a Source.
+type Source func(key string) (string, bool)
+
+// MapSource serves configuration from m, e.g. the parsed contents of a .env
+// file.
+func MapSource(m map[string]string) Source {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+// Layered consults each source in turn and returns the first non-empty
+// value, so earlier sources take precedence over later ones.
+func Layered(srcs ...Source) Source {
+	return func(key string) (string, bool) {
+		for _, src := range srcs {
+			if v, ok := src(key); ok && strings.TrimSpace(v) != "" {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Config is the complete layout and output configuration of a Generator.
+type Config struct {
+	TemplateImage    string
+	FontFamily       string
+	FontFile         string // TrueType font embedded as FontFamily; empty uses a built-in font
+	FontBoldFile     string // bold face for the name; defaults to FontFile
+	MissingGlyphs    string // policy for characters the font lacks
+	Transliterations string // "ő=o, ű=u" replacements tried first
+	TemplateWidthPx  float64
+	TemplateHeightPx float64
+	DPI              float64
+	Orientation      string // OrientationAuto follows the template dimensions
+	PDFEngine        string // EngineFpdf, or EngineGofpdf to fall back to the archived engine
+	Tagged           bool   // write tagged PDFs with a reading order, language and alt text
+	EmbedCredential  bool   // attach the certificate's Credential as CredentialFileName
+
+	// TemplateInset is how far in from every page edge, in mm, the template
+	// is drawn; 0 is full bleed. TemplateFit is how it fills the rest of the
+	// page, e.g. FitStretch.
+	TemplateInset float64
+	TemplateFit   string
+
+	// Paper is the page size of the PDF, PAGE_SIZE: PaperTemplate for the
+	// template's own size, a named size such as "A4" or "letter" turned to
+	// the template's orientation, or WIDTHxHEIGHT in mm. On other paper
+	// than the template's, the whole certificate is scaled to fit and
+	// centred, so recipients can print it on standard paper.
+	Paper string
+
+	// AssetCacheDir holds the assets fetched from https URLs and copied out
+	// of registered file systems; empty is certgen/assets in the user's
+	// cache directory. A fetched asset is fetched again, if it has changed,
+	// once it is older than AssetCacheTTL.
+	AssetCacheDir string
+	AssetCacheTTL time.Duration
+
+	Background BackgroundConfig
+
+	Name      TextField
+	NameRules NameRules
+	Reg       TextField
+	QR        QRConfig
+
+	// RegTemplate is the text/template for the registration line, e.g.
+	// "{{.RegNumber}}" when the template art already carries the label,
+	// or RegNone to print no registration line at all.
+	RegTemplate string
+	RegOptional bool // leave the line out when the record values it uses are empty
+	RegFormat   RegFormat
+
+	Grade GradeConfig
+	Image ImageFieldConfig
+
+	// Expiry is the "valid until" line, printed only for certificates that
+	// expire.
+	Expiry           TextField
+	ExpiryDateFormat string // Go layout for the date on the expiry line
+	ValidityMonths   int    // default lifetime of a certificate; 0 never expires
+
+	Locale string // labels are printed in; a record's "locale" field overrides it
+	// Timezone is where certificates are issued: their dates are printed,
+	// recorded and named in it. Nil is the server's local time.
+	Timezone *time.Location
+
+	IssuerName          string // recorded in the PDF metadata
+	LinkedIn            LinkedInConfig
+	VerificationBaseURL string
+	OutputDirTemplate   string
+	OutputExists        string
+
+	// Sidecar writes a Sidecar JSON file with every PDF, named like it, in
+	// SidecarDir or, when that is empty, beside the PDF.
+	Sidecar    bool
+	SidecarDir string
+
+	// Version labels the configuration, e.g. "2026-10" for a redesign; it
+	// is recorded with every certificate in the registry next to the
+	// config hash. ConfigChange is what to do, ConfigChange*, when a
+	// certificate is issued again with another hash than the first time.
+	Version      string
+	ConfigChange string
+
+	Impose ImposeConfig
+
+	// CourseCatalog is a JSON file of courses that records name by their
+	// course_code; see Course.
+	CourseCatalog string
+	courses       map[string]course
+	// Signatories are printed on signature lines; a course sets them.
+	Signatories []Signatory
+	Signatory   SignatoryLayout
+
+	DebugGrid bool // overlay a mm grid and field boxes for layout calibration
+}
+
+// Page orientations for ORIENTATION.
+const (
+	OrientationAuto      = "auto"      // as TEMPLATE_WIDTH_PX and TEMPLATE_HEIGHT_PX are (default)
+	OrientationPortrait  = "portrait"  // taller than wide
+	OrientationLandscape = "landscape" // wider than tall, the only layout before ORIENTATION
+)
+
+// Template fit modes for TEMPLATE_FIT.
+const (
+	FitStretch = "stretch" // fill the page, distorting a template of another shape (default)
+	FitContain = "contain" // the whole template, as large as fits, centred
+	FitCover   = "cover"   // fill the page, centred, cropping what overhangs
+)
+
+// TextField positions and styles one line of text. Left, Top and
+// LineHeight are in mm, Size in points.
+type TextField struct {
+	Size       float64
+	Left       float64
+	Top        float64
+	LineHeight float64 // height of the line box below Top; 0 takes Size as mm
+	VAlign     string  // where the text sits in its line box, e.g. AlignMiddle
+	Color      color.RGBA
+	Effects    TextEffects
+	Blend      Blend
+}
+
+// GradeConfig is the optional grade line, whose text and color can depend
+// on the grade through rules.
+type GradeConfig struct {
+	TextField
+	Field    string // record field holding the grade; empty prints no grade line
+	Rules    string // see parseGradeRules
+	Optional bool   // leave the line out for records without a grade, instead of failing them
+}
+
+// QRConfig positions and styles the verification QR code. Size is in
+// template pixels and converted to mm using the configured DPI.
+type QRConfig struct {
+	Left       float64
+	Top        float64
+	Size       int
+	Level      string
+	Foreground color.RGBA
+	Background color.RGBA
+
+	Style       string     // module shape, QRStyle*
+	EyeStyle    string     // finder pattern shape, QREye*
+	EyeColor    color.RGBA // finder pattern color; zero uses the module colors
+	Gradient    string     // direction of the module gradient, Gradient*
+	GradientEnd color.RGBA // module color at the end of the gradient
+
+	// Blend paints the code over the template art, e.g. BlendMultiply so a
+	// white background takes on the paper's texture.
+	Blend Blend
+
+	// SizeMM is the printed edge length in mm, for templates that reserve
+	// an area of a given size; 0 prints Size template pixels at DPI.
+	// Codes whose modules would come out smaller than MinModule mm are
+	// refused, as phones cannot scan them reliably.
+	SizeMM    float64
+	MinModule float64
+
+	// Symbology is the kind of code printed, Symbology*, for scanners
+	// that read another than QR.
+	Symbology string
+}
+
+// ConfigFromEnv loads the configuration from the process environment.
+func ConfigFromEnv() (Config, error) {
+	return LoadConfig(os.LookupEnv)
+}
+
+// LoadConfig reads every setting from src, falling back to defaults for
+// missing keys. All malformed and invalid values are reported together,
+// joined with errors.Join, rather than stopping at the first one.
+func LoadConfig(src Source) (Config, error) {
+	l := &loader{src: src}
+	cfg := l.config()
+
+	errs := l.errs
+	errs = append(errs, cfg.resolveAssets()...)
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.CourseCatalog != "" {
+		var err error
+		if cfg.courses, err = loadCourses(cfg.CourseCatalog, src); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return cfg, invalidConfig(errors.Join(errs...))
+}
+
+// Setting describes one configuration key read by LoadConfig.
+type Setting struct {
+	Key     string
+	Default string
+	Bool    bool // takes true/false and can be used as a bare switch
+}
+
+// Settings lists every key LoadConfig reads with its default, in the order
+// they are read.
+func Settings() []Setting {
+	l := &loader{src: func(string) (string, bool) { return "", false }, record: true}
+	l.config()
+	return l.settings
+}
+
+// config reads a complete Config through l.
+func (l *loader) config() Config {
+	return Config{
+		TemplateImage:    l.str("TEMPLATE_IMAGE", ""),
+		FontFamily:       l.str("FONT_FAMILY", "Helvetica"),
+		FontFile:         l.str("FONT_FILE", ""),
+		FontBoldFile:     l.str("FONT_BOLD_FILE", ""),
+		MissingGlyphs:    l.str("MISSING_GLYPHS", GlyphsError),
+		Transliterations: l.str("TRANSLITERATIONS", ""),
+		TemplateWidthPx:  l.float("TEMPLATE_WIDTH_PX", 2500),
+		TemplateHeightPx: l.float("TEMPLATE_HEIGHT_PX", 1932),
+		DPI:              l.float("DPI", 300),
+		Orientation:      l.str("ORIENTATION", OrientationAuto),
+		TemplateInset:    l.float("TEMPLATE_INSET", TemplateSafety),
+		TemplateFit:      l.str("TEMPLATE_FIT", FitStretch),
+		Paper:            l.str("PAGE_SIZE", PaperTemplate),
+		AssetCacheDir:    l.str("ASSET_CACHE_DIR", ""),
+		AssetCacheTTL:    l.duration("ASSET_CACHE_TTL", time.Hour),
+		PDFEngine:        l.str("PDF_ENGINE", EngineFpdf),
+		Tagged:           l.bool("PDF_TAGGED", true),
+		EmbedCredential:  l.bool("PDF_EMBED_CREDENTIAL", true),
+
+		Background: BackgroundConfig{
+			Color:       l.color("BACKGROUND_COLOR", "", "", "", "", color.RGBA{}),
+			Gradient:    l.str("BACKGROUND_GRADIENT", GradientNone),
+			ColorEnd:    l.color("BACKGROUND_COLOR_END", "", "", "", "", color.RGBA{}),
+			BorderWidth: l.float("BORDER_WIDTH", 0),
+			BorderColor: l.color("BORDER_COLOR", "", "", "", "", color.RGBA{A: 255}),
+			BorderInset: l.float("BORDER_INSET", 8),
+			BorderStyle: l.str("BORDER_STYLE", BorderSingle),
+		},
+
+		Name: l.textField("NAME", 42, 50, 70),
+		NameRules: NameRules{
+			Case:       l.str("NAME_CASE", CaseKeep),
+			Honorifics: l.str("NAME_HONORIFICS", HonorificsKeep),
+			MaxLength:  l.int("NAME_MAX_LENGTH", 0),
+		},
+		Reg:         l.textField("REG", 18, 50, 110),
+		RegTemplate: l.str("REG_TEMPLATE", DefaultRegTemplate),
+		RegOptional: l.bool("REG_OPTIONAL", false),
+		RegFormat: RegFormat{
+			Pad:       l.int("REG_PAD", 0),
+			Groups:    l.str("REG_GROUPS", ""),
+			Separator: l.str("REG_GROUP_SEPARATOR", "-"),
+			Prefix:    l.str("REG_PREFIX", ""),
+			Suffix:    l.str("REG_SUFFIX", ""),
+		},
+
+		Grade: GradeConfig{
+			Field:     l.str("GRADE_FIELD", ""),
+			Rules:     l.str("GRADE_RULES", ""),
+			Optional:  l.bool("GRADE_OPTIONAL", false),
+			TextField: l.textField("GRADE", 16, 50, 95),
+		},
+		Image: ImageFieldConfig{
+			Field:    l.str("IMAGE_FIELD", ""),
+			Path:     l.str("IMAGE_PATH", ""),
+			Left:     l.float("IMAGE_LEFT", 20),
+			Top:      l.float("IMAGE_TOP", 20),
+			Width:    l.float("IMAGE_WIDTH", 40),
+			Height:   l.float("IMAGE_HEIGHT", 20),
+			Optional: l.bool("IMAGE_OPTIONAL", false),
+			Blend:    l.blend("IMAGE"),
+		},
+		Expiry:           l.textField("EXPIRY", 14, 50, 125),
+		ExpiryDateFormat: l.str("EXPIRY_DATE_FORMAT", time.DateOnly),
+		ValidityMonths:   l.int("VALIDITY_MONTHS", 0),
+
+		QR: QRConfig{
+			Left:       l.float("QR_LEFT", 160),
+			Top:        l.float("QR_TOP", 110),
+			Size:       l.int("QR_SIZE", 180),
+			Level:      l.str("QR_ERROR_CORRECTION", "M"),
+			Foreground: l.color("QR_FG", "QR_FG_R", "QR_FG_G", "QR_FG_B", "QR_FG_A", color.RGBA{A: 255}),
+			Background: l.color("QR_BG", "QR_BG_R", "QR_BG_G", "QR_BG_B", "QR_BG_A", color.RGBA{}),
+
+			Style:       l.str("QR_STYLE", QRStyleSquare),
+			EyeStyle:    l.str("QR_EYE_STYLE", QREyeSquare),
+			EyeColor:    l.color("QR_EYE_COLOR", "", "", "", "", color.RGBA{}),
+			Gradient:    l.str("QR_GRADIENT", GradientNone),
+			GradientEnd: l.color("QR_FG_END", "", "", "", "", color.RGBA{}),
+
+			Blend: l.blend("QR"),
+
+			SizeMM:    l.float("QR_SIZE_MM", 0),
+			MinModule: l.float("QR_MIN_MODULE", 0.25),
+
+			Symbology: l.str("QR_SYMBOLOGY", SymbologyQR),
+		},
+
+		Locale:   l.str("LOCALE", DefaultLocale),
+		Timezone: l.location("TIMEZONE"),
+
+		IssuerName:          l.str("ISSUER_NAME", ""),
+		VerificationBaseURL: l.str("VERIFICATION_BASE_URL", "https://peaceandhumanity.org/verification"),
+		OutputDirTemplate:   l.str("OUTPUT_DIR_TEMPLATE", ""),
+		OutputExists:        l.str("OUTPUT_EXISTS", ExistsOverwrite),
+
+		Sidecar:    l.bool("SIDECAR_JSON", false),
+		SidecarDir: l.str("SIDECAR_DIR", ""),
+
+		Version:      l.str("CONFIG_VERSION", ""),
+		ConfigChange: l.str("CONFIG_CHANGE", ConfigChangeWarn),
+
+		Impose: ImposeConfig{
+			Sheet:    l.str("IMPOSE_SHEET", "SRA3"),
+			Layout:   l.str("IMPOSE_LAYOUT", ImposeAuto),
+			Gap:      l.float("IMPOSE_GAP", 0),
+			Margin:   l.float("IMPOSE_MARGIN", 10),
+			CutMarks: l.bool("IMPOSE_CUT_MARKS", true),
+		},
+
+		CourseCatalog: l.str("COURSE_CATALOG", ""),
+		Signatory: SignatoryLayout{
+			Size:        l.float("SIGNATORY_SIZE", 11),
+			Left:        l.float("SIGNATORY_LEFT", 30),
+			Top:         l.float("SIGNATORY_TOP", 150),
+			Color:       l.color("SIGNATORY_COLOR", "", "", "", "", color.RGBA{A: 255}),
+			Spacing:     l.float("SIGNATORY_SPACING", 70),
+			Width:       l.float("SIGNATORY_WIDTH", 55),
+			ImageHeight: l.float("SIGNATORY_IMAGE_HEIGHT", 15),
+			Blend:       l.blend("SIGNATORY"),
+		},
+
+		LinkedIn: LinkedInConfig{
+			OrganizationID: l.str("LINKEDIN_ORGANIZATION_ID", ""),
+			CertName:       l.str("LINKEDIN_CERT_NAME", "Certificate"),
+		},
+
+		DebugGrid: l.bool("DEBUG_GRID", false),
+	}
+}
+
+// Validate checks the semantic constraints on cfg: positive sizes, known
+// enum values, parseable templates and an existing template image. The
+// error matches ErrInvalidConfig, and ErrTemplateNotFound or ErrFontLoad
+// when those are among the problems.
+func (cfg Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	positive := []struct {
+		key string
+		v   float64
+	}{
+		{"TEMPLATE_WIDTH_PX", cfg.TemplateWidthPx},
+		{"TEMPLATE_HEIGHT_PX", cfg.TemplateHeightPx},
+		{"DPI", cfg.DPI},
+		{"NAME_SIZE", cfg.Name.Size},
+		{"REG_SIZE", cfg.Reg.Size},
+		{"EXPIRY_SIZE", cfg.Expiry.Size},
+		{"GRADE_SIZE", cfg.Grade.Size},
+		{"SIGNATORY_SIZE", cfg.Signatory.Size},
+		{"QR_SIZE", float64(cfg.QR.Size)},
+	}
+	for _, p := range positive {
+		if p.v <= 0 {
+			fail("%s: must be greater than zero, got %g", p.key, p.v)
+		}
+	}
+
+	for _, f := range []struct {
+		prefix string
+		TextField
+	}{{"NAME", cfg.Name}, {"REG", cfg.Reg}, {"GRADE", cfg.Grade.TextField}, {"EXPIRY", cfg.Expiry}} {
+		if f.LineHeight < 0 {
+			fail("%s_LINE_HEIGHT: must not be negative, got %g", f.prefix, f.LineHeight)
+		}
+		if f.Effects.OutlineWidth < 0 {
+			fail("%s_OUTLINE_WIDTH: must not be negative, got %g", f.prefix, f.Effects.OutlineWidth)
+		}
+		switch strings.ToLower(f.VAlign) {
+		case "", AlignMiddle, AlignTop, AlignBottom, AlignBaseline:
+		default:
+			fail("%s_VALIGN: %q is not one of middle, top, bottom, baseline", f.prefix, f.VAlign)
+		}
+		f.Blend.validate(f.prefix, fail)
+	}
+	cfg.QR.Blend.validate("QR", fail)
+	cfg.Signatory.Blend.validate("SIGNATORY", fail)
+
+	if cfg.TemplateImage != "" {
+		if _, err := os.Stat(cfg.TemplateImage); err != nil {
+			fail("TEMPLATE_IMAGE: %w: %s", ErrTemplateNotFound, cfg.TemplateImage)
+		}
+	}
+	if err := cfg.checkFonts(); err != nil {
+		key := "FONT_FAMILY"
+		if cfg.FontFile != "" {
+			key = "FONT_FILE"
+		}
+		fail("%s: %w", key, err)
+	}
+
+	if _, ok := findLocale(cfg.Locale); !ok {
+		fail("LOCALE: %q is not one of %s", cfg.Locale, strings.Join(Locales(), ", "))
+	}
+
+	switch strings.ToLower(cfg.MissingGlyphs) {
+	case "", GlyphsError, GlyphsTransliterate:
+	default:
+		fail("MISSING_GLYPHS: %q is not one of error, transliterate", cfg.MissingGlyphs)
+	}
+	if _, err := parseTransliterations(cfg.Transliterations); err != nil {
+		fail("TRANSLITERATIONS: %v", err)
+	}
+
+	switch strings.ToLower(cfg.Orientation) {
+	case "", OrientationAuto, OrientationPortrait, OrientationLandscape:
+	default:
+		fail("ORIENTATION: %q is not one of auto, portrait, landscape", cfg.Orientation)
+	}
+	switch strings.ToLower(cfg.TemplateFit) {
+	case "", FitStretch, FitContain, FitCover:
+	default:
+		fail("TEMPLATE_FIT: %q is not one of stretch, contain, cover", cfg.TemplateFit)
+	}
+	if _, _, err := cfg.paper(); err != nil {
+		fail("PAGE_SIZE: %v", err)
+	}
+	if w, h := cfg.PageSize(); cfg.TemplateInset < 0 || 2*cfg.TemplateInset >= math.Min(w, h) {
+		fail("TEMPLATE_INSET: must be at least 0 and leave some of the %.1fx%.1f mm page, got %g", w, h, cfg.TemplateInset)
+	}
+
+	switch strings.ToLower(cfg.PDFEngine) {
+	case "", EngineFpdf, EngineGofpdf:
+	default:
+		fail("PDF_ENGINE: %q is not one of fpdf, gofpdf", cfg.PDFEngine)
+	}
+
+	switch strings.ToUpper(cfg.QR.Level) {
+	case "L", "M", "Q", "H":
+	default:
+		fail("QR_ERROR_CORRECTION: %q is not one of L, M, Q, H", cfg.QR.Level)
+	}
+	if cfg.QR.SizeMM < 0 {
+		fail("QR_SIZE_MM: must not be negative, got %g", cfg.QR.SizeMM)
+	}
+	if cfg.QR.MinModule < 0 {
+		fail("QR_MIN_MODULE: must not be negative, got %g", cfg.QR.MinModule)
+	}
+	switch strings.ToLower(cfg.QR.Symbology) {
+	case "", SymbologyQR, SymbologyDataMatrix, SymbologyAztec:
+	default:
+		fail("QR_SYMBOLOGY: %q is not one of qr, datamatrix, aztec", cfg.QR.Symbology)
+	}
+	switch strings.ToLower(cfg.QR.Style) {
+	case "", QRStyleSquare, QRStyleRounded, QRStyleDots:
+	default:
+		fail("QR_STYLE: %q is not one of square, rounded, dots", cfg.QR.Style)
+	}
+	switch strings.ToLower(cfg.QR.EyeStyle) {
+	case "", QREyeSquare, QREyeRounded, QREyeCircle:
+	default:
+		fail("QR_EYE_STYLE: %q is not one of square, rounded, circle", cfg.QR.EyeStyle)
+	}
+	switch strings.ToLower(cfg.QR.Gradient) {
+	case "", GradientNone:
+	case GradientHorizontal, GradientVertical, GradientDiagonal, GradientRadial:
+		if cfg.QR.GradientEnd.A == 0 {
+			fail("QR_FG_END: required with QR_GRADIENT=%s", cfg.QR.Gradient)
+		}
+	default:
+		fail("QR_GRADIENT: %q is not one of none, horizontal, vertical, diagonal, radial", cfg.QR.Gradient)
+	}
+
+	if id := cfg.LinkedIn.OrganizationID; id != "" {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			fail("LINKEDIN_ORGANIZATION_ID: %q is not the numeric ID of a LinkedIn company page", id)
+		}
+	}
+
+	pageWidth, pageHeight := cfg.PageSize()
+	cfg.Background.validate(pageWidth, pageHeight, fail)
+	cfg.Impose.validate(fail)
+
+	if u, err := url.Parse(cfg.VerificationBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		fail("VERIFICATION_BASE_URL: %q is not an absolute URL", cfg.VerificationBaseURL)
+	}
+
+	switch strings.ToLower(cfg.NameRules.Case) {
+	case "", CaseKeep, CaseUpper, CaseTitle:
+	default:
+		fail("NAME_CASE: %q is not one of keep, upper, title", cfg.NameRules.Case)
+	}
+	switch strings.ToLower(cfg.NameRules.Honorifics) {
+	case "", HonorificsKeep, HonorificsNormalize, HonorificsStrip:
+	default:
+		fail("NAME_HONORIFICS: %q is not one of keep, normalize, strip", cfg.NameRules.Honorifics)
+	}
+	if cfg.ValidityMonths < 0 {
+		fail("VALIDITY_MONTHS: must not be negative, got %d", cfg.ValidityMonths)
+	}
+	if cfg.NameRules.MaxLength < 0 {
+		fail("NAME_MAX_LENGTH: must not be negative, got %d", cfg.NameRules.MaxLength)
+	}
+
+	if _, err := parseGradeRules(cfg.Grade.Rules); err != nil {
+		fail("GRADE_RULES: %v", err)
+	}
+
+	if _, err := parseRegTemplate(cfg.RegTemplate); err != nil {
+		fail("REG_TEMPLATE: %v", err)
+	}
+	cfg.RegFormat.validate(fail)
+	cfg.Image.validate(fail)
+
+	if cfg.OutputDirTemplate != "" {
+		if _, err := template.New("").Parse(cfg.OutputDirTemplate); err != nil {
+			fail("OUTPUT_DIR_TEMPLATE: %v", err)
+		}
+	}
+
+	switch strings.ToLower(cfg.OutputExists) {
+	case "", ExistsOverwrite, ExistsError, ExistsSkip, ExistsVersion:
+	default:
+		fail("OUTPUT_EXISTS: %q is not one of overwrite, error, skip, version", cfg.OutputExists)
+	}
+	switch strings.ToLower(cfg.ConfigChange) {
+	case "", ConfigChangeWarn, ConfigChangeRefuse, ConfigChangeAllow:
+	default:
+		fail("CONFIG_CHANGE: %q is not one of warn, refuse, allow", cfg.ConfigChange)
+	}
+
+	return invalidConfig(errors.Join(errs...))
+}
+
+// PageSize is the page size in mm derived from the template pixel
+// dimensions and DPI, turned to ORIENTATION unless that is auto.
+func (cfg Config) PageSize() (width, height float64) {
+	width = (cfg.TemplateWidthPx / cfg.DPI) * 25.4
+	height = (cfg.TemplateHeightPx / cfg.DPI) * 25.4
+
+	switch strings.ToLower(cfg.Orientation) {
+	case OrientationLandscape:
+		if width < height {
+			width, height = height, width
+		}
+	case OrientationPortrait:
+		if width > height {
+			width, height = height, width
+		}
+	}
+	return width, height
+}
+
+// QRSizeMM is the printed QR edge length in mm.
+func (cfg Config) QRSizeMM() float64 {
+	if cfg.QR.SizeMM > 0 {
+		return cfg.QR.SizeMM
+	}
+	return float64(cfg.QR.Size) * 25.4 / cfg.DPI
+}
+
+// qrPixels is the edge length in pixels the QR image is rendered at.
+func (cfg Config) qrPixels() int {
+	if cfg.QR.SizeMM > 0 {
+		return max(int(math.Round(cfg.QR.SizeMM*cfg.DPI/25.4)), 1)
+	}
+	return cfg.QR.Size
+}
+
+// coreFonts are the families every PDF viewer provides without embedding.
+var coreFonts = map[string]bool{
+	"helvetica": true, "arial": true, "times": true,
+	"courier": true, "symbol": true, "zapfdingbats": true,
+}
+
+// VerificationURL is the URL encoded in the QR code for regNumber.
+func (cfg Config) VerificationURL(regNumber string) string {
+	return fmt.Sprintf("%s#%s", strings.TrimRight(cfg.VerificationBaseURL, "/"), regNumber)
+}
+
+// Location is the Timezone certificates are issued in.
+func (cfg Config) Location() *time.Location {
+	if cfg.Timezone == nil {
+		return time.Local
+	}
+	return cfg.Timezone
+}
+
+// loader reads typed values from a Source, collecting every parse error
+// instead of failing on the first. With record set it also notes every key
+// it is asked for, which is how Settings enumerates them.
+type loader struct {
+	src  Source
+	errs []error
+
+	record   bool
+	settings []Setting
+}
+
+func (l *loader) note(key, def string, isBool bool) {
+	if l.record {
+		l.settings = append(l.settings, Setting{Key: key, Default: def, Bool: isBool})
+	}
+}
+
+func (l *loader) lookup(key string) (string, bool) {
+	v, ok := l.src(key)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+func (l *loader) str(key, def string) string {
+	l.note(key, def, false)
+	if v, ok := l.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+func (l *loader) float(key string, def float64) float64 {
+	l.note(key, strconv.FormatFloat(def, 'f', -1, 64), false)
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a number", key, v))
+		return def
+	}
+	return f
+}
+
+func (l *loader) int(key string, def int) int {
+	l.note(key, strconv.Itoa(def), false)
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+		return def
+	}
+	return n
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	l.note(key, strconv.FormatBool(def), true)
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+		return def
+	}
+	return b
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	l.note(key, def.String(), false)
+	v, ok := l.lookup(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a duration such as 1h or 30m", key, v))
+		return def
+	}
+	return d
+}
+
+// location reads an IANA time zone name such as "Asia/Colombo"; unset, or
+// "Local", is the server's local time.
+func (l *loader) location(key string) *time.Location {
+	l.note(key, "Local", false)
+	v, ok := l.lookup(key)
+	if !ok {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not an IANA time zone such as Asia/Colombo", key, v))
+		return time.Local
+	}
+	return loc
+}
+
+// textField reads the settings of the text field whose keys start with
+// prefix.
+func (l *loader) textField(prefix string, size, left, top float64) TextField {
+	return TextField{
+		Size:       l.float(prefix+"_SIZE", size),
+		Left:       l.float(prefix+"_LEFT", left),
+		Top:        l.float(prefix+"_TOP", top),
+		LineHeight: l.float(prefix+"_LINE_HEIGHT", 0),
+		VAlign:     l.str(prefix+"_VALIGN", AlignMiddle),
+		Color:      l.color(prefix+"_COLOR", prefix+"_COLOR_R", prefix+"_COLOR_G", prefix+"_COLOR_B", "", color.RGBA{A: 255}),
+		Effects: TextEffects{
+			OutlineWidth: l.float(prefix+"_OUTLINE_WIDTH", 0),
+			OutlineColor: l.color(prefix+"_OUTLINE_COLOR", "", "", "", "", color.RGBA{R: 255, G: 255, B: 255, A: 255}),
+			ShadowX:      l.float(prefix+"_SHADOW_X", 0),
+			ShadowY:      l.float(prefix+"_SHADOW_Y", 0),
+			ShadowColor:  l.color(prefix+"_SHADOW_COLOR", "", "", "", "", color.RGBA{R: 128, G: 128, B: 128, A: 255}),
+		},
+		Blend: l.blend(prefix),
+	}
+}
+
+// channel reads a single 0–255 color component.
+func (l *loader) channel(key string, def uint8) uint8 {
+	if key == "" {
+		return def
+	}
+	n := l.int(key, int(def))
+	if n < 0 || n > 255 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %d is outside 0–255", key, n))
+		return def
+	}
+	return uint8(n)
+}
+
+// color reads a color from key in any form ParseColor takes, e.g.
+// NAME_COLOR=#1a2b3c, else from the per-channel keys; an empty aKey keeps
+// def.A.
+func (l *loader) color(key, rKey, gKey, bKey, aKey string, def color.RGBA) color.RGBA {
+	l.note(key, "", false)
+	if v, ok := l.lookup(key); ok {
+		c, err := ParseColor(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %w", key, err))
+			return def
+		}
+		return c
+	}
+	return color.RGBA{
+		R: l.channel(rKey, def.R),
+		G: l.channel(gKey, def.G),
+		B: l.channel(bKey, def.B),
+		A: l.channel(aKey, def.A),
+	}
+}
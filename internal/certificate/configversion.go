@@ -0,0 +1,78 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+)
+
+// Policies for CONFIG_CHANGE, applied when a certificate is issued or
+// repaired again with a layout other than the one it was issued with, as
+// told by its config hash in the registry.
+const (
+	ConfigChangeWarn   = "warn"   // reissue it, with a warning (default)
+	ConfigChangeRefuse = "refuse" // fail it, so the original stays as it is
+	ConfigChangeAllow  = "allow"  // reissue it silently
+)
+
+// ConfigHash is the digest of the configuration rec's certificate is laid
+// out with, its course's and rec's layout overrides included. Two issues
+// of a certificate with the same hash look alike and say the same.
+func (g *Generator) ConfigHash(rec Record) (string, error) {
+	g, _, err := g.forRecord(rec)
+	if err != nil {
+		return "", err
+	}
+	return g.configHash, nil
+}
+
+// hash is the digest of everything in cfg that decides what a certificate
+// looks like and says. Where and how files are written, CONFIG_VERSION and
+// CONFIG_CHANGE are left out, and assets count by their digests in assets
+// rather than by their paths, which differ between machines and caches.
+func (cfg Config) hash(assets map[string]string) string {
+	c := cfg
+	c.Version, c.ConfigChange = "", ""
+	c.AssetCacheDir, c.AssetCacheTTL = "", 0
+	c.OutputDirTemplate, c.OutputExists = "", ""
+	c.Sidecar, c.SidecarDir = false, ""
+	c.CourseCatalog, c.Impose = "", ImposeConfig{}
+	asset := func(path string) string {
+		if sum, ok := assets[path]; ok {
+			return sum
+		}
+		return path
+	}
+	c.TemplateImage, c.FontFile, c.FontBoldFile = asset(c.TemplateImage), asset(c.FontFile), asset(c.FontBoldFile)
+	c.Signatories = slices.Clone(c.Signatories)
+	for i := range c.Signatories {
+		c.Signatories[i].Signature = asset(c.Signatories[i].Signature)
+	}
+	// A time.Location has no JSON form of its own
+	b, _ := json.Marshal(struct {
+		Config
+		Timezone string
+	}{c, c.Location().String()})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// digestAssets returns the digests of the asset files cfg renders with, by
+// path. Files that cannot be read are left out, for Check to report.
+func (cfg Config) digestAssets() map[string]string {
+	assets := map[string]string{}
+	paths := []string{cfg.TemplateImage, cfg.FontFile, cfg.FontBoldFile}
+	for _, s := range cfg.Signatories {
+		paths = append(paths, s.Signature)
+	}
+	for _, p := range paths {
+		if _, done := assets[p]; p == "" || done {
+			continue
+		}
+		if sum, err := FileSHA256(p); err == nil {
+			assets[p] = sum
+		}
+	}
+	return assets
+}
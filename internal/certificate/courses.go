@@ -0,0 +1,256 @@
+package certificate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// CourseCodeField is the record field naming a COURSE_CATALOG course, such
+// as a batch column "course_code".
+const CourseCodeField = "course_code"
+
+// Course is one course or event of COURSE_CATALOG: what every certificate
+// for it shares, so records only carry a course code. The catalog is a
+// JSON object of courses by code:
+//
+//	{
+//	  "GO101": {
+//	    "title": "Introduction to Go",
+//	    "profile": "profiles/go.env",
+//	    "settings": {"NAME_COLOR": "navy"},
+//	    "signatories": [{"name": "Irshad Ahmed", "title": "Founder", "signature": "sig/irshad.png"}],
+//	    "validity_months": 24
+//	  }
+//	}
+//
+// Paths in the catalog are relative to the catalog file; a signature may
+// also be an https URL or a file of a registered FS, as assets may.
+type Course struct {
+	Title string `json:"title"` // the record's Course when it has none
+	// Profile is a .env file of settings overlaying the configuration for
+	// the course, such as its TEMPLATE_IMAGE; Settings overlay the profile.
+	Profile     string            `json:"profile,omitempty"`
+	Settings    map[string]string `json:"settings,omitempty"`
+	Signatories []Signatory       `json:"signatories,omitempty"`
+	// ValidityMonths overrides VALIDITY_MONTHS; nil keeps it, 0 never
+	// expires.
+	ValidityMonths *int `json:"validity_months,omitempty"`
+}
+
+// Signatory is a person who signs a course's certificates, printed on a
+// signature line as SIGNATORY_* lays out.
+type Signatory struct {
+	Name      string `json:"name"`
+	Title     string `json:"title,omitempty"`
+	Signature string `json:"signature,omitempty"` // PNG or JPEG drawn above the line
+}
+
+// SignatoryLayout places the signature lines, left to right from Left,
+// with the lines at Top and each name and title beneath its line. Left,
+// Top and the lengths are in mm, Size in points.
+type SignatoryLayout struct {
+	Size        float64
+	Left        float64
+	Top         float64
+	Color       color.RGBA
+	Spacing     float64 // from one line's start to the next
+	Width       float64 // of each line
+	ImageHeight float64 // of the signature image above the line
+	Blend       Blend   // of the signatures, lines and names
+}
+
+// course is a loaded catalog entry with the configuration it renders with.
+type course struct {
+	Course
+	cfg           Config
+	profileSHA256 string
+}
+
+// loadCourses reads the catalog at path and loads the configuration of each
+// course, layered over src.
+func loadCourses(path string, src Source) (map[string]course, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("COURSE_CATALOG: %w", err)
+	}
+	var entries map[string]Course
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("COURSE_CATALOG %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	rel := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || isAssetRef(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	courses := map[string]course{}
+	var errs []error
+	for _, code := range slices.Sorted(maps.Keys(entries)) {
+		c := entries[code]
+		fail := func(err error) { errs = append(errs, fmt.Errorf("COURSE_CATALOG: course %s: %w", code, err)) }
+		if strings.TrimSpace(code) == "" {
+			fail(errors.New("course codes must not be empty"))
+			continue
+		}
+
+		var profile map[string]string
+		var profileSHA256 string
+		if c.Profile != "" {
+			var err error
+			if profile, err = godotenv.Read(rel(c.Profile)); err == nil {
+				profileSHA256, err = FileSHA256(rel(c.Profile))
+			}
+			if err != nil {
+				fail(fmt.Errorf("profile: %w", err))
+				continue
+			}
+		}
+		own := maps.Clone(c.Settings)
+		if c.ValidityMonths != nil {
+			if own == nil {
+				own = map[string]string{}
+			}
+			own["VALIDITY_MONTHS"] = strconv.Itoa(*c.ValidityMonths)
+		}
+		layered := Layered(MapSource(own), MapSource(profile), src)
+		cfg, err := LoadConfig(func(key string) (string, bool) {
+			// A course cannot have courses of its own
+			if key == "COURSE_CATALOG" {
+				return "", false
+			}
+			return layered(key)
+		})
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		for i, s := range c.Signatories {
+			if strings.TrimSpace(s.Name) == "" {
+				fail(fmt.Errorf("signatory %d has no name", i+1))
+			}
+			if s.Signature != "" {
+				sig, err := cfg.resolveAsset(rel(s.Signature))
+				if err == nil {
+					_, _, err = imageSize(sig)
+				}
+				if err != nil {
+					fail(fmt.Errorf("signature of %s: %w", s.Name, err))
+				}
+				c.Signatories[i].Signature = sig
+			}
+		}
+		cfg.Signatories = c.Signatories
+		courses[code] = course{Course: c, cfg: cfg, profileSHA256: profileSHA256}
+	}
+	return courses, errors.Join(errs...)
+}
+
+// Courses lists the codes in COURSE_CATALOG, sorted.
+func (g *Generator) Courses() []string {
+	return slices.Sorted(maps.Keys(g.courses))
+}
+
+// Resolve fills in what rec's course in COURSE_CATALOG gives it: the
+// course title as its Course, unless it has one, and, once it has an
+// IssuedAt, the expiry of the course's validity. Records without a course
+// code are returned as they are.
+func (g *Generator) Resolve(rec Record) (Record, error) {
+	cg, err := g.forCourse(rec)
+	if err != nil || cg == g {
+		return rec, err
+	}
+	c := g.cfg.courses[strings.TrimSpace(rec.Fields[CourseCodeField])]
+	if rec.Course == "" {
+		rec.Course = c.Title
+	}
+	if rec.ExpiresAt.IsZero() && !rec.IssuedAt.IsZero() {
+		rec.ExpiresAt = cg.ExpiresAt(rec)
+	}
+	return rec, nil
+}
+
+// forCourse returns the generator for rec's course, or g itself for a
+// record without a course code. Without a COURSE_CATALOG, a course_code is
+// just another field.
+func (g *Generator) forCourse(rec Record) (*Generator, error) {
+	code := strings.TrimSpace(rec.Fields[CourseCodeField])
+	if code == "" || g.courses == nil {
+		return g, nil
+	}
+	cg, ok := g.courses[code]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %q is not in COURSE_CATALOG (have %s)", ErrUnknownCourse, CourseCodeField, code, strings.Join(g.Courses(), ", "))
+	}
+	return cg, nil
+}
+
+// drawSignatories prints a signature line for each of cfg.Signatories,
+// with the signature image above it and the name and title below.
+func drawSignatories(pdf renderer, cfg Config, enc func(string) string) {
+	l := cfg.Signatory
+	em := ptToMM(l.Size)
+	for i, s := range cfg.Signatories {
+		x := l.Left + float64(i)*l.Spacing
+		if s.Signature != "" {
+			if w, h, err := imageSize(s.Signature); err == nil && h > 0 {
+				// Fitted into the line's width and the image height, centred
+				sh := l.ImageHeight
+				sw := sh * float64(w) / float64(h)
+				if sw > l.Width {
+					sw, sh = l.Width, l.Width*float64(h)/float64(w)
+				}
+				pdf.BeginTag("Figure", "Signature of "+s.Name)
+				pdf.ImageFile(s.Signature, x+(l.Width-sw)/2, l.Top-sh-0.5, sw, sh)
+				pdf.EndTag()
+			}
+		}
+
+		pdf.BeginTag("Artifact", "")
+		pdf.SetDrawColor(int(l.Color.R), int(l.Color.G), int(l.Color.B))
+		pdf.SetLineWidth(0.3)
+		pdf.Line(x, l.Top, x+l.Width, l.Top)
+		pdf.EndTag()
+
+		setTextColor(pdf, l.Color)
+		baseline := l.Top + 1.4*em
+		for _, line := range []struct {
+			style, text string
+			size        float64
+		}{{"B", s.Name, l.Size}, {"", s.Title, l.Size * 0.85}} {
+			if line.text == "" {
+				continue
+			}
+			pdf.SetFont(cfg.FontFamily, line.style, line.size)
+			text := enc(line.text)
+			pdf.BeginTag("P", "")
+			pdf.Text(x+(l.Width-pdf.StringWidth(text))/2, baseline, text)
+			pdf.EndTag()
+			baseline += 1.2 * ptToMM(line.size)
+		}
+	}
+}
+
+// imageSize is the size in pixels of the image at path.
+func imageSize(path string) (width, height int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	return c.Width, c.Height, err
+}
@@ -0,0 +1,215 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CoverEntry is one issued certificate listed on a cover sheet.
+type CoverEntry struct {
+	Name      string
+	RegNumber string
+	IssuedAt  time.Time
+	SHA256    string // hex digest of the issued PDF
+}
+
+// CoverSheet is the issuance summary of a batch, for filing with an
+// accreditation body: an A4 list of every certificate issued, with the
+// Merkle root of their PDFs' digests, so the listed documents can later be
+// shown to be exactly those issued. Entries are listed in the order added.
+// A CoverSheet is not safe for concurrent use.
+type CoverSheet struct {
+	g       *Generator
+	title   string
+	entries []CoverEntry
+	leaves  [][]byte
+}
+
+// NewCoverSheet starts an empty cover sheet headed with title, such as
+// the batch's file name.
+func (g *Generator) NewCoverSheet(title string) *CoverSheet {
+	return &CoverSheet{g: g, title: title}
+}
+
+// Add lists e on the sheet.
+func (c *CoverSheet) Add(e CoverEntry) error {
+	sum, err := hex.DecodeString(e.SHA256)
+	if err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("cover sheet: %s: %q is not a SHA-256 digest", e.RegNumber, e.SHA256)
+	}
+	c.entries = append(c.entries, e)
+	c.leaves = append(c.leaves, sum)
+	return nil
+}
+
+// Len is the number of certificates listed.
+func (c *CoverSheet) Len() int {
+	return len(c.entries)
+}
+
+// MerkleRoot is the hex root of the Merkle tree over the listed PDFs'
+// digests, in the order listed: each parent is the SHA-256 of its two
+// children's digests concatenated, and an unpaired last node is carried up
+// as it is. It is empty for an empty sheet.
+func (c *CoverSheet) MerkleRoot() string {
+	if len(c.leaves) == 0 {
+		return ""
+	}
+	level := c.leaves
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
+
+// Cover sheet geometry, in mm on an A4 portrait page.
+const (
+	coverWidth  = 210.0
+	coverHeight = 297.0
+	coverMargin = 15.0
+	coverRow    = 4.5
+)
+
+// coverColumns are the table's columns, left to right, with their widths.
+var coverColumns = []struct {
+	title string
+	width float64
+}{{"#", 10}, {"Name", 48}, {"Registration", 28}, {"Issued", 20}, {"PDF SHA-256", 74}}
+
+// Output writes the cover sheet to w.
+func (c *CoverSheet) Output(w io.Writer) error {
+	if len(c.entries) == 0 {
+		return errors.New("cover sheet: no certificates")
+	}
+	cfg := c.g.cfg
+	pdf := newRenderer(cfg.PDFEngine, coverWidth, coverHeight)
+	if err := cfg.addFonts(pdf); err != nil {
+		return err
+	}
+	enc := cfg.encoder(pdf)
+	loc := cfg.Location()
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetDrawColor(0, 0, 0)
+
+	first, last := c.entries[0].IssuedAt, c.entries[0].IssuedAt
+	for _, e := range c.entries {
+		if e.IssuedAt.Before(first) {
+			first = e.IssuedAt
+		}
+		if e.IssuedAt.After(last) {
+			last = e.IssuedAt
+		}
+	}
+	issued := first.In(loc).Format(time.DateOnly)
+	if d := last.In(loc).Format(time.DateOnly); d != issued {
+		issued += " to " + d
+	}
+
+	y := coverMargin + ptToMM(16)
+	pdf.SetFont(cfg.FontFamily, "B", 16)
+	pdf.Text(coverMargin, y, "Issuance summary")
+	y += 4
+	pdf.SetFont(cfg.FontFamily, "", 10)
+	for _, line := range [][2]string{
+		{"Issuer", cfg.IssuerName},
+		{"Batch", c.title},
+		{"Certificates", strconv.Itoa(len(c.entries))},
+		{"Issued", issued + " (" + loc.String() + ")"},
+	} {
+		if line[1] == "" {
+			continue
+		}
+		y += 5.5
+		pdf.SetFont(cfg.FontFamily, "B", 10)
+		pdf.Text(coverMargin, y, enc(line[0]))
+		pdf.SetFont(cfg.FontFamily, "", 10)
+		pdf.Text(coverMargin+30, y, enc(line[1]))
+	}
+	y += 5.5
+	pdf.SetFont(cfg.FontFamily, "B", 10)
+	pdf.Text(coverMargin, y, "Merkle root")
+	pdf.SetFont("Courier", "", 8.5)
+	pdf.Text(coverMargin+30, y, c.MerkleRoot())
+	y += 4.5
+	pdf.SetFont(cfg.FontFamily, "", 7)
+	pdf.Text(coverMargin+30, y, "The PDF digests below, hashed in pairs with SHA-256 up to one; an unpaired digest is carried up.")
+	y += 6
+
+	header := func() {
+		pdf.SetFont(cfg.FontFamily, "B", 8)
+		x := coverMargin
+		for _, col := range coverColumns {
+			pdf.Text(x, y, col.title)
+			x += col.width
+		}
+		pdf.SetLineWidth(0.3)
+		pdf.Line(coverMargin, y+1.2, coverWidth-coverMargin, y+1.2)
+		y += coverRow
+	}
+	page := 1
+	footer := func() {
+		pdf.SetFont(cfg.FontFamily, "", 7)
+		s := "Page " + strconv.Itoa(page)
+		pdf.Text(coverWidth-coverMargin-pdf.StringWidth(s), coverHeight-coverMargin/2, s)
+	}
+	header()
+	for i, e := range c.entries {
+		if y > coverHeight-coverMargin {
+			footer()
+			pdf.AddPage()
+			page++
+			y = coverMargin + ptToMM(8)
+			header()
+		}
+		cells := []string{strconv.Itoa(i + 1), e.Name, e.RegNumber, e.IssuedAt.In(loc).Format(time.DateOnly)}
+		x := coverMargin
+		pdf.SetFont(cfg.FontFamily, "", 8)
+		for j, s := range cells {
+			pdf.Text(x, y, fitText(pdf, enc, s, coverColumns[j].width-2))
+			x += coverColumns[j].width
+		}
+		pdf.SetFont("Courier", "", 5.5)
+		pdf.Text(x, y, e.SHA256)
+		y += coverRow
+	}
+	footer()
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// WriteFile writes the cover sheet to path, replacing it atomically.
+func (c *CoverSheet) WriteFile(path string) error {
+	_, err := writeAtomic(path, nil, c.Output)
+	return err
+}
+
+// fitText encodes s with enc, shortened with an ellipsis until it is at
+// most width mm wide in the current font.
+func fitText(pdf renderer, enc func(string) string, s string, width float64) string {
+	if t := enc(s); pdf.StringWidth(t) <= width {
+		return t
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.StringWidth(enc(string(r)+"...")) > width {
+		r = r[:len(r)-1]
+	}
+	return enc(string(r) + "...")
+}
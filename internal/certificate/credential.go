@@ -0,0 +1,70 @@
+package certificate
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// CredentialFileName is the name of the credential attached to every PDF
+// with PDF_EMBED_CREDENTIAL.
+const CredentialFileName = "credential.json"
+
+// Credential is the machine-readable form of a certificate, laid out as a
+// W3C Verifiable Credential (data model 1.1) without a proof: it is only
+// as trustworthy as the registry entry at its ID, which verifiers should
+// check.
+type Credential struct {
+	Context           []string          `json:"@context"`
+	ID                string            `json:"id"` // the verification URL
+	Type              []string          `json:"type"`
+	Issuer            CredentialIssuer  `json:"issuer"`
+	IssuanceDate      time.Time         `json:"issuanceDate"`
+	ExpirationDate    *time.Time        `json:"expirationDate,omitempty"`
+	CredentialSubject CredentialSubject `json:"credentialSubject"`
+}
+
+// CredentialIssuer identifies the issuer by its verification site.
+type CredentialIssuer struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// CredentialSubject is what the certificate says about its recipient.
+type CredentialSubject struct {
+	Name               string `json:"name"`
+	RegistrationNumber string `json:"registrationNumber"`
+	Course             string `json:"course,omitempty"`
+	Grade              string `json:"grade,omitempty"`
+}
+
+// Credential returns the credential for rec's certificate, with the same
+// values as are printed on it and recorded in the registry.
+func (g *Generator) Credential(rec Record) Credential {
+	c := Credential{
+		Context:      []string{"https://www.w3.org/2018/credentials/v1"},
+		ID:           g.cfg.VerificationURL(rec.RegNumber),
+		Type:         []string{"VerifiableCredential", "CertificateCredential"},
+		Issuer:       CredentialIssuer{ID: strings.TrimRight(g.cfg.VerificationBaseURL, "/"), Name: g.cfg.IssuerName},
+		IssuanceDate: rec.issuedAt().UTC(),
+		CredentialSubject: CredentialSubject{
+			Name:               g.cfg.NameRules.formatName(rec.Name),
+			RegistrationNumber: rec.RegNumber,
+			Course:             rec.Course,
+		},
+	}
+	if exp := g.ExpiresAt(rec); !exp.IsZero() {
+		exp = exp.UTC()
+		c.ExpirationDate = &exp
+	}
+	if g.cfg.Grade.Field != "" {
+		c.CredentialSubject.Grade = strings.TrimSpace(rec.Fields[g.cfg.Grade.Field])
+	}
+	return c
+}
+
+// credentialJSON is the attached credential file.
+func (g *Generator) credentialJSON(rec Record) []byte {
+	b, _ := json.MarshalIndent(g.Credential(rec), "", "  ")
+	return b
+}
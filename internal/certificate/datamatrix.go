@@ -0,0 +1,199 @@
+package certificate
+
+import (
+	"fmt"
+	"slices"
+)
+
+// dataMatrixSymbol is one of the square ECC 200 symbol sizes.
+type dataMatrixSymbol struct {
+	size    int // modules per side, including the finder and timing patterns
+	regions int // data regions per side
+	data    int // data codewords
+	check   int // check codewords, over all blocks
+	blocks  int // interleaved Reed-Solomon blocks
+}
+
+// dataMatrixSymbols are the square symbols, smallest first. The
+// rectangular ones are left out: certificates reserve a square for the
+// code.
+var dataMatrixSymbols = []dataMatrixSymbol{
+	{10, 1, 3, 5, 1}, {12, 1, 5, 7, 1}, {14, 1, 8, 10, 1}, {16, 1, 12, 12, 1},
+	{18, 1, 18, 14, 1}, {20, 1, 22, 18, 1}, {22, 1, 30, 20, 1}, {24, 1, 36, 24, 1},
+	{26, 1, 44, 28, 1}, {32, 2, 62, 36, 1}, {36, 2, 86, 42, 1}, {40, 2, 114, 48, 1},
+	{44, 2, 144, 56, 1}, {48, 2, 174, 68, 1}, {52, 2, 204, 84, 2}, {64, 4, 280, 112, 2},
+	{72, 4, 368, 144, 4}, {80, 4, 456, 192, 4}, {88, 4, 576, 224, 4}, {96, 4, 696, 272, 4},
+	{104, 4, 816, 336, 6}, {120, 6, 1050, 408, 6}, {132, 6, 1304, 496, 8}, {144, 6, 1558, 620, 10},
+}
+
+var dataMatrixField = newGaloisField(0x12d, 256)
+
+// encodeDataMatrix encodes content in the smallest square symbol that
+// holds it, without a quiet zone.
+func encodeDataMatrix(content []byte) ([][]bool, error) {
+	words := dataMatrixASCII(content)
+	i := slices.IndexFunc(dataMatrixSymbols, func(s dataMatrixSymbol) bool { return s.data >= len(words) })
+	if i < 0 {
+		largest := dataMatrixSymbols[len(dataMatrixSymbols)-1]
+		return nil, fmt.Errorf("%d codewords do not fit in the %d of the largest symbol", len(words), largest.data)
+	}
+	s := dataMatrixSymbols[i]
+	words = dataMatrixPad(words, s.data)
+	return s.draw(append(words, s.checkWords(words)...)), nil
+}
+
+// dataMatrixASCII encodes content in ASCII encodation: a codeword for each
+// pair of digits, for each ASCII character, and two for other bytes.
+func dataMatrixASCII(content []byte) []int {
+	digit := func(c byte) bool { return c >= '0' && c <= '9' }
+	var words []int
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case digit(c) && i+1 < len(content) && digit(content[i+1]):
+			words = append(words, 130+int(c-'0')*10+int(content[i+1]-'0'))
+			i++
+		case c < 128:
+			words = append(words, int(c)+1)
+		default:
+			words = append(words, 235, int(c)-127) // Upper Shift
+		}
+	}
+	return words
+}
+
+// dataMatrixPad fills words up to n codewords: an end of data codeword,
+// then pad codewords scrambled by their position.
+func dataMatrixPad(words []int, n int) []int {
+	if len(words) < n {
+		words = append(words, 129)
+	}
+	for len(words) < n {
+		pad := 129 + (149*(len(words)+1))%253 + 1
+		if pad > 254 {
+			pad -= 254
+		}
+		words = append(words, pad)
+	}
+	return words
+}
+
+// checkWords computes the check codewords of data, interleaving them the
+// way its codewords are spread over the blocks.
+func (s dataMatrixSymbol) checkWords(data []int) []int {
+	n := s.check / s.blocks
+	check := make([]int, s.check)
+	for b := range s.blocks {
+		var block []int
+		for i := b; i < len(data); i += s.blocks {
+			block = append(block, data[i])
+		}
+		for j, w := range dataMatrixField.checkWords(block, n) {
+			check[j*s.blocks+b] = w
+		}
+	}
+	return check
+}
+
+// draw lays out the codewords in the symbol's data regions, each framed by
+// its solid finder pattern on the left and bottom and its alternating
+// timing pattern on the top and right.
+func (s dataMatrixSymbol) draw(words []int) [][]bool {
+	d := s.size/s.regions - 2
+	bits := dataMatrixPlace(words, s.regions*d)
+	modules := newModules(s.size)
+	for ry := range s.regions {
+		for rx := range s.regions {
+			top, left := ry*(d+2), rx*(d+2)
+			for i := range d + 2 {
+				modules[top+i][left] = true
+				modules[top+d+1][left+i] = true
+				modules[top][left+i] = i%2 == 0
+				modules[top+i][left+d+1] = i%2 == 1
+			}
+		}
+	}
+	for row := range bits {
+		for col, dark := range bits[row] {
+			modules[row+1+2*(row/d)][col+1+2*(col/d)] = dark
+		}
+	}
+	return modules
+}
+
+// dataMatrixPlace places the bits of words in an n × n mapping matrix
+// along the diagonal zigzag of ISO/IEC 16022, codeword by codeword in the
+// "utah" shape or, at the edges, one of the four corner shapes.
+func dataMatrixPlace(words []int, n int) [][]bool {
+	bits, placed := newModules(n), newModules(n)
+	w := 0
+	module := func(row, col, bit int) {
+		if row < 0 {
+			row += n
+			col += 4 - (n+4)%8
+		}
+		if col < 0 {
+			col += n
+			row += 4 - (n+4)%8
+		}
+		placed[row][col] = true
+		bits[row][col] = w < len(words) && words[w]>>(7-bit)&1 == 1
+	}
+	shape := func(cells ...[2]int) {
+		for bit, c := range cells {
+			module(c[0], c[1], bit)
+		}
+		w++
+	}
+	utah := func(row, col int) {
+		shape([2]int{row - 2, col - 2}, [2]int{row - 2, col - 1}, [2]int{row - 1, col - 2}, [2]int{row - 1, col - 1},
+			[2]int{row - 1, col}, [2]int{row, col - 2}, [2]int{row, col - 1}, [2]int{row, col})
+	}
+	free := func(row, col int) bool {
+		return row >= 0 && row < n && col >= 0 && col < n && !placed[row][col]
+	}
+
+	row, col := 4, 0
+	for {
+		switch {
+		case row == n && col == 0:
+			shape([2]int{n - 1, 0}, [2]int{n - 1, 1}, [2]int{n - 1, 2}, [2]int{0, n - 2},
+				[2]int{0, n - 1}, [2]int{1, n - 1}, [2]int{2, n - 1}, [2]int{3, n - 1})
+		case row == n-2 && col == 0 && n%4 != 0:
+			shape([2]int{n - 3, 0}, [2]int{n - 2, 0}, [2]int{n - 1, 0}, [2]int{0, n - 4},
+				[2]int{0, n - 3}, [2]int{0, n - 2}, [2]int{0, n - 1}, [2]int{1, n - 1})
+		case row == n-2 && col == 0 && n%8 == 4:
+			shape([2]int{n - 3, 0}, [2]int{n - 2, 0}, [2]int{n - 1, 0}, [2]int{0, n - 2},
+				[2]int{0, n - 1}, [2]int{1, n - 1}, [2]int{2, n - 1}, [2]int{3, n - 1})
+		case row == n+4 && col == 2 && n%8 == 0:
+			shape([2]int{n - 1, 0}, [2]int{n - 1, n - 1}, [2]int{0, n - 3}, [2]int{0, n - 2},
+				[2]int{0, n - 1}, [2]int{1, n - 3}, [2]int{1, n - 2}, [2]int{1, n - 1})
+		}
+		// Up and to the right, then down and to the left
+		for {
+			if free(row, col) {
+				utah(row, col)
+			}
+			if row, col = row-2, col+2; row < 0 || col >= n {
+				break
+			}
+		}
+		row, col = row+1, col+3
+		for {
+			if free(row, col) {
+				utah(row, col)
+			}
+			if row, col = row+2, col-2; row >= n || col < 0 {
+				break
+			}
+		}
+		if row, col = row+3, col+1; row >= n && col >= n {
+			break
+		}
+	}
+	// Symbols whose matrix is not filled by whole codewords have a fixed
+	// pattern in the bottom-right corner
+	if !placed[n-1][n-1] {
+		bits[n-1][n-1], bits[n-2][n-2] = true, true
+	}
+	return bits
+}
@@ -0,0 +1,95 @@
+package certificate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDataMatrixCodewords(t *testing.T) {
+	tests := []struct {
+		content string
+		n       int // data codewords of the symbol
+		want    []int
+	}{
+		// The ISO/IEC 16022 example: digit pairs take one codeword each
+		{"123456", 3, []int{142, 164, 186}},
+		{"A", 3, []int{66, 129, 70}},      // end of data, then a scrambled pad
+		{"1a", 3, []int{50, 98, 129}},     // a lone digit is ASCII
+		{"\xe9", 3, []int{235, 106, 129}}, // Upper Shift for bytes over 127
+		{"12345", 5, []int{142, 164, 54, 129, 115}},
+	}
+	for _, tt := range tests {
+		got := dataMatrixPad(dataMatrixASCII([]byte(tt.content)), tt.n)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: codewords %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestDataMatrixCheckWords(t *testing.T) {
+	// The ISO/IEC 16022 example, "123456" in the 10 × 10 symbol
+	s := dataMatrixSymbols[0]
+	if got, want := s.checkWords([]int{142, 164, 186}), []int{114, 25, 5, 88, 102}; !slices.Equal(got, want) {
+		t.Errorf("10×10: check words %v, want %v", got, want)
+	}
+
+	// The 144 × 144 symbol spreads its codewords over ten blocks, data and
+	// check words alike round robin: the first eight blocks get 156 data
+	// words, the last two 155, and each 62 check words.
+	s = dataMatrixSymbols[len(dataMatrixSymbols)-1]
+	if s.size != 144 {
+		t.Fatalf("largest symbol is %d×%d, want 144×144", s.size, s.size)
+	}
+	data := dataMatrixPad(dataMatrixASCII([]byte("https://example.com/verify/R-1")), s.data)
+	check := s.checkWords(data)
+	if len(check) != 620 {
+		t.Fatalf("144×144: %d check words, want 620", len(check))
+	}
+	for b := range s.blocks {
+		var block, blockCheck []int
+		for i := b; i < len(data); i += s.blocks {
+			block = append(block, data[i])
+		}
+		for i := b; i < len(check); i += s.blocks {
+			blockCheck = append(blockCheck, check[i])
+		}
+		want := 156
+		if b >= 8 {
+			want = 155
+		}
+		if len(block) != want || len(blockCheck) != 62 {
+			t.Errorf("block %d: %d data and %d check words, want %d and 62", b, len(block), len(blockCheck), want)
+		}
+		checkSyndromes(t, dataMatrixField, append(block, blockCheck...), 62)
+	}
+}
+
+// TestDataMatrixFrame checks the finder and timing patterns of one and of
+// several data regions per side.
+func TestDataMatrixFrame(t *testing.T) {
+	for _, content := range []string{"123456", "https://example.com/verify/R-1"} {
+		modules, err := encodeDataMatrix([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(dataMatrixSymbols, func(s dataMatrixSymbol) bool { return s.size == len(modules) })
+		if i < 0 {
+			t.Fatalf("%q: %d modules per side is no symbol size", content, len(modules))
+		}
+		s := dataMatrixSymbols[i]
+		step := s.size / s.regions
+		for ry := range s.regions {
+			for rx := range s.regions {
+				top, left, far := ry*step, rx*step, step-1
+				for i := range step {
+					if !modules[top+i][left] || !modules[top+far][left+i] {
+						t.Fatalf("%q: region %d,%d: broken finder pattern", content, rx, ry)
+					}
+					if modules[top][left+i] != (i%2 == 0) || modules[top+i][left+far] != (i%2 == 1) {
+						t.Fatalf("%q: region %d,%d: broken timing pattern", content, rx, ry)
+					}
+				}
+			}
+		}
+	}
+}
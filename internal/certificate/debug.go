@@ -0,0 +1,48 @@
+package certificate
+
+import "fmt"
+
+// drawDebugOverlay draws a mm grid, the bounding box of every field and its
+// coordinates on top of the finished page (DEBUG_GRID=true).
+func drawDebugOverlay(pdf renderer, pageWidth, pageHeight float64, boxes []FieldBox) {
+	pdf.SetFont("Helvetica", "", 5)
+
+	// Fine lines every 5 mm, stronger labelled lines every 10 mm
+	for x := 0.0; x <= pageWidth; x += 5 {
+		gridLine(pdf, int(x)%10 == 0)
+		pdf.Line(x, 0, x, pageHeight)
+		if int(x)%10 == 0 {
+			pdf.Text(x+0.5, 2.5, fmt.Sprintf("%.0f", x))
+		}
+	}
+	for y := 0.0; y <= pageHeight; y += 5 {
+		gridLine(pdf, int(y)%10 == 0)
+		pdf.Line(0, y, pageWidth, y)
+		if int(y)%10 == 0 && y > 0 {
+			pdf.Text(0.5, y-0.5, fmt.Sprintf("%.0f", y))
+		}
+	}
+
+	// Field boxes with their origin
+	pdf.SetDrawColor(220, 0, 0)
+	pdf.SetTextColor(220, 0, 0)
+	pdf.SetFillColor(220, 0, 0)
+	pdf.SetLineWidth(0.3)
+	pdf.SetFont("Helvetica", "B", 6)
+	for _, b := range boxes {
+		pdf.Rect(b.X, b.Y, b.W, b.H, "D")
+		pdf.Circle(b.X, b.Y, 0.8, "F")
+		pdf.Text(b.X+1, b.Y-1, fmt.Sprintf("%s (%.1f, %.1f) %.1fx%.1f mm", b.Field, b.X, b.Y, b.W, b.H))
+	}
+}
+
+func gridLine(pdf renderer, major bool) {
+	if major {
+		pdf.SetDrawColor(0, 120, 255)
+		pdf.SetTextColor(0, 120, 255)
+		pdf.SetLineWidth(0.15)
+		return
+	}
+	pdf.SetDrawColor(150, 200, 255)
+	pdf.SetLineWidth(0.05)
+}
@@ -0,0 +1,68 @@
+package certificate
+
+import "image/color"
+
+// Text rendering modes, as PDF numbers them.
+const (
+	textFill       = 0
+	textFillStroke = 2
+)
+
+// TextEffects keep a line legible over busy template artwork without
+// editing the template: an outline around every glyph, and a drop shadow
+// beneath the text.
+type TextEffects struct {
+	OutlineWidth float64 // mm the outline reaches past the glyphs; 0 for none
+	OutlineColor color.RGBA
+	ShadowX      float64 // mm the shadow is offset right; with ShadowY 0, no shadow
+	ShadowY      float64 // mm the shadow is offset down
+	ShadowColor  color.RGBA
+}
+
+func (e TextEffects) shadow() bool { return e.ShadowX != 0 || e.ShadowY != 0 }
+
+// drawText prints s at f's position in c, over the shadow and outline f
+// asks for. Only the text itself is tagged: the effects are artifacts, so
+// the line is read aloud and copied once.
+func drawText(pdf renderer, f TextField, c color.RGBA, s string) {
+	f.Blend.draw(pdf, func() { drawEffects(pdf, f, c, s) })
+}
+
+// drawEffects is drawText before the field's Blend.
+func drawEffects(pdf renderer, f TextField, c color.RGBA, s string) {
+	x, y, e := f.x(), f.baseline(), f.Effects
+	if e.shadow() {
+		pdf.BeginTag("Artifact", "")
+		// The shadow takes the outlined shape, as if the outline cast it
+		strokeText(pdf, x+e.ShadowX, y+e.ShadowY, s, e.ShadowColor, e.OutlineWidth)
+		pdf.EndTag()
+	}
+	if e.OutlineWidth > 0 {
+		pdf.BeginTag("Artifact", "")
+		strokeText(pdf, x, y, s, e.OutlineColor, e.OutlineWidth)
+		pdf.EndTag()
+	}
+	setTextColor(pdf, c)
+	pdf.BeginTag("P", "")
+	pdf.Text(x, y, s)
+	pdf.EndTag()
+}
+
+// strokeText prints s filled in c and stroked width mm past its edges in
+// c. The stroke is centred on the glyph outlines, so its inner half is
+// covered by the text printed on top.
+func strokeText(pdf renderer, x, y float64, s string, c color.RGBA, width float64) {
+	setTextColor(pdf, c)
+	if width <= 0 {
+		pdf.Text(x, y, s)
+		return
+	}
+	pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+	pdf.SetLineWidth(2 * width)
+	// Round joins keep sharp corners, as in A and V, from spiking
+	pdf.SetLineJoinStyle("round")
+	pdf.SetTextRenderingMode(textFillStroke)
+	pdf.Text(x, y, s)
+	pdf.SetTextRenderingMode(textFill)
+	pdf.SetLineJoinStyle("miter")
+}
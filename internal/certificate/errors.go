@@ -0,0 +1,30 @@
+package certificate
+
+import "errors"
+
+// Errors callers can test for with errors.Is to tell failure modes apart,
+// e.g. to choose an HTTP status. They are always wrapped with the details.
+var (
+	ErrInvalidConfig    = errors.New("invalid configuration")
+	ErrTemplateNotFound = errors.New("template image not found")
+	ErrFontLoad         = errors.New("font unavailable")
+	ErrOutputExists     = errors.New("output file already exists")
+	ErrMissingGlyph     = errors.New("missing glyph")
+	ErrUnknownCourse    = errors.New("unknown course")
+	ErrConfigChanged    = errors.New("configuration changed since the certificate was issued")
+)
+
+// configError marks the joined errors of LoadConfig and Validate as
+// ErrInvalidConfig without changing their message.
+type configError struct{ error }
+
+func (e configError) Is(target error) bool { return target == ErrInvalidConfig }
+
+func (e configError) Unwrap() error { return e.error }
+
+func invalidConfig(err error) error {
+	if err == nil {
+		return nil
+	}
+	return configError{err}
+}
@@ -0,0 +1,101 @@
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// fontCache holds the custom TrueType fonts read so far, keyed by path.
+// Each file is read and checked once per process however many certificates
+// use it; the PDF engine still subsets it per document, which is what keeps every
+// PDF small.
+var fontCache sync.Map // path → *cachedFont
+
+type cachedFont struct {
+	once   sync.Once
+	data   []byte
+	glyphs *sfnt.Font // for coverage checks
+	err    error
+}
+
+// loadFont returns the contents of the TrueType font at path, reading it on
+// first use.
+func loadFont(path string) ([]byte, error) {
+	f, err := cachedFontFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.data, nil
+}
+
+func cachedFontFor(path string) (*cachedFont, error) {
+	v, _ := fontCache.LoadOrStore(path, &cachedFont{})
+	f := v.(*cachedFont)
+	f.once.Do(func() {
+		f.data, f.err = os.ReadFile(path)
+		if f.err != nil {
+			f.err = fmt.Errorf("%w: %w", ErrFontLoad, f.err)
+			return
+		}
+		if !isTrueType(f.data) {
+			f.err = fmt.Errorf("%w: %s is not a TrueType font", ErrFontLoad, path)
+			return
+		}
+		if f.glyphs, f.err = sfnt.Parse(f.data); f.err != nil {
+			f.err = fmt.Errorf("%w: %s: %w", ErrFontLoad, path, f.err)
+		}
+	})
+	return f, f.err
+}
+
+// isTrueType reports whether data starts like a TrueType (not CFF-based
+// OpenType) font, the only outlines the PDF engines can embed.
+func isTrueType(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0, 1, 0, 0}) || bytes.HasPrefix(data, []byte("true"))
+}
+
+// checkFonts reports whether the font configured in cfg can be used: a
+// built-in family, or custom font files that load.
+func (cfg Config) checkFonts() error {
+	if cfg.FontFile == "" {
+		if !coreFonts[strings.ToLower(cfg.FontFamily)] {
+			return fmt.Errorf("%w: %q is not a built-in PDF font (Helvetica, Arial, Times, Courier, Symbol, ZapfDingbats)", ErrFontLoad, cfg.FontFamily)
+		}
+		return nil
+	}
+	for _, path := range []string{cfg.FontFile, cfg.boldFontFile()} {
+		if _, err := loadFont(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boldFontFile is the font file the name is set in.
+func (cfg Config) boldFontFile() string {
+	if cfg.FontBoldFile != "" {
+		return cfg.FontBoldFile
+	}
+	return cfg.FontFile
+}
+
+// addFonts registers the custom fonts of cfg, if any, with pdf under
+// FontFamily, in the regular and bold styles the fields use.
+func (cfg Config) addFonts(pdf renderer) error {
+	if cfg.FontFile == "" {
+		return nil
+	}
+	for style, path := range map[string]string{"": cfg.FontFile, "B": cfg.boldFontFile()} {
+		data, err := loadFont(path)
+		if err != nil {
+			return err
+		}
+		pdf.AddFont(cfg.FontFamily, style, data)
+	}
+	return pdf.Error()
+}
@@ -1,186 +1,547 @@
 package certificate
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	_ "image/gif" // template formats the PDF engines accept
+	_ "image/jpeg"
 	"image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
-	"github.com/jung-kurt/gofpdf"
 	"github.com/skip2/go-qrcode"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func Generate(name, regNumber, outputDir string) (string, error) {
-	// ── Configuration from .env ─────────────────────────────────────────────
-	templatePath := os.Getenv("TEMPLATE_IMAGE")
-	fontFamily := getEnvOrDefault("FONT_FAMILY", "Helvetica")
-
-	// Get template dimensions in pixels
-	templateWidthPx, _ := strconv.ParseFloat(getEnvOrDefault("TEMPLATE_WIDTH_PX", "2500"), 64)
-	templateHeightPx, _ := strconv.ParseFloat(getEnvOrDefault("TEMPLATE_HEIGHT_PX", "1932"), 64)
-
-	// Get DPI for conversion
-	dpi, _ := strconv.ParseFloat(getEnvOrDefault("DPI", "300"), 64)
-
-	// Calculate page size in mm from pixels and DPI
-	pageWidth := (templateWidthPx / dpi) * 25.4
-	pageHeight := (templateHeightPx / dpi) * 25.4
-
-	// Ensure landscape orientation
-	if pageWidth < pageHeight {
-		pageWidth, pageHeight = pageHeight, pageWidth
-	}
-
-	// Debug output
-	fmt.Printf("Template: %.0fx%.0f px @ %.0f DPI → PDF: %.2fx%.2f mm\n",
-		templateWidthPx, templateHeightPx, dpi, pageWidth, pageHeight)
-
-	// ── Text positioning & styling ──────────────────────────────────────────
-	nameSize, _ := strconv.ParseFloat(getEnvOrDefault("NAME_SIZE", "42"), 64)
-	nameLeft, _ := strconv.ParseFloat(getEnvOrDefault("NAME_LEFT", "50"), 64)
-	nameTop, _ := strconv.ParseFloat(getEnvOrDefault("NAME_TOP", "70"), 64)
-	nameR, _ := strconv.Atoi(getEnvOrDefault("NAME_COLOR_R", "0"))
-	nameG, _ := strconv.Atoi(getEnvOrDefault("NAME_COLOR_G", "0"))
-	nameB, _ := strconv.Atoi(getEnvOrDefault("NAME_COLOR_B", "0"))
-
-	regSize, _ := strconv.ParseFloat(getEnvOrDefault("REG_SIZE", "18"), 64)
-	regLeft, _ := strconv.ParseFloat(getEnvOrDefault("REG_LEFT", "50"), 64)
-	regTop, _ := strconv.ParseFloat(getEnvOrDefault("REG_TOP", "110"), 64)
-	regR, _ := strconv.Atoi(getEnvOrDefault("REG_COLOR_R", "0"))
-	regG, _ := strconv.Atoi(getEnvOrDefault("REG_COLOR_G", "0"))
-	regB, _ := strconv.Atoi(getEnvOrDefault("REG_COLOR_B", "0"))
-
-	qrLeft, _ := strconv.ParseFloat(getEnvOrDefault("QR_LEFT", "160"), 64)
-	qrTop, _ := strconv.ParseFloat(getEnvOrDefault("QR_TOP", "110"), 64)
-	qrSize, _ := strconv.Atoi(getEnvOrDefault("QR_SIZE", "180"))
-	qrLevelStr := getEnvOrDefault("QR_ERROR_CORRECTION", "M")
+// TemplateSafety is the default TEMPLATE_INSET in mm, a safety buffer
+// against printers clipping the template's edges (adjust 1.0–3.0 mm based
+// on testing). Full-bleed templates want 0.
+const TemplateSafety = 1.0
+
+// drawTemplate draws the template image TEMPLATE_INSET in from the page
+// edges, fitted as TEMPLATE_FIT says.
+func drawTemplate(pdf renderer, cfg Config, pageWidth, pageHeight float64) {
+	in := cfg.TemplateInset
+	x, y, w, h := in, in, pageWidth-2*in, pageHeight-2*in
+	fit := strings.ToLower(cfg.TemplateFit)
+	if fit == FitContain || fit == FitCover {
+		iw, ih, err := imageSize(cfg.TemplateImage)
+		if err != nil || iw == 0 || ih == 0 {
+			// Left to ImageFile, which reports an unreadable image
+			pdf.ImageFile(cfg.TemplateImage, x, y, w, h)
+			return
+		}
+		scale := math.Min(w/float64(iw), h/float64(ih))
+		if fit == FitCover {
+			scale = math.Max(w/float64(iw), h/float64(ih))
+		}
+		sw, sh := float64(iw)*scale, float64(ih)*scale
+		if fit == FitCover {
+			pdf.TransformBegin()
+			pdf.ClipRect(x, y, w, h, false)
+			pdf.ImageFile(cfg.TemplateImage, x+(w-sw)/2, y+(h-sh)/2, sw, sh)
+			pdf.ClipEnd()
+			pdf.TransformEnd()
+			return
+		}
+		x, y, w, h = x+(w-sw)/2, y+(h-sh)/2, sw, sh
+	}
+	pdf.ImageFile(cfg.TemplateImage, x, y, w, h)
+}
 
-	// ── Generate QR ─────────────────────────────────────────────────────────
-	baseURL := getEnvOrDefault("VERIFICATION_BASE_URL", "https://peaceandhumanity.org/verification")
-	baseURL = strings.TrimRight(baseURL, "/")
-	verifyURL := fmt.Sprintf("%s#%s", baseURL, regNumber)
+// Generator renders certificates from a validated Config and reports
+// progress to its logger.
+//
+// A Generator is safe for concurrent use: its Config is never modified
+// after New, every call builds its own PDF document and QR image in memory,
+// and PDFs are written through uniquely named temp files. Concurrent calls
+// for the same registration number honour OUTPUT_EXISTS as sequential ones
+// would.
+type Generator struct {
+	cfg        Config
+	logger     *slog.Logger
+	regTmpl    *template.Template
+	translit   map[rune]string
+	gradeRules []gradeRule
+	courses    map[string]*Generator // by course code, from COURSE_CATALOG
+
+	assets     map[string]string // digests of the asset files, by path
+	configHash string
+	profile    *SidecarProfile // the course g renders, for sidecars
+}
 
-	qr, err := qrcode.New(verifyURL, getQRLevel(qrLevelStr))
+// Option configures a Generator.
+type Option func(*Generator)
+
+// WithLogger sets the logger used for progress and debug output.
+func WithLogger(l *slog.Logger) Option {
+	return func(g *Generator) { g.logger = l }
+}
+
+// New returns a Generator for cfg, or the validation errors that would make
+// its output wrong. Without WithLogger it logs to slog.Default().
+func New(cfg Config, opts ...Option) (*Generator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	regTmpl, err := parseRegTemplate(cfg.RegTemplate)
+	if err != nil {
+		return nil, err // reported by Validate
+	}
+	translit, err := parseTransliterations(cfg.Transliterations)
+	if err != nil {
+		return nil, err
+	}
+	gradeRules, err := parseGradeRules(cfg.Grade.Rules)
 	if err != nil {
-		return "", fmt.Errorf("QR creation failed: %w", err)
+		return nil, err
+	}
+	g := &Generator{cfg: cfg, regTmpl: regTmpl, translit: translit, gradeRules: gradeRules}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.assets = cfg.digestAssets()
+	g.configHash = cfg.hash(g.assets)
+	if cfg.courses != nil {
+		g.courses = make(map[string]*Generator, len(cfg.courses))
+	}
+	for code, c := range cfg.courses {
+		cg, err := New(c.cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("course %s: %w", code, err)
+		}
+		cg.profile = &SidecarProfile{CourseCode: code, File: c.Profile, SHA256: c.profileSHA256}
+		g.courses[code] = cg
+	}
+	return g, nil
+}
+
+// Config returns the configuration g was created with.
+func (g *Generator) Config() Config {
+	return g.cfg
+}
+
+// Check reports whether the assets g renders with are still usable: the
+// template image must exist and decode, and the font must be available.
+// Validate only checked them when g was created.
+func (g *Generator) Check() error {
+	if err := g.cfg.checkFonts(); err != nil {
+		return err
+	}
+	if g.cfg.TemplateImage == "" {
+		return nil
+	}
+	f, err := os.Open(g.cfg.TemplateImage)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrTemplateNotFound, err)
+	} else if err != nil {
+		return fmt.Errorf("template image: %w", err)
+	}
+	defer f.Close()
+	if _, _, err := image.DecodeConfig(f); err != nil {
+		return fmt.Errorf("template image %s: %w", g.cfg.TemplateImage, err)
+	}
+	return nil
+}
+
+func (g *Generator) log() *slog.Logger {
+	if g.logger == nil {
+		return slog.Default()
 	}
+	return g.logger
+}
 
-	// Get QR as image (this gives us black modules on white bg by default)
-	img := qr.Image(qrSize) // qrSize is the pixel size you want
+// Generate renders the certificate for name and regNumber using the
+// configuration in the environment.
+func Generate(name, regNumber, outputDir string) (string, error) {
+	return GenerateRecord(Record{Name: name, RegNumber: regNumber}, outputDir)
+}
 
-	// Custom colors from .env
-	fgR, _ := strconv.Atoi(getEnvOrDefault("QR_FG_R", "0"))
-	fgG, _ := strconv.Atoi(getEnvOrDefault("QR_FG_G", "0"))
-	fgB, _ := strconv.Atoi(getEnvOrDefault("QR_FG_B", "0"))
-	fgA, _ := strconv.Atoi(getEnvOrDefault("QR_FG_A", "255"))
+// GenerateRecord renders rec using the configuration in the environment.
+func GenerateRecord(rec Record, outputDir string) (string, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return "", fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	g, err := New(cfg)
+	if err != nil {
+		return "", err
+	}
+	res, err := g.Generate(rec, outputDir)
+	return res.Path, err
+}
 
-	bgR, _ := strconv.Atoi(getEnvOrDefault("QR_BG_R", "0"))
-	bgG, _ := strconv.Atoi(getEnvOrDefault("QR_BG_G", "0"))
-	bgB, _ := strconv.Atoi(getEnvOrDefault("QR_BG_B", "0"))
-	bgA, _ := strconv.Atoi(getEnvOrDefault("QR_BG_A", "0"))
+// Generate renders the certificate for rec. When OUTPUT_DIR_TEMPLATE is set
+// the PDF is written to the record-derived subdirectory of outputDir.
+func (g *Generator) Generate(rec Record, outputDir string) (GenerateResult, error) {
+	return g.GenerateContext(context.Background(), rec, outputDir)
+}
 
-	// Create new image with desired background (usually transparent)
-	customImg := image.NewRGBA(image.Rect(0, 0, qrSize, qrSize))
+// GenerateContext is Generate with each stage traced as a child span of
+// the span in ctx.
+func (g *Generator) GenerateContext(ctx context.Context, rec Record, outputDir string) (res GenerateResult, err error) {
+	ctx, span := tracer.Start(ctx, "certificate.Generate",
+		trace.WithAttributes(attribute.String("certgen.reg_number", rec.RegNumber)))
+	defer func() { endSpan(span, err) }()
 
-	// Fill background
-	bgColor := color.RGBA{uint8(bgR), uint8(bgG), uint8(bgB), uint8(bgA)}
-	draw.Draw(customImg, customImg.Bounds(), &image.Uniform{C: bgColor}, image.Point{}, draw.Src)
+	start := time.Now()
+	if g, rec, err = g.forRecord(rec); err != nil {
+		return GenerateResult{}, err
+	}
+	cfg := g.cfg
+	regNumber := rec.RegNumber
+	if cfg.Sidecar && rec.IssuedAt.IsZero() {
+		rec.IssuedAt = start // so the PDF and its sidecar agree
+	}
+	res = g.newResult(rec)
 
-	// Draw QR modules with custom foreground color
-	fgColor := color.RGBA{uint8(fgR), uint8(fgG), uint8(fgB), uint8(fgA)}
+	outputDir, err = resolveOutputDir(outputDir, cfg.OutputDirTemplate, rec, cfg.Location())
+	if err != nil {
+		return GenerateResult{}, err
+	}
 
-	for y := 0; y < qrSize; y++ {
-		for x := 0; x < qrSize; x++ {
-			if img.At(x, y) == color.Black { // original QR uses black for modules
-				customImg.Set(x, y, fgColor)
-			}
-			// Transparent/white pixels stay as background color
+	filename := sanitize(regNumber + ".pdf")
+	outputPath, skip, err := resolveOutputPath(outputDir, filename, cfg.OutputExists)
+	if err != nil {
+		return GenerateResult{}, err
+	}
+	if skip {
+		span.SetAttributes(attribute.Bool("certgen.skipped", true))
+		if res.SHA256, res.Size, err = fileDigest(outputPath); err != nil {
+			return GenerateResult{}, fmt.Errorf("existing PDF unreadable: %w", err)
 		}
+		res.Path, res.Skipped, res.Duration = outputPath, true, time.Since(start)
+		g.log().Info("pdf exists, skipped", "reg_number", regNumber, "path", outputPath)
+		return res, nil
 	}
 
-	// Save the custom image
-	tempQRPath := filepath.Join(outputDir, "temp_qr_"+regNumber+".png")
-	f, err := os.Create(tempQRPath)
+	pdf, err := g.build(ctx, rec)
 	if err != nil {
-		return "", fmt.Errorf("cannot create temp QR file: %w", err)
+		return GenerateResult{}, err
 	}
-	defer f.Close()
 
-	if err := png.Encode(f, customImg); err != nil {
-		return "", fmt.Errorf("cannot encode custom QR: %w", err)
+	// ── Save PDF ────────────────────────────────────────────────────────────
+	_, save := tracer.Start(ctx, "certificate.save")
+	var d *digest
+	outputPath, err = writeAtomic(outputPath, onConflict(outputDir, filename, cfg.OutputExists), func(w io.Writer) error {
+		d = newDigest(w)
+		return pdf.Output(d)
+	})
+	endSpan(save, err)
+	if err != nil {
+		return GenerateResult{}, fmt.Errorf("PDF save failed: %w", err)
+	}
+	res.Path, res.Size, res.SHA256 = outputPath, d.n, d.sum()
+	if cfg.Sidecar {
+		if res.SidecarPath, err = g.writeSidecar(rec, res); err != nil {
+			return GenerateResult{}, fmt.Errorf("sidecar save failed: %w", err)
+		}
+	}
+	res.Duration = time.Since(start)
+
+	g.log().Info("pdf generated", "reg_number", regNumber, "path", outputPath)
+
+	return res, nil
+}
+
+// Render writes the certificate PDF for rec to w.
+func (g *Generator) Render(w io.Writer, rec Record) (GenerateResult, error) {
+	start := time.Now()
+	g, rec, err := g.forRecord(rec)
+	if err != nil {
+		return GenerateResult{}, err
 	}
-	defer os.Remove(tempQRPath)
+	pdf, err := g.build(context.Background(), rec)
+	if err != nil {
+		return GenerateResult{}, err
+	}
+	d := newDigest(w)
+	if err := pdf.Output(d); err != nil {
+		return GenerateResult{}, err
+	}
+	res := g.newResult(rec)
+	res.Size, res.SHA256, res.Duration = d.n, d.sum(), time.Since(start)
+	return res, nil
+}
+
+// Regenerate writes rec's certificate to path, replacing whatever is there,
+// regardless of OUTPUT_EXISTS and OUTPUT_DIR_TEMPLATE. It is for restoring
+// an issued PDF where the registry says it is: with the record and
+// configuration it was issued with, the PDF is the same byte for byte.
+func (g *Generator) Regenerate(ctx context.Context, rec Record, path string) (res GenerateResult, err error) {
+	ctx, span := tracer.Start(ctx, "certificate.Regenerate",
+		trace.WithAttributes(attribute.String("certgen.reg_number", rec.RegNumber)))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	if g, rec, err = g.forRecord(rec); err != nil {
+		return GenerateResult{}, err
+	}
+	res = g.newResult(rec)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return GenerateResult{}, fmt.Errorf("cannot create output directory: %w", err)
+	}
+	pdf, err := g.build(ctx, rec)
+	if err != nil {
+		return GenerateResult{}, err
+	}
+	var d *digest
+	if _, err = writeAtomic(path, nil, func(w io.Writer) error {
+		d = newDigest(w)
+		return pdf.Output(d)
+	}); err != nil {
+		return GenerateResult{}, fmt.Errorf("PDF save failed: %w", err)
+	}
+	res.Path, res.Size, res.SHA256 = path, d.n, d.sum()
+	if g.cfg.Sidecar {
+		if res.SidecarPath, err = g.writeSidecar(rec, res); err != nil {
+			return GenerateResult{}, fmt.Errorf("sidecar save failed: %w", err)
+		}
+	}
+	res.Duration = time.Since(start)
+
+	g.log().Info("pdf regenerated", "reg_number", rec.RegNumber, "path", path)
+	return res, nil
+}
+
+// build lays out the complete certificate document for rec.
+func (g *Generator) build(ctx context.Context, rec Record) (renderer, error) {
+	cfg := g.cfg
+
+	// Page size in mm from pixels and DPI, in the configured orientation
+	pageWidth, pageHeight := cfg.PageSize()
+	paperWidth, paperHeight := cfg.PaperSize()
+
+	g.log().Debug("page layout",
+		"template_px", fmt.Sprintf("%.0fx%.0f", cfg.TemplateWidthPx, cfg.TemplateHeightPx),
+		"dpi", cfg.DPI,
+		"page_mm", fmt.Sprintf("%.2fx%.2f", pageWidth, pageHeight),
+		"paper_mm", fmt.Sprintf("%.2fx%.2f", paperWidth, paperHeight))
 
 	// ── Create PDF ──────────────────────────────────────────────────────────
-	// Keep the working reversed setup (this forces landscape correctly)
-	pdf := gofpdf.NewCustom(&gofpdf.InitType{
-		OrientationStr: "L",
-		UnitStr:        "mm",
-		Size: gofpdf.SizeType{
-			Wd: pageHeight, // smaller value
-			Ht: pageWidth,  // larger value
-		},
-	})
+	pdf := newRenderer(cfg.PDFEngine, paperWidth, paperHeight)
+	if err := cfg.addFonts(pdf); err != nil {
+		return nil, err
+	}
+	locale, err := g.locale(rec)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tagged {
+		pdf.SetTagged(locale)
+	}
+	// Dated when issued, so rendering the record again reproduces the PDF
+	issued := g.issued(rec)
+	pdf.SetCreationDate(issued)
+	pdf.SetModificationDate(issued)
+	pdf.SetMetadata(g.xmp(rec))
+	if cfg.EmbedCredential {
+		pdf.Attach(CredentialFileName, "Machine-readable certificate data", g.credentialJSON(rec))
+	}
+
+	if err := g.drawPage(ctx, pdf, rec, locale, "qr"); err != nil {
+		return nil, err
+	}
+	return pdf, pdf.Error()
+}
+
+// drawPage is draw on a page of PaperSize, onto which the certificate is
+// scaled when PAGE_SIZE names other paper than the template's.
+func (g *Generator) drawPage(ctx context.Context, pdf renderer, rec Record, locale, qrName string) error {
+	x, y, scale := g.cfg.paperFit()
+	if x == 0 && y == 0 && scale == 1 {
+		return g.draw(ctx, pdf, rec, locale, qrName)
+	}
+	pdf.TransformBegin()
+	pdf.TransformTranslate(x, y)
+	pdf.TransformScale(scale*100, scale*100, 0, 0)
+	err := g.draw(ctx, pdf, rec, locale, qrName)
+	pdf.TransformEnd()
+	return err
+}
+
+// draw lays out rec's certificate on the current page of pdf, with its
+// top-left corner at the origin. qrName registers the QR image, and must be
+// unique within the document.
+func (g *Generator) draw(ctx context.Context, pdf renderer, rec Record, locale, qrName string) error {
+	cfg := g.cfg
+	regNumber := rec.RegNumber
+	text, err := g.lines(rec)
+	if err != nil {
+		return err
+	}
+	if cfg.NameRules.tooLong(text.name) {
+		g.log().Warn("name longer than NAME_MAX_LENGTH", "reg_number", regNumber,
+			"name", text.name, "max", cfg.NameRules.MaxLength)
+	}
+	pageWidth, pageHeight := cfg.PageSize()
 
-	pdf.SetMargins(0, 0, 0)
-	pdf.SetAutoPageBreak(false, 0)
-	pdf.AddPage()
-
-	// Safety buffer to avoid edge clipping (adjust 1.0–3.0 mm based on testing)
-	const safety = 1.0
-
-	if templatePath != "" {
-		if _, err := os.Stat(templatePath); err == nil {
-			pdf.ImageOptions(
-				templatePath,
-				safety, safety, // shift inward a tiny bit from left/top
-				pageWidth-safety*2, pageHeight-safety*2, // shrink very slightly to fit inside safety zone
-				false,
-				gofpdf.ImageOptions{ImageType: "", ReadDpi: false},
-				0, "",
-			)
+	// ── Generate QR ─────────────────────────────────────────────────────────
+	_, span := tracer.Start(ctx, "certificate.qr")
+	qrPNG, err := g.qrImage(g.QRPayload(rec))
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Background.drawn() {
+		pdf.BeginTag("Artifact", "")
+		cfg.Background.draw(pdf, pageWidth, pageHeight)
+		pdf.EndTag()
+	}
+
+	_, span = tracer.Start(ctx, "certificate.template",
+		trace.WithAttributes(attribute.String("certgen.template", cfg.TemplateImage)))
+	if cfg.TemplateImage != "" {
+		if _, err := os.Stat(cfg.TemplateImage); err == nil {
+			pdf.BeginTag("Figure", catalog[locale][LabelTemplateAlt])
+			drawTemplate(pdf, cfg, pageWidth, pageHeight)
+			pdf.EndTag()
 		} else {
-			return "", fmt.Errorf("template image not found: %s", templatePath)
+			err := fmt.Errorf("%w: %s", ErrTemplateNotFound, cfg.TemplateImage)
+			endSpan(span, err)
+			return err
 		}
 	}
+	endSpan(span, pdf.Error())
+
+	// ── Text layout ─────────────────────────────────────────────────────────
+	_, span = tracer.Start(ctx, "certificate.text")
+	enc := cfg.encoder(pdf)
 
 	// ── Name (fixed left position - no centering) ───────────────────────────
-	pdf.SetFont(fontFamily, "B", nameSize)
-	pdf.SetTextColor(nameR, nameG, nameB)
-	pdf.SetXY(nameLeft, nameTop)
-	pdf.Cell(0, nameSize, name) // 0 = auto width, no forced centering
+	pdf.SetFont(cfg.FontFamily, "B", cfg.Name.Size)
+	drawText(pdf, cfg.Name, cfg.Name.Color, enc(text.name))
 
 	// ── Registration Number (fixed left position - no centering) ────────────
-	regText := "Registration Number : " + regNumber
-	pdf.SetFont(fontFamily, "", regSize)
-	pdf.SetTextColor(regR, regG, regB)
-	pdf.SetXY(regLeft, regTop)
-	pdf.Cell(0, regSize, regText)
+	if text.reg != "" {
+		pdf.SetFont(cfg.FontFamily, "", cfg.Reg.Size)
+		drawText(pdf, cfg.Reg, cfg.Reg.Color, enc(text.reg))
+	}
+
+	// ── Grade (when GRADE_FIELD is set) ─────────────────────────────────────
+	if text.grade != "" {
+		pdf.SetFont(cfg.FontFamily, "", cfg.Grade.Size)
+		drawText(pdf, cfg.Grade.TextField, text.gradeColor, enc(text.grade))
+	}
+
+	// ── Expiry (only for certificates that expire) ──────────────────────────
+	if text.expiry != "" {
+		pdf.SetFont(cfg.FontFamily, "", cfg.Expiry.Size)
+		drawText(pdf, cfg.Expiry, cfg.Expiry.Color, enc(text.expiry))
+	}
+
+	// ── Signatories (from the record's course) ──────────────────────────────
+	cfg.Signatory.Blend.draw(pdf, func() { drawSignatories(pdf, cfg, enc) })
+	endSpan(span, pdf.Error())
+
+	// ── Image (when IMAGE_FIELD is set) ─────────────────────────────────────
+	if text.image != "" {
+		x, y := cfg.Image.origin(text.imageW, text.imageH)
+		pdf.BeginTag("Figure", text.imageAlt)
+		cfg.Image.Blend.draw(pdf, func() { pdf.ImageFile(text.image, x, y, text.imageW, text.imageH) })
+		pdf.EndTag()
+	}
 
 	// ── QR Code ─────────────────────────────────────────────────────────────
-	qrSizeMM := float64(qrSize) * 25.4 / dpi
-	if _, err := os.Stat(tempQRPath); err == nil {
-		pdf.ImageOptions(tempQRPath, qrLeft, qrTop, qrSizeMM, qrSizeMM, false,
-			gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: false}, 0, "")
+	qrSizeMM := cfg.QRSizeMM()
+	pdf.BeginTag("Figure", catalog[locale][LabelQRAlt])
+	cfg.QR.Blend.draw(pdf, func() { pdf.Image(qrName, qrPNG, cfg.QR.Left, cfg.QR.Top, qrSizeMM, qrSizeMM) })
+	pdf.EndTag()
+
+	if cfg.DebugGrid {
+		pdf.BeginTag("Artifact", "")
+		drawDebugOverlay(pdf, pageWidth, pageHeight, g.measure(pdf, text))
+		pdf.EndTag()
 	}
 
-	// ── Save PDF ────────────────────────────────────────────────────────────
-	filename := sanitize(regNumber + ".pdf")
-	outputPath := filepath.Join(outputDir, filename)
+	return pdf.Error()
+}
 
-	err = pdf.OutputFileAndClose(outputPath)
+// qrImage renders the QR code for content as a PNG in the configured
+// colors and style.
+func (g *Generator) qrImage(content string) (*bytes.Buffer, error) {
+	cfg := g.cfg
+	sym, err := g.code(content)
 	if err != nil {
-		return "", fmt.Errorf("PDF save failed: %w", err)
+		return nil, err
+	}
+	if cfg.QR.styled() || sym.qr == nil {
+		var qrPNG bytes.Buffer
+		if err := png.Encode(&qrPNG, cfg.QR.styledImage(sym.modules, sym.eyes, cfg.qrPixels())); err != nil {
+			return nil, fmt.Errorf("cannot encode custom QR: %w", err)
+		}
+		return &qrPNG, nil
 	}
 
-	fmt.Printf("PDF generated: %s\n", filename)
+	// go-qrcode renders a two-entry paletted image, so the custom colors are
+	// just its palette and no pixel needs recoloring
+	qr := sym.qr
+	qr.BackgroundColor = cfg.QR.Background
+	qr.ForegroundColor = cfg.QR.Foreground
+	var img image.Image = qr.Image(cfg.qrPixels())
+
+	// The PDF engines keep only fully transparent palette entries; anything
+	// translucent needs an alpha channel
+	if translucent(cfg.QR.Foreground) || translucent(cfg.QR.Background) {
+		rgba := image.NewNRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
+		img = rgba
+	}
 
-	return outputPath, nil
+	// Encode the custom image; it is registered with the PDF from memory
+	var qrPNG bytes.Buffer
+	if err := png.Encode(&qrPNG, img); err != nil {
+		return nil, fmt.Errorf("cannot encode custom QR: %w", err)
+	}
+	return &qrPNG, nil
+}
+
+// code encodes content in the configured symbology and error correction
+// level, and checks that its modules print at least QR_MIN_MODULE mm wide.
+func (g *Generator) code(content string) (symbol, error) {
+	cfg := g.cfg
+	sym, err := cfg.QR.encodeSymbol(content)
+	if err != nil {
+		return symbol{}, err
+	}
+	// The modules include the quiet zone, which takes up the size as well
+	n := len(sym.modules)
+	if module := cfg.QRSizeMM() / float64(n); module < cfg.QR.MinModule {
+		size := "QR_SIZE"
+		if cfg.QR.SizeMM > 0 {
+			size = "QR_SIZE_MM"
+		}
+		desc, hint := fmt.Sprintf("%s of %d modules", sym.name, n), "enlarge "+size
+		if sym.level != "" {
+			desc += " at error correction " + sym.level
+			hint += " or lower QR_ERROR_CORRECTION"
+		}
+		return symbol{}, fmt.Errorf("%s: %.2f mm modules at %.1f mm are below QR_MIN_MODULE %g mm; %s",
+			desc, module, cfg.QRSizeMM(), cfg.QR.MinModule, hint)
+	}
+	return sym, nil
+}
+
+func translucent(c color.RGBA) bool {
+	return c.A != 0 && c.A != 255
+}
+
+func setTextColor(pdf renderer, c color.RGBA) {
+	pdf.SetTextColor(int(c.R), int(c.G), int(c.B))
 }
 
 func getQRLevel(level string) qrcode.RecoveryLevel {
@@ -198,11 +559,18 @@ func getQRLevel(level string) qrcode.RecoveryLevel {
 	}
 }
 
-func getEnvOrDefault(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// qrLevelName is QR_ERROR_CORRECTION's letter for level.
+func qrLevelName(level qrcode.RecoveryLevel) string {
+	switch level {
+	case qrcode.Low:
+		return "L"
+	case qrcode.High:
+		return "Q"
+	case qrcode.Highest:
+		return "H"
+	default:
+		return "M"
 	}
-	return fallback
 }
 
 func sanitize(s string) string {
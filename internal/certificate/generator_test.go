@@ -0,0 +1,178 @@
+package certificate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// TestGenerateConcurrent generates one registration number from many
+// goroutines at once under each OUTPUT_EXISTS policy. Run it with -race.
+func TestGenerateConcurrent(t *testing.T) {
+	const workers = 16
+	for _, policy := range []string{ExistsOverwrite, ExistsSkip, ExistsError, ExistsVersion} {
+		t.Run(policy, func(t *testing.T) {
+			g := newTestGenerator(t, map[string]string{"OUTPUT_EXISTS": policy})
+			dir := t.TempDir()
+			rec := Record{Name: "Ada Lovelace", RegNumber: "R-1"}
+
+			var wg sync.WaitGroup
+			results := make([]GenerateResult, workers)
+			errs := make([]error, workers)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i], errs[i] = g.Generate(rec, dir)
+				}()
+			}
+			wg.Wait()
+
+			var ok []GenerateResult
+			for i, err := range errs {
+				switch {
+				case err == nil:
+					ok = append(ok, results[i])
+				case policy == ExistsError && errors.Is(err, ErrOutputExists):
+				default:
+					t.Errorf("Generate: %v", err)
+				}
+			}
+			if policy == ExistsError && len(ok) != 1 {
+				t.Errorf("%d generations succeeded, want 1", len(ok))
+			}
+			if policy != ExistsError && len(ok) != workers {
+				t.Errorf("%d of %d generations succeeded", len(ok), workers)
+			}
+
+			// version keeps every generation, the other policies leave one file.
+			want := []string{"R-1.pdf"}
+			if policy == ExistsVersion {
+				for n := 2; n <= workers; n++ {
+					want = append(want, fmt.Sprintf("R-1 (%d).pdf", n))
+				}
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			slices.Sort(want)
+			if !slices.Equal(names, want) {
+				t.Fatalf("output directory has %q, want %q", names, want)
+			}
+
+			sums := make(map[string]string, len(want)) // path → digest
+			for _, name := range want {
+				path := filepath.Join(dir, name)
+				checkPDF(t, path)
+				if sums[path], err = FileSHA256(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if policy == ExistsVersion {
+				// No two generations may claim, or overwrite, the same file.
+				seen := make(map[string]bool, len(ok))
+				for _, res := range ok {
+					if seen[res.Path] {
+						t.Errorf("two generations wrote %s", res.Path)
+					}
+					seen[res.Path] = true
+					if sum, found := sums[res.Path]; !found || sum != res.SHA256 {
+						t.Errorf("%s has digest %s, its generation returned %s", res.Path, sum, res.SHA256)
+					}
+				}
+				return
+			}
+			path := filepath.Join(dir, "R-1.pdf")
+			found := false
+			for _, res := range ok {
+				found = found || res.SHA256 == sums[path]
+			}
+			if !found {
+				t.Errorf("R-1.pdf has digest %s, which no generation returned", sums[path])
+			}
+		})
+	}
+}
+
+// newTestGenerator returns a Generator for the default configuration with
+// settings applied, without a template image.
+func newTestGenerator(tb testing.TB, settings map[string]string) *Generator {
+	tb.Helper()
+	cfg, err := LoadConfig(MapSource(settings))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	g, err := New(cfg, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return g
+}
+
+// checkPDF fails unless path holds a whole PDF file.
+func checkPDF(t *testing.T, path string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("%PDF-")) || !bytes.HasSuffix(bytes.TrimSpace(b), []byte("%%EOF")) {
+		t.Errorf("%s is not a whole PDF file (%d bytes)", path, len(b))
+	}
+}
+
+// BenchmarkQRImage compares qrImage, which renders go-qrcode's paletted
+// image in the configured colors, with recoloring it pixel by pixel into
+// an RGBA copy, as it used to.
+func BenchmarkQRImage(b *testing.B) {
+	g := newTestGenerator(b, map[string]string{"QR_FG_R": "20", "QR_SIZE": "600"})
+	content := g.QRPayload(Record{Name: "Ada Lovelace", RegNumber: "R-1"})
+	b.Run("palette", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := g.qrImage(content); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("recolor", func(b *testing.B) {
+		cfg := g.cfg
+		for b.Loop() {
+			qr, err := qrcode.New(content, cfg.QR.recoveryLevel())
+			if err != nil {
+				b.Fatal(err)
+			}
+			size := cfg.qrPixels()
+			img := qr.Image(size)
+			out := image.NewRGBA(image.Rect(0, 0, size, size))
+			draw.Draw(out, out.Bounds(), &image.Uniform{C: cfg.QR.Background}, image.Point{}, draw.Src)
+			for y := range size {
+				for x := range size {
+					if img.At(x, y) == color.Black {
+						out.Set(x, y, cfg.QR.Foreground)
+					}
+				}
+			}
+			if err := png.Encode(io.Discard, out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package certificate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Policies for MISSING_GLYPHS, applied when the font cannot print a
+// character of a line.
+const (
+	GlyphsError         = "error"         // refuse to generate (default)
+	GlyphsTransliterate = "transliterate" // "ő" becomes "o", "Ł" becomes "L"
+)
+
+// transliterations covers letters that do not decompose into a base letter
+// and combining marks.
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ı': "i", 'ħ': "h", 'Ħ': "H",
+	'‘': "'", '’': "'", '“': `"`, '”': `"`, '–': "-", '—': "-",
+}
+
+// parseTransliterations reads TRANSLITERATIONS, "ő=o, ű=u", into a map
+// consulted before the built-in table.
+func parseTransliterations(s string) (map[rune]string, error) {
+	m := map[rune]string{}
+	for _, pair := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		from, to, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		runes := []rune(from)
+		if !ok || len(runes) != 1 {
+			return nil, fmt.Errorf("%q is not CHAR=REPLACEMENT", pair)
+		}
+		m[runes[0]] = to
+	}
+	return m, nil
+}
+
+// covers reports which characters the font printing a line has glyphs for.
+type covers func(rune) bool
+
+// coverage returns the glyph coverage of the regular and bold faces of cfg.
+// The built-in fonts print cp1252.
+func (cfg Config) coverage() (regular, bold covers, err error) {
+	if cfg.FontFile == "" {
+		cp1252 := func(r rune) bool {
+			_, ok := charmap.Windows1252.EncodeRune(r)
+			return ok
+		}
+		return cp1252, cp1252, nil
+	}
+	regular, err = fontCoverage(cfg.FontFile)
+	if err == nil {
+		bold, err = fontCoverage(cfg.boldFontFile())
+	}
+	return regular, bold, err
+}
+
+func fontCoverage(path string) (covers, error) {
+	f, err := cachedFontFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return func(r rune) bool {
+		i, err := f.glyphs.GlyphIndex(nil, r)
+		return err == nil && i != 0
+	}, nil
+}
+
+// printable checks that every character of text has a glyph, after
+// transliteration under MISSING_GLYPHS=transliterate, and returns the text
+// to print. field names the line in errors.
+func (g *Generator) printable(field, text string, has covers) (string, error) {
+	if strings.EqualFold(g.cfg.MissingGlyphs, GlyphsTransliterate) {
+		text = transliterate(text, has, g.translit)
+	}
+	var missing strings.Builder
+	for _, r := range text {
+		if !has(r) && !unicode.IsSpace(r) && !strings.ContainsRune(missing.String(), r) {
+			missing.WriteRune(r)
+		}
+	}
+	if missing.Len() > 0 {
+		return "", fmt.Errorf("%w: the font cannot print %q in the %s %q", ErrMissingGlyph, missing.String(), field, text)
+	}
+	return text, nil
+}
+
+// transliterate replaces every character of s the font lacks: from extra,
+// then the built-in table, then by dropping the accents of its
+// canonical decomposition ("ő" is "o" plus a double acute). Characters
+// with no replacement are kept, for printable to report.
+func transliterate(s string, has covers, extra map[rune]string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if has(r) || unicode.IsSpace(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if repl, ok := extra[r]; ok {
+			b.WriteString(repl)
+			continue
+		}
+		if repl, ok := transliterations[r]; ok {
+			b.WriteString(repl)
+			continue
+		}
+		base := strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, norm.NFD.String(string(r)))
+		if base == "" || !allCovered(base, has) {
+			base = string(r)
+		}
+		b.WriteString(base)
+	}
+	return b.String()
+}
+
+func allCovered(s string, has covers) bool {
+	for _, r := range s {
+		if !has(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// gradeRule restyles a grade value: "Distinction" in gold, "Pass" in black.
+type gradeRule struct {
+	op       string     // "", ">=", ">", "<=", "<", or "*" for anything
+	value    string     // literal to compare, or the number for op
+	num      float64    // value parsed, for numeric ops
+	text     string     // printed instead of the value; empty prints the value
+	color    color.RGBA // replaces GRADE_COLOR when hasColor
+	hasColor bool
+}
+
+// parseGradeRules reads GRADE_RULES, rules separated by ";" and tried in
+// order, e.g.
+//
+//	>=85: Distinction rgb(212,175,55); >=50: Pass; *: Not yet achieved rgb(160,0,0)
+//
+// A rule matches a literal value case-insensitively, compares a numeric
+// score with >=, >, <= or <, or matches anything with "*". The color after
+// the text is rgb(R,G,B) or hex such as #D4AF37; color names are not
+// taken there, since they could be part of the text.
+func parseGradeRules(s string) ([]gradeRule, error) {
+	var rules []gradeRule
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		match, rest, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("rule %q is not MATCH: TEXT", part)
+		}
+		match = strings.TrimSpace(match)
+		r := gradeRule{text: strings.TrimSpace(rest)}
+
+		if i := ruleColor(r.text); i >= 0 {
+			c, err := ParseColor(r.text[i:])
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", part, err)
+			}
+			r.color, r.hasColor, r.text = c, true, strings.TrimSpace(r.text[:i])
+		}
+
+		switch m := match; {
+		case m == "*":
+			r.op = "*"
+		case strings.HasPrefix(m, ">="), strings.HasPrefix(m, "<="):
+			r.op, r.value = m[:2], strings.TrimSpace(m[2:])
+		case strings.HasPrefix(m, ">"), strings.HasPrefix(m, "<"):
+			r.op, r.value = m[:1], strings.TrimSpace(m[1:])
+		default:
+			r.value = m
+		}
+		if r.op != "" && r.op != "*" {
+			n, err := strconv.ParseFloat(r.value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %q is not a number", part, r.value)
+			}
+			r.num = n
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ruleColor returns where the color at the end of a rule's text starts,
+// or -1 if it has none.
+func ruleColor(text string) int {
+	if i := strings.LastIndex(text, "rgb("); i >= 0 && strings.HasSuffix(text, ")") {
+		return i
+	}
+	if i := strings.LastIndexByte(text, ' ') + 1; strings.HasPrefix(text[i:], "#") {
+		return i
+	}
+	return -1
+}
+
+// parseRGB reads "R,G,B" with 0–255 components.
+func parseRGB(s string) (color.RGBA, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return color.RGBA{}, fmt.Errorf("rgb(%s) needs three components", s)
+	}
+	var c [3]uint8
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 || n > 255 {
+			return color.RGBA{}, fmt.Errorf("rgb(%s): %q is not 0–255", s, strings.TrimSpace(p))
+		}
+		c[i] = uint8(n)
+	}
+	return color.RGBA{R: c[0], G: c[1], B: c[2], A: 255}, nil
+}
+
+func (r gradeRule) matches(value string) bool {
+	switch r.op {
+	case "*":
+		return true
+	case "":
+		return strings.EqualFold(r.value, value)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	switch r.op {
+	case ">=":
+		return n >= r.num
+	case ">":
+		return n > r.num
+	case "<=":
+		return n <= r.num
+	default:
+		return n < r.num
+	}
+}
+
+// errNoGrade is returned for records without a grade, which only
+// GRADE_OPTIONAL allows.
+var errNoGrade = errors.New("no grade")
+
+// grade returns the grade line of rec and its color: the first matching
+// rule's text and color, else the value itself in GRADE_COLOR.
+func (g *Generator) grade(rec Record) (text string, c color.RGBA, err error) {
+	value := strings.TrimSpace(rec.Fields[g.cfg.Grade.Field])
+	if value == "" {
+		return "", color.RGBA{}, fmt.Errorf("%w: missing %s", errNoGrade, g.cfg.Grade.Field)
+	}
+	text, c = value, g.cfg.Grade.Color
+	for _, r := range g.gradeRules {
+		if r.matches(value) {
+			if r.text != "" {
+				text = r.text
+			}
+			if r.hasColor {
+				c = r.color
+			}
+			break
+		}
+	}
+	return text, c, nil
+}
@@ -0,0 +1,114 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ImageFieldConfig is the optional per-record image, such as the logo or
+// seal of a partner institution on co-branded certificates. The record's
+// Field picks the image, which is fitted into the Width×Height mm box at
+// Left, Top, keeping its proportions and centred in the box.
+type ImageFieldConfig struct {
+	Field string // record field choosing the image; empty prints no image
+	// Path is a text/template turning the field's value into the image's
+	// path, URL or asset reference, e.g. "logos/{{.}}.png" for a partner
+	// column of "acme". Values used in it must be plain names, without
+	// path separators. Empty takes the value as the path itself.
+	Path   string
+	Left   float64
+	Top    float64
+	Width  float64 // box width; 0 sizes the image by Height alone
+	Height float64 // box height; 0 sizes the image by Width alone
+	// Optional leaves the image out for records without the field, instead
+	// of failing them.
+	Optional bool
+	// Blend lets the image sit in the template art, e.g. a seal scanned on
+	// white paper printed with BlendMultiply.
+	Blend Blend
+}
+
+// errNoImage is returned for records without IMAGE_FIELD.
+var errNoImage = errors.New("no image value")
+
+func parseImagePath(s string) (*template.Template, error) {
+	return template.New("image").Option("missingkey=error").Parse(s)
+}
+
+// image resolves rec's image to a file on disk and the mm size it is drawn
+// at. It returns an empty path when the record prints none.
+func (g *Generator) image(rec Record) (path string, w, h float64, err error) {
+	f := g.cfg.Image
+	if f.Field == "" {
+		return "", 0, 0, nil
+	}
+	v := strings.TrimSpace(rec.Fields[f.Field])
+	if v == "" {
+		if f.Optional {
+			return "", 0, 0, nil
+		}
+		return "", 0, 0, fmt.Errorf("IMAGE_FIELD: %w in %q", errNoImage, f.Field)
+	}
+	ref := v
+	if f.Path != "" {
+		if strings.ContainsAny(v, `/\`) || strings.Contains(v, "..") {
+			return "", 0, 0, fmt.Errorf("IMAGE_FIELD: %s %q must be a plain name to use in IMAGE_PATH", f.Field, v)
+		}
+		t, err := parseImagePath(f.Path)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("IMAGE_PATH: %w", err)
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, v); err != nil {
+			return "", 0, 0, fmt.Errorf("IMAGE_PATH: %w", err)
+		}
+		ref = b.String()
+	}
+	if path, err = g.cfg.resolveAsset(ref); err != nil {
+		return "", 0, 0, fmt.Errorf("image for %s %q: %w", f.Field, v, err)
+	}
+	pw, ph, err := imageSize(path)
+	if err == nil && (pw == 0 || ph == 0) {
+		err = fmt.Errorf("%s is empty", path)
+	}
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("image for %s %q: %w", f.Field, v, err)
+	}
+
+	// The largest size that fits the box
+	aspect := float64(pw) / float64(ph)
+	switch w, h = f.Width, f.Width/aspect; {
+	case f.Width == 0:
+		w, h = f.Height*aspect, f.Height
+	case f.Height > 0 && h > f.Height:
+		w, h = f.Height*aspect, f.Height
+	}
+	return path, w, h, nil
+}
+
+// origin is where an image of w×h mm is drawn to sit centred in the box.
+func (f ImageFieldConfig) origin(w, h float64) (x, y float64) {
+	x, y = f.Left, f.Top
+	if f.Width > 0 {
+		x += (f.Width - w) / 2
+	}
+	if f.Height > 0 {
+		y += (f.Height - h) / 2
+	}
+	return x, y
+}
+
+func (f ImageFieldConfig) validate(fail func(string, ...any)) {
+	if f.Field == "" {
+		return
+	}
+	f.Blend.validate("IMAGE", fail)
+	if f.Width < 0 || f.Height < 0 || f.Width == 0 && f.Height == 0 {
+		fail("IMAGE_WIDTH/IMAGE_HEIGHT: at least one must be greater than zero and neither negative, got %gx%g", f.Width, f.Height)
+	}
+	if _, err := parseImagePath(f.Path); err != nil {
+		fail("IMAGE_PATH: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package certificate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ImposeAuto for IMPOSE_LAYOUT fits as many certificates on a sheet as it
+// holds, turning the sheet if that fits more.
+const ImposeAuto = "auto"
+
+// Cut marks are drawn this far outside the trimmed certificates, so none
+// of them shows on a cut piece, and at most this long.
+const (
+	cutMarkOffset = 2.0
+	cutMarkLength = 5.0
+)
+
+// sheetSizes are the named IMPOSE_SHEET sizes, portrait, in mm.
+var sheetSizes = map[string][2]float64{
+	"a4":      {210, 297},
+	"a3":      {297, 420},
+	"a2":      {420, 594},
+	"sra4":    {225, 320},
+	"sra3":    {320, 450},
+	"sra2":    {450, 640},
+	"letter":  {215.9, 279.4},
+	"legal":   {215.9, 355.6},
+	"tabloid": {279.4, 431.8},
+	"12x18":   {304.8, 457.2},
+}
+
+// ImposeConfig lays certificates out n-up on print sheets, for "batch
+// -impose". The sheet may be turned either way to fit more.
+type ImposeConfig struct {
+	Sheet    string  // a named size such as SRA3, or WIDTHxHEIGHT in mm
+	Layout   string  // ImposeAuto, or COLUMNSxROWS
+	Gap      float64 // mm between neighbouring certificates
+	Margin   float64 // least mm between the certificates and the sheet edge
+	CutMarks bool    // draw crop marks in the margin at every cut
+}
+
+// sheetSize parses IMPOSE_SHEET.
+func (c ImposeConfig) sheetSize() (width, height float64, err error) {
+	return parseSheetSize(c.Sheet)
+}
+
+// parseSheetSize parses the name of one of sheetSizes, or WIDTHxHEIGHT in
+// mm.
+func parseSheetSize(s string) (width, height float64, err error) {
+	if size, ok := sheetSizes[strings.ToLower(s)]; ok {
+		return size[0], size[1], nil
+	}
+	w, h, ok := parseDimensions(s, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	if !ok || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("%q is neither a sheet size (A4, A3, SRA3, letter, tabloid, ...) nor WIDTHxHEIGHT in mm", s)
+	}
+	return w, h, nil
+}
+
+// grid parses IMPOSE_LAYOUT; 0×0 is auto.
+func (c ImposeConfig) grid() (cols, rows int, err error) {
+	if c.Layout == "" || strings.EqualFold(c.Layout, ImposeAuto) {
+		return 0, 0, nil
+	}
+	cols, rows, ok := parseDimensions(c.Layout, strconv.Atoi)
+	if !ok || cols < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("%q is neither auto nor COLUMNSxROWS, e.g. 2x1", c.Layout)
+	}
+	return cols, rows, nil
+}
+
+func parseDimensions[T any](s string, parse func(string) (T, error)) (a, b T, ok bool) {
+	as, bs, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return a, b, false
+	}
+	a, errA := parse(strings.TrimSpace(as))
+	b, errB := parse(strings.TrimSpace(bs))
+	return a, b, errA == nil && errB == nil
+}
+
+func (c ImposeConfig) validate(fail func(format string, args ...any)) {
+	if _, _, err := c.sheetSize(); err != nil {
+		fail("IMPOSE_SHEET: %v", err)
+	}
+	if _, _, err := c.grid(); err != nil {
+		fail("IMPOSE_LAYOUT: %v", err)
+	}
+	if c.Gap < 0 {
+		fail("IMPOSE_GAP: must not be negative, got %g", c.Gap)
+	}
+	if c.Margin < 0 {
+		fail("IMPOSE_MARGIN: must not be negative, got %g", c.Margin)
+	}
+}
+
+// sheetLayout places the certificates of one sheet: a grid of cols × rows
+// pages of cellW × cellH mm, centred, with its top-left corner at
+// (left, top).
+type sheetLayout struct {
+	width, height float64
+	cols, rows    int
+	cellW, cellH  float64
+	left, top     float64
+	gap           float64
+}
+
+// fit returns how many cells of cellW × cellH fit across length mm.
+func fit(length, cell, gap, margin float64) int {
+	// A hair of slack so sizes that fit exactly are not lost to rounding
+	return int(math.Floor((length - 2*margin + gap + 1e-6) / (cell + gap)))
+}
+
+// layoutSheet arranges pages of width × height mm on a sheet as c asks:
+// the requested grid, or the one holding the most, in whichever sheet
+// orientation allows it.
+func (c ImposeConfig) layoutSheet(width, height float64) (sheetLayout, error) {
+	sw, sh, err := c.sheetSize()
+	if err != nil {
+		return sheetLayout{}, err
+	}
+	cols, rows, err := c.grid()
+	if err != nil {
+		return sheetLayout{}, err
+	}
+
+	var best sheetLayout
+	for _, size := range [][2]float64{{sw, sh}, {sh, sw}} {
+		l := sheetLayout{width: size[0], height: size[1], cellW: width, cellH: height, gap: c.Gap}
+		fitCols, fitRows := fit(l.width, width, c.Gap, c.Margin), fit(l.height, height, c.Gap, c.Margin)
+		switch {
+		case cols == 0:
+			l.cols, l.rows = fitCols, fitRows
+		case cols <= fitCols && rows <= fitRows:
+			l.cols, l.rows = cols, rows
+		}
+		if l.cols*l.rows > best.cols*best.rows {
+			best = l
+		}
+	}
+	if best.cols*best.rows == 0 {
+		grid := "a certificate"
+		if cols > 0 {
+			grid = fmt.Sprintf("%d×%d certificates", cols, rows)
+		}
+		return sheetLayout{}, fmt.Errorf("%s of %.1fx%.1f mm with %g mm gaps do not fit on a %gx%g mm sheet inside %g mm margins",
+			grid, width, height, c.Gap, sw, sh, c.Margin)
+	}
+	best.left = (best.width - (float64(best.cols)*(width+c.Gap) - c.Gap)) / 2
+	best.top = (best.height - (float64(best.rows)*(height+c.Gap) - c.Gap)) / 2
+	return best, nil
+}
+
+// cell is the top-left corner of the i-th certificate on a sheet, in
+// reading order.
+func (l sheetLayout) cell(i int) (x, y float64) {
+	col, row := i%l.cols, i/l.cols
+	return l.left + float64(col)*(l.cellW+l.gap), l.top + float64(row)*(l.cellH+l.gap)
+}
+
+// drawCutMarks marks every trim line of the grid in the margin around it.
+func (l sheetLayout) drawCutMarks(pdf renderer) {
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.1)
+	right, bottom := l.cell(l.cols*l.rows - 1)
+	right, bottom = right+l.cellW, bottom+l.cellH
+	// Marks shrink to the margin there is, and are left out without one
+	length := math.Min(cutMarkLength, math.Min(l.left, l.top)-cutMarkOffset)
+	if length <= 0 {
+		return
+	}
+
+	var xs, ys []float64
+	for col := range l.cols {
+		x, _ := l.cell(col)
+		xs = append(xs, x, x+l.cellW)
+	}
+	for row := range l.rows {
+		_, y := l.cell(row * l.cols)
+		ys = append(ys, y, y+l.cellH)
+	}
+	// Without gaps neighbours share their cut
+	xs, ys = slices.Compact(xs), slices.Compact(ys)
+	for _, x := range xs {
+		pdf.Line(x, l.top-cutMarkOffset-length, x, l.top-cutMarkOffset)
+		pdf.Line(x, bottom+cutMarkOffset, x, bottom+cutMarkOffset+length)
+	}
+	for _, y := range ys {
+		pdf.Line(l.left-cutMarkOffset-length, y, l.left-cutMarkOffset, y)
+		pdf.Line(right+cutMarkOffset, y, right+cutMarkOffset+length, y)
+	}
+}
+
+// Imposition collects certificates n-up on print sheets, as IMPOSE_*
+// configures, into a single PDF for printing and cutting. Each certificate
+// looks exactly as its own PDF does; the sheets carry no metadata, tags or
+// attachments, which belong to the issued PDFs.
+//
+// The document is held in memory until it is written. An Imposition is not
+// safe for concurrent use.
+type Imposition struct {
+	g      *Generator
+	pdf    renderer
+	layout sheetLayout
+	n      int // certificates placed
+	sheets int // sheets started
+}
+
+// NewImposition starts an empty imposed document, or reports why the
+// certificates do not fit on the configured sheet.
+func (g *Generator) NewImposition() (*Imposition, error) {
+	cfg := g.cfg
+	layout, err := cfg.Impose.layoutSheet(cfg.PaperSize())
+	if err != nil {
+		return nil, fmt.Errorf("imposition: %w", err)
+	}
+	pdf := newRenderer(cfg.PDFEngine, layout.width, layout.height)
+	if err := cfg.addFonts(pdf); err != nil {
+		return nil, err
+	}
+	return &Imposition{g: g, pdf: pdf, layout: layout}, nil
+}
+
+// PerSheet is the number of certificates on each sheet.
+func (im *Imposition) PerSheet() int {
+	return im.layout.cols * im.layout.rows
+}
+
+// Len is the number of certificates added so far.
+func (im *Imposition) Len() int {
+	return im.n
+}
+
+// Sheets is the number of sheets in the document.
+func (im *Imposition) Sheets() int {
+	return im.sheets
+}
+
+// Add places the certificate for rec in the next free cell, starting a new
+// sheet when the current one is full. rec must carry the IssuedAt it was
+// issued with for its dates to match the issued PDF.
+func (im *Imposition) Add(ctx context.Context, rec Record) error {
+	g, rec, err := im.g.forRecord(rec)
+	if err != nil {
+		return err
+	}
+	locale, err := g.locale(rec)
+	if err != nil {
+		return err
+	}
+
+	if im.n/im.PerSheet() == im.sheets {
+		if im.sheets > 0 {
+			im.pdf.AddPage()
+		}
+		if g.cfg.Impose.CutMarks {
+			im.layout.drawCutMarks(im.pdf)
+		}
+		im.sheets++
+	}
+	x, y := im.layout.cell(im.n % im.PerSheet())
+	im.pdf.TransformBegin()
+	im.pdf.TransformTranslate(x, y)
+	// Nothing, not even the debug grid, spills onto a neighbour
+	im.pdf.ClipRect(0, 0, im.layout.cellW, im.layout.cellH, false)
+	err = g.drawPage(ctx, im.pdf, rec, locale, fmt.Sprintf("qr%d", im.n))
+	im.pdf.ClipEnd()
+	im.pdf.TransformEnd()
+	if err != nil {
+		return err
+	}
+	if err := im.pdf.Error(); err != nil {
+		return err
+	}
+	im.n++
+	return nil
+}
+
+// Output writes the imposed document to w.
+func (im *Imposition) Output(w io.Writer) error {
+	if im.n == 0 {
+		return errors.New("imposition: no certificates")
+	}
+	return im.pdf.Output(w)
+}
+
+// WriteFile writes the imposed document to path, replacing it atomically.
+func (im *Imposition) WriteFile(path string) error {
+	_, err := writeAtomic(path, nil, im.Output)
+	return err
+}
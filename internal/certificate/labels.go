@@ -0,0 +1,199 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Keys of the static labels of certificates.
+const (
+	LabelReg         = "reg"          // precedes the registration number
+	LabelExpiry      = "expiry"       // precedes the expiry date
+	LabelTemplateAlt = "template_alt" // alt text of the template image in tagged PDFs
+	LabelQRAlt       = "qr_alt"       // alt text of the QR code in tagged PDFs
+)
+
+// DefaultLocale is used when neither LOCALE nor the record picks one.
+const DefaultLocale = "en"
+
+// catalog holds the static labels of every supported locale. Each locale
+// must define every key.
+var catalog = map[string]map[string]string{
+	"en": {
+		LabelReg:         "Registration Number : ",
+		LabelExpiry:      "Valid until : ",
+		LabelTemplateAlt: "Certificate design",
+		LabelQRAlt:       "QR code linking to the verification page",
+	},
+	"fr": {
+		LabelReg:         "Numéro d'enregistrement : ",
+		LabelExpiry:      "Valable jusqu'au : ",
+		LabelTemplateAlt: "Motif du certificat",
+		LabelQRAlt:       "Code QR menant à la page de vérification",
+	},
+	"es": {
+		LabelReg:         "Número de registro : ",
+		LabelExpiry:      "Válido hasta : ",
+		LabelTemplateAlt: "Diseño del certificado",
+		LabelQRAlt:       "Código QR que enlaza a la página de verificación",
+	},
+}
+
+// Locales lists the locales labels are available in.
+func Locales() []string {
+	locales := make([]string, 0, len(catalog))
+	for l := range catalog {
+		locales = append(locales, l)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// findLocale returns the catalog locale for tag: the tag itself, else its
+// base language, so "fr-CA" and "fr_BE" print French labels.
+func findLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := catalog[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	_, ok := catalog[base]
+	return base, ok
+}
+
+// locale is the locale rec is printed in: its "locale" field, else LOCALE.
+func (g *Generator) locale(rec Record) (string, error) {
+	tag := g.cfg.Locale
+	if v := rec.Fields["locale"]; v != "" {
+		tag = v
+	}
+	l, ok := findLocale(tag)
+	if !ok {
+		return "", fmt.Errorf("locale %q has no labels (have %s)", tag, strings.Join(Locales(), ", "))
+	}
+	return l, nil
+}
+
+// lines are the texts printed on a certificate, as Unicode.
+type lines struct {
+	name   string
+	reg    string
+	expiry string // empty when the certificate does not expire
+
+	grade      string // empty without GRADE_FIELD
+	gradeColor color.RGBA
+
+	image          string // file of IMAGE_FIELD's image; empty for none
+	imageAlt       string
+	imageW, imageH float64
+}
+
+// Special REG_TEMPLATE values.
+const (
+	DefaultRegTemplate = "{{.Label}}{{.RegNumber}}" // localized label, then the number
+	RegNone            = "none"                     // no registration line
+)
+
+// regData is what REG_TEMPLATE is executed against.
+type regData struct {
+	Label     string // the catalog's registration label for the locale
+	RegNumber string // formatted by RegFormat
+	Raw       string // the RegNumber the record was issued with
+	Name      string
+	Course    string
+	Fields    map[string]string
+}
+
+func parseRegTemplate(s string) (*template.Template, error) {
+	if strings.EqualFold(s, RegNone) {
+		s = ""
+	}
+	return template.New("reg").Option("missingkey=zero").Parse(s)
+}
+
+// lines resolves what rec's certificate prints.
+func (g *Generator) lines(rec Record) (lines, error) {
+	locale, err := g.locale(rec)
+	if err != nil {
+		return lines{}, err
+	}
+	data := regData{
+		Label:     catalog[locale][LabelReg],
+		RegNumber: g.cfg.RegFormat.format(rec.RegNumber),
+		Raw:       rec.RegNumber,
+		Name:      rec.Name,
+		Course:    rec.Course,
+		Fields:    rec.Fields,
+	}
+	reg, err := g.regLine(data)
+	if err != nil {
+		return lines{}, err
+	}
+	// An optional line that would print only its labels, because every
+	// record value it uses is empty, is left out.
+	if g.cfg.RegOptional {
+		if blank, err := g.regLine(regData{Label: data.Label, RegNumber: g.cfg.RegFormat.format("")}); err == nil && reg == blank {
+			reg = ""
+		}
+	}
+
+	l := lines{name: g.cfg.NameRules.formatName(rec.Name), reg: reg}
+	if g.cfg.Grade.Field != "" {
+		l.grade, l.gradeColor, err = g.grade(rec)
+		if errors.Is(err, errNoGrade) && g.cfg.Grade.Optional {
+			err = nil
+		}
+		if err != nil {
+			return lines{}, err
+		}
+	}
+	if l.image, l.imageW, l.imageH, err = g.image(rec); err != nil {
+		return lines{}, err
+	}
+	if l.image != "" {
+		l.imageAlt = "Image for " + rec.Fields[g.cfg.Image.Field]
+	}
+	if exp := g.ExpiresAt(rec); !exp.IsZero() {
+		l.expiry = catalog[locale][LabelExpiry] + exp.Format(g.cfg.ExpiryDateFormat)
+	}
+
+	regular, bold, err := g.cfg.coverage()
+	if err != nil {
+		return lines{}, err
+	}
+	if l.name, err = g.printable("name", l.name, bold); err != nil {
+		return lines{}, err
+	}
+	if l.reg, err = g.printable("registration line", l.reg, regular); err != nil {
+		return lines{}, err
+	}
+	if l.expiry, err = g.printable("expiry line", l.expiry, regular); err != nil {
+		return lines{}, err
+	}
+	if l.grade, err = g.printable("grade", l.grade, regular); err != nil {
+		return lines{}, err
+	}
+	return l, nil
+}
+
+func (g *Generator) regLine(data regData) (string, error) {
+	var b strings.Builder
+	if err := g.regTmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("REG_TEMPLATE failed: %w", err)
+	}
+	return b.String(), nil
+}
+
+// encoder converts text for the fonts of cfg. Embedded fonts take UTF-8;
+// the built-in fonts are encoded in cp1252, which covers the Western
+// European labels in the catalog.
+func (cfg Config) encoder(pdf renderer) func(string) string {
+	if cfg.FontFile != "" {
+		return func(s string) string { return s }
+	}
+	return pdf.Translator()
+}
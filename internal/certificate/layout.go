@@ -0,0 +1,135 @@
+package certificate
+
+import "strings"
+
+// FieldBox is the area a field occupies on the page, in mm from the top-left
+// corner. For text fields it is the line box: TOP down to LINE_HEIGHT below
+// it, or the font's em box for baseline alignment.
+type FieldBox struct {
+	Field string  `json:"field"` // NAME, REG, GRADE, EXPIRY or QR, matching the config key prefix
+	Text  string  `json:"text,omitempty"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	W     float64 `json:"w"`
+	H     float64 `json:"h"`
+	Size  float64 `json:"size,omitempty"` // font size in points, for text fields
+}
+
+// Vertical alignments for a field's VALIGN: where its text sits in the line
+// box that starts at TOP and is LINE_HEIGHT tall.
+const (
+	AlignMiddle   = "middle"   // centered in the line box (default)
+	AlignTop      = "top"      // top of the text at TOP
+	AlignBottom   = "bottom"   // bottom of the text at the bottom of the line box
+	AlignBaseline = "baseline" // baseline at TOP; LINE_HEIGHT is not used
+)
+
+// The PDF engines treat a line of text as tall as the font size, with the
+// baseline this fraction of it below the top; the rest is the descent.
+const ascent = 0.8
+
+// ptToMM converts a font size in points to mm.
+func ptToMM(pt float64) float64 {
+	return pt * 25.4 / 72
+}
+
+// Without LINE_HEIGHT a field is laid out as the text cell layouts were
+// calibrated with before it existed: the font size in points taken as mm
+// for its height, and its text 1 mm in from LEFT, the cell's padding.
+const cellPadding = 1.0
+
+// lineHeight is LINE_HEIGHT in mm, or the old cell height.
+func (f TextField) lineHeight() float64 {
+	if f.LineHeight > 0 {
+		return f.LineHeight
+	}
+	return f.Size
+}
+
+// x is where the text of f starts, in mm from the left of the page.
+func (f TextField) x() float64 {
+	if f.LineHeight > 0 {
+		return f.Left
+	}
+	return f.Left + cellPadding
+}
+
+// baseline is where the text of f is set, in mm from the top of the page.
+func (f TextField) baseline() float64 {
+	em := ptToMM(f.Size)
+	switch strings.ToLower(f.VAlign) {
+	case AlignTop:
+		return f.Top + ascent*em
+	case AlignBottom:
+		return f.Top + f.lineHeight() - (1-ascent)*em
+	case AlignBaseline:
+		return f.Top
+	}
+	return f.Top + f.lineHeight()/2 + (ascent-0.5)*em
+}
+
+// box returns the top and height of the area the line of f occupies.
+func (f TextField) box() (y, h float64) {
+	if strings.EqualFold(f.VAlign, AlignBaseline) {
+		em := ptToMM(f.Size)
+		return f.Top - ascent*em, em
+	}
+	return f.Top, f.lineHeight()
+}
+
+// TopForBox returns the TOP that moves the box of f to y, e.g. after the
+// field was dragged in the designer.
+func (f TextField) TopForBox(y float64) float64 {
+	top, _ := f.box()
+	return f.Top + y - top
+}
+
+// FieldBoxes measures where every field of rec lands with the current
+// configuration, without rendering a certificate.
+func (g *Generator) FieldBoxes(rec Record) ([]FieldBox, error) {
+	g, rec, err := g.forRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+	l, err := g.lines(rec)
+	if err != nil {
+		return nil, err
+	}
+	w, h := g.cfg.PageSize()
+	pdf := newRenderer(g.cfg.PDFEngine, w, h)
+	if err := g.cfg.addFonts(pdf); err != nil {
+		return nil, err
+	}
+	boxes := g.measure(pdf, l)
+	return boxes, pdf.Error()
+}
+
+// measure computes the field boxes using pdf for font metrics. It changes
+// the current font of pdf.
+func (g *Generator) measure(pdf renderer, l lines) []FieldBox {
+	cfg := g.cfg
+	enc := cfg.encoder(pdf)
+	text := func(field, style, s string, f TextField) FieldBox {
+		pdf.SetFont(cfg.FontFamily, style, f.Size)
+		y, h := f.box()
+		return FieldBox{Field: field, Text: s, X: f.Left, Y: y, W: pdf.StringWidth(enc(s)), H: h, Size: f.Size}
+	}
+
+	qr := cfg.QRSizeMM()
+	boxes := []FieldBox{
+		text("NAME", "B", l.name, cfg.Name),
+		text("REG", "", l.reg, cfg.Reg),
+		{Field: "QR", X: cfg.QR.Left, Y: cfg.QR.Top, W: qr, H: qr},
+	}
+	if l.grade != "" {
+		boxes = append(boxes, text("GRADE", "", l.grade, cfg.Grade.TextField))
+	}
+	if l.expiry != "" {
+		boxes = append(boxes, text("EXPIRY", "", l.expiry, cfg.Expiry))
+	}
+	if l.image != "" {
+		x, y := cfg.Image.origin(l.imageW, l.imageH)
+		boxes = append(boxes, FieldBox{Field: "IMAGE", X: x, Y: y, W: l.imageW, H: l.imageH})
+	}
+	return boxes
+}
@@ -0,0 +1,36 @@
+package certificate
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewLogger builds a slog logger writing to w. level is debug, info, warn or
+// error; format is text or json. Empty values mean info and text.
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", format)
+	}
+}
+
+// LoggerFromEnv builds a stderr logger from LOG_LEVEL and LOG_FORMAT. Logs
+// never go to stdout, which is reserved for output a caller may pipe.
+func LoggerFromEnv() (*slog.Logger, error) {
+	return NewLogger(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
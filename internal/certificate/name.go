@@ -0,0 +1,110 @@
+package certificate
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Transforms for NAME_CASE.
+const (
+	CaseKeep  = "keep"  // print the case as given (default)
+	CaseUpper = "upper" // "ADA LOVELACE"
+	CaseTitle = "title" // "Ada Lovelace", "Jean-Luc O'Neill", "Ludwig van Beethoven"
+)
+
+// Handling of leading honorifics for NAME_HONORIFICS.
+const (
+	HonorificsKeep      = "keep"      // leave them as written (default)
+	HonorificsNormalize = "normalize" // "DR" and "dr." become "Dr."
+	HonorificsStrip     = "strip"     // drop them
+)
+
+// NameRules controls how record names are cleaned up before printing.
+type NameRules struct {
+	Case       string
+	Honorifics string
+	// MaxLength is the number of characters above which a name is
+	// reported as too long; 0 disables the check. Long names are still
+	// printed.
+	MaxLength int
+}
+
+// honorifics maps the lowercase, dot-less spelling of each recognised
+// honorific to its canonical form.
+var honorifics = map[string]string{
+	"dr": "Dr.", "mr": "Mr.", "mrs": "Mrs.", "ms": "Ms.", "mx": "Mx.", "miss": "Miss",
+	"prof": "Prof.", "rev": "Rev.", "sir": "Sir", "dame": "Dame",
+}
+
+// particles stay lowercase in title case unless they start the name.
+var particles = map[string]bool{
+	"van": true, "von": true, "der": true, "den": true, "de": true, "del": true,
+	"della": true, "da": true, "di": true, "du": true, "la": true, "le": true,
+	"bin": true, "binti": true, "al": true,
+}
+
+// formatName applies r to name. Whitespace is always trimmed and condensed
+// to single spaces, since it never prints as intended.
+func (r NameRules) formatName(name string) string {
+	words := strings.Fields(name)
+
+	// Only the leading words can be honorifics ("Dr. Prof. Ada ...").
+	n := 0
+	for n < len(words)-1 && honorifics[honorificKey(words[n])] != "" {
+		n++
+	}
+	titles, rest := words[:n], words[n:]
+
+	switch strings.ToLower(r.Case) {
+	case CaseUpper:
+		for i, w := range rest {
+			rest[i] = strings.ToUpper(w)
+		}
+	case CaseTitle:
+		for i, w := range rest {
+			if i > 0 && particles[strings.ToLower(w)] {
+				rest[i] = strings.ToLower(w)
+			} else {
+				rest[i] = titleWord(w)
+			}
+		}
+	}
+
+	switch strings.ToLower(r.Honorifics) {
+	case HonorificsStrip:
+		titles = nil
+	case HonorificsNormalize:
+		for i, w := range titles {
+			titles[i] = honorifics[honorificKey(w)]
+		}
+	}
+	return strings.Join(append(titles, rest...), " ")
+}
+
+// tooLong reports whether name, as printed, exceeds MaxLength.
+func (r NameRules) tooLong(name string) bool {
+	return r.MaxLength > 0 && utf8.RuneCountInString(name) > r.MaxLength
+}
+
+func honorificKey(w string) string {
+	return strings.ToLower(strings.TrimSuffix(w, "."))
+}
+
+// titleWord capitalizes the first letter of w and of every part after a
+// hyphen or apostrophe, lowercasing the rest.
+func titleWord(w string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range strings.ToLower(w) {
+		if upper && unicode.IsLetter(r) {
+			r = unicode.ToTitle(r)
+			upper = false
+		}
+		b.WriteRune(r)
+		if r == '-' || r == '\'' || r == '’' {
+			upper = true
+		}
+	}
+	return b.String()
+}
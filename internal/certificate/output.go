@@ -0,0 +1,192 @@
+package certificate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// pathData is what OUTPUT_DIR_TEMPLATE is executed against.
+type pathData struct {
+	Name      string
+	RegNumber string
+	Course    string
+	Year      string
+	Month     string
+	Day       string
+	Fields    map[string]string
+}
+
+// resolveOutputDir expands OUTPUT_DIR_TEMPLATE (e.g. "{{.Year}}/{{.Course}}")
+// for rec below baseDir and creates the resulting directory. The date is
+// rec's issue date in loc.
+func resolveOutputDir(baseDir, tmpl string, rec Record, loc *time.Location) (string, error) {
+	dir, err := outputDirFor(baseDir, tmpl, rec, loc)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cannot create output directory: %w", err)
+	}
+	return dir, nil
+}
+
+// outputDirFor is resolveOutputDir without touching the filesystem.
+func outputDirFor(baseDir, tmpl string, rec Record, loc *time.Location) (string, error) {
+	if tmpl == "" {
+		return baseDir, nil
+	}
+
+	t, err := template.New("output_dir").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid OUTPUT_DIR_TEMPLATE: %w", err)
+	}
+
+	issued := rec.issuedAt().In(loc)
+	var buf bytes.Buffer
+	err = t.Execute(&buf, pathData{
+		Name:      rec.Name,
+		RegNumber: rec.RegNumber,
+		Course:    rec.Course,
+		Year:      issued.Format("2006"),
+		Month:     issued.Format("01"),
+		Day:       issued.Format("02"),
+		Fields:    rec.Fields,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OUTPUT_DIR_TEMPLATE failed: %w", err)
+	}
+
+	// Every segment is sanitized on its own so record values can never
+	// escape baseDir or introduce characters the filesystem rejects.
+	var parts []string
+	for _, seg := range strings.Split(filepath.ToSlash(buf.String()), "/") {
+		seg = sanitize(seg)
+		if seg == "" || seg == "." || seg == ".." {
+			continue
+		}
+		parts = append(parts, seg)
+	}
+
+	return filepath.Join(append([]string{baseDir}, parts...)...), nil
+}
+
+// Policies for OUTPUT_EXISTS, applied when the target PDF is already present.
+const (
+	ExistsOverwrite = "overwrite" // replace the existing file (default)
+	ExistsError     = "error"     // refuse to generate
+	ExistsSkip      = "skip"      // keep the existing file, generate nothing
+	ExistsVersion   = "version"   // write "REG (2).pdf", "REG (3).pdf", ...
+)
+
+// resolveOutputPath applies the exists policy to dir/filename. skip reports
+// that the existing file should be kept and nothing generated.
+func resolveOutputPath(dir, filename, policy string) (path string, skip bool, err error) {
+	path = filepath.Join(dir, filename)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path, false, nil
+	}
+
+	switch strings.ToLower(policy) {
+	case "", ExistsOverwrite:
+		return path, false, nil
+	case ExistsError:
+		return "", false, fmt.Errorf("%w: %s", ErrOutputExists, path)
+	case ExistsSkip:
+		return path, true, nil
+	case ExistsVersion:
+		return nextVersion(dir, filename, 2), false, nil
+	default:
+		return "", false, fmt.Errorf("invalid OUTPUT_EXISTS policy %q (want overwrite, error, skip or version)", policy)
+	}
+}
+
+// nextVersion returns the first "stem (n).ext" in dir, counting from n,
+// that does not exist yet.
+func nextVersion(dir, filename string, n int) string {
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	for ; ; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// onConflict returns what writeAtomic does when another generation created
+// dir/filename after resolveOutputPath looked: nil for the overwrite and
+// skip policies, which may replace it, otherwise a function returning the
+// path to try next or an error.
+func onConflict(dir, filename, policy string) func(path string) (string, error) {
+	switch strings.ToLower(policy) {
+	case ExistsError:
+		return func(path string) (string, error) {
+			return "", fmt.Errorf("%w: %s", ErrOutputExists, path)
+		}
+	case ExistsVersion:
+		return func(string) (string, error) {
+			return nextVersion(dir, filename, 2), nil
+		}
+	}
+	return nil
+}
+
+// writeAtomic writes path via a temp file in the same directory that is
+// synced and moved into place, so readers never observe a partial file, and
+// returns the path it ended up at.
+//
+// With a conflict function the file is hard-linked into place rather than
+// renamed, which fails instead of replacing a file that appeared in the
+// meantime; conflict then decides where to try next. That keeps concurrent
+// generations of one registration number from overwriting each other.
+func writeAtomic(path string, conflict func(string) (string, error), write func(io.Writer) error) (_ string, err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return "", err
+	}
+	// CreateTemp uses 0600; issued certificates get regular file permissions.
+	if err = tmp.Chmod(0o644); err != nil {
+		return "", err
+	}
+	if err = tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if conflict == nil {
+		return path, os.Rename(tmp.Name(), path)
+	}
+	for {
+		err = os.Link(tmp.Name(), path)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+		if path, err = conflict(path); err != nil {
+			return "", err
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, os.Remove(tmp.Name())
+}
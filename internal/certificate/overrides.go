@@ -0,0 +1,97 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// layoutOverrides are the record fields that replace a layout setting for
+// that record alone, named like the setting in lowercase: a batch column
+// "name_size" shrinks one very long name without touching the rest.
+var layoutOverrides = map[string]func(*Config) *float64{
+	"name_size":   func(c *Config) *float64 { return &c.Name.Size },
+	"name_left":   func(c *Config) *float64 { return &c.Name.Left },
+	"name_top":    func(c *Config) *float64 { return &c.Name.Top },
+	"reg_size":    func(c *Config) *float64 { return &c.Reg.Size },
+	"reg_left":    func(c *Config) *float64 { return &c.Reg.Left },
+	"reg_top":     func(c *Config) *float64 { return &c.Reg.Top },
+	"grade_size":  func(c *Config) *float64 { return &c.Grade.Size },
+	"grade_left":  func(c *Config) *float64 { return &c.Grade.Left },
+	"grade_top":   func(c *Config) *float64 { return &c.Grade.Top },
+	"expiry_size": func(c *Config) *float64 { return &c.Expiry.Size },
+	"expiry_left": func(c *Config) *float64 { return &c.Expiry.Left },
+	"expiry_top":  func(c *Config) *float64 { return &c.Expiry.Top },
+	"qr_left":     func(c *Config) *float64 { return &c.QR.Left },
+	"qr_top":      func(c *Config) *float64 { return &c.QR.Top },
+	"qr_size_mm":  func(c *Config) *float64 { return &c.QR.SizeMM },
+}
+
+// LayoutOverrides lists the record fields that override layout settings.
+func LayoutOverrides() []string {
+	keys := make([]string, 0, len(layoutOverrides)+2)
+	for k := range layoutOverrides {
+		keys = append(keys, k)
+	}
+	keys = append(keys, "qr_size", "qr_error_correction")
+	slices.Sort(keys)
+	return keys
+}
+
+// forRecord returns the generator to render rec with, and rec as its
+// course resolves it: g itself, the generator of rec's course, or a copy of
+// either whose layout carries rec's overrides.
+func (g *Generator) forRecord(rec Record) (*Generator, Record, error) {
+	rec, err := g.Resolve(rec)
+	if err != nil {
+		return nil, rec, err
+	}
+	if g, err = g.forCourse(rec); err != nil {
+		return nil, rec, err
+	}
+	cfg := g.cfg
+	var errs []error
+	changed := false
+	for _, key := range LayoutOverrides() {
+		v := strings.TrimSpace(rec.Fields[key])
+		if v == "" {
+			continue
+		}
+		if key == "qr_error_correction" {
+			switch strings.ToUpper(v) {
+			case "L", "M", "Q", "H":
+				cfg.QR.Level, changed = v, true
+			default:
+				errs = append(errs, fmt.Errorf("%s: %q is not one of L, M, Q, H", key, v))
+			}
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %q is not a number", key, v))
+			continue
+		case strings.Contains(key, "_size") && f <= 0, key == "qr_size" && f < 1:
+			errs = append(errs, fmt.Errorf("%s: must be greater than zero, got %s", key, v))
+			continue
+		}
+		if key == "qr_size" {
+			// A size in pixels replaces the template's size in mm too
+			cfg.QR.Size, cfg.QR.SizeMM = int(f), 0
+		} else {
+			*layoutOverrides[key](&cfg) = f
+		}
+		changed = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, rec, fmt.Errorf("layout override: %w", err)
+	}
+	if !changed {
+		return g, rec, nil
+	}
+	o := *g
+	o.cfg, o.configHash = cfg, cfg.hash(g.assets)
+	return &o, rec, nil
+}
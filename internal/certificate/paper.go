@@ -0,0 +1,49 @@
+package certificate
+
+import (
+	"math"
+	"strings"
+)
+
+// PaperTemplate is the default PAGE_SIZE: pages the size of the template,
+// as TEMPLATE_WIDTH_PX, TEMPLATE_HEIGHT_PX and DPI make it.
+const PaperTemplate = "template"
+
+// paper parses PAGE_SIZE. A named size is turned to the orientation of
+// PageSize; WIDTHxHEIGHT is taken as given.
+func (cfg Config) paper() (width, height float64, err error) {
+	if cfg.Paper == "" || strings.EqualFold(cfg.Paper, PaperTemplate) {
+		width, height = cfg.PageSize()
+		return width, height, nil
+	}
+	if width, height, err = parseSheetSize(cfg.Paper); err != nil {
+		return 0, 0, err
+	}
+	if _, named := sheetSizes[strings.ToLower(cfg.Paper)]; named {
+		if w, h := cfg.PageSize(); (w > h) != (width > height) {
+			width, height = height, width
+		}
+	}
+	return width, height, nil
+}
+
+// PaperSize is the size in mm of the PDF's pages. Certificates are laid out
+// on a page of PageSize, which is scaled to fit the paper when PAGE_SIZE
+// names another.
+func (cfg Config) PaperSize() (width, height float64) {
+	width, height, err := cfg.paper()
+	if err != nil {
+		return cfg.PageSize() // reported by Validate
+	}
+	return width, height
+}
+
+// paperFit is where the PageSize page is drawn on the paper: the offset of
+// its top-left corner in mm, and the scale that makes it as large as fits,
+// centred.
+func (cfg Config) paperFit() (x, y, scale float64) {
+	w, h := cfg.PageSize()
+	pw, ph := cfg.PaperSize()
+	scale = math.Min(pw/w, ph/h)
+	return (pw - w*scale) / 2, (ph - h*scale) / 2, scale
+}
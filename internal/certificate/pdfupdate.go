@@ -0,0 +1,231 @@
+package certificate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+)
+
+// extras are the parts of a document neither engine can write, the
+// structure tree and the XMP metadata, added to its output as an
+// incremental update so the engine's output is kept byte for byte.
+type extras struct {
+	tagging
+	xmp []byte
+}
+
+func (e *extras) SetMetadata(xmp []byte) {
+	e.xmp = xmp
+}
+
+// output writes the document produced by out to w, followed by the extras
+// if there are any.
+func (e *extras) output(w io.Writer, out func(io.Writer) error) error {
+	if e.tree == nil && e.xmp == nil {
+		return out(w)
+	}
+	var buf bytes.Buffer
+	if err := out(&buf); err != nil {
+		return err
+	}
+	pdf, err := e.appendTo(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(pdf)
+	return err
+}
+
+func (e *extras) appendTo(pdf []byte) ([]byte, error) {
+	u, err := newPDFUpdate(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("PDF update: %w", err)
+	}
+	var catalog string
+	if e.tree != nil {
+		entries, err := e.tree.write(u)
+		if err != nil {
+			return nil, fmt.Errorf("tagged PDF: %w", err)
+		}
+		catalog += entries
+	}
+	if e.xmp != nil {
+		// Metadata streams stay uncompressed so indexers find the packet
+		num := u.add("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(e.xmp), e.xmp)
+		catalog += fmt.Sprintf(" /Metadata %d 0 R", num)
+	}
+	u.replace(u.root, "<<%s%s >>", u.catalog, catalog)
+	return u.finish(), nil
+}
+
+// pdfUpdate appends objects to a finished single-page document and closes
+// them with their own cross-reference section and trailer.
+type pdfUpdate struct {
+	b       *bytes.Buffer
+	trailer []byte
+	prev    int // offset of the previous cross-reference table
+	next    int // next free object number
+
+	root    int
+	catalog []byte // catalog dictionary, without the brackets
+	page    int    // object number of the page
+	version byte   // minor version in the header
+
+	offsets map[int]int
+	order   []int
+}
+
+var (
+	reRoot    = regexp.MustCompile(`/Root (\d+) 0 R`)
+	reInfo    = regexp.MustCompile(`/Info (\d+) 0 R`)
+	reSize    = regexp.MustCompile(`/Size (\d+)`)
+	rePages   = regexp.MustCompile(`/Pages (\d+) 0 R`)
+	reKids    = regexp.MustCompile(`/Kids \[\s*(\d+) 0 R`)
+	reVersion = regexp.MustCompile(`^%PDF-1\.(\d)`)
+)
+
+func newPDFUpdate(pdf []byte) (*pdfUpdate, error) {
+	u := &pdfUpdate{b: bytes.NewBuffer(pdf), offsets: map[int]int{}}
+	var err error
+	if u.trailer, u.prev, err = lastTrailer(pdf); err != nil {
+		return nil, err
+	}
+	if u.root, err = ref(reRoot, u.trailer); err != nil {
+		return nil, err
+	}
+	if u.next, err = ref(reSize, u.trailer); err != nil {
+		return nil, err
+	}
+	if u.catalog, err = objectDict(pdf, u.root); err != nil {
+		return nil, err
+	}
+	pagesNum, err := ref(rePages, u.catalog)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := objectDict(pdf, pagesNum)
+	if err != nil {
+		return nil, err
+	}
+	if u.page, err = ref(reKids, pages); err != nil {
+		return nil, err
+	}
+	if m := reVersion.FindSubmatch(pdf); m != nil {
+		u.version = m[1][0] - '0'
+	}
+	return u, nil
+}
+
+// dict returns the dictionary of an object of the original document.
+func (u *pdfUpdate) dict(num int) ([]byte, error) {
+	return objectDict(u.b.Bytes(), num)
+}
+
+// add writes a new object and returns its number.
+func (u *pdfUpdate) add(format string, args ...any) int {
+	num := u.reserve()
+	u.replace(num, format, args...)
+	return num
+}
+
+// reserve allocates an object number, for objects that refer to each
+// other.
+func (u *pdfUpdate) reserve() int {
+	u.next++
+	return u.next - 1
+}
+
+// replace writes object num, superseding any earlier version.
+func (u *pdfUpdate) replace(num int, format string, args ...any) {
+	u.offsets[num] = u.b.Len()
+	u.order = append(u.order, num)
+	fmt.Fprintf(u.b, "%d 0 obj\n", num)
+	fmt.Fprintf(u.b, format, args...)
+	u.b.WriteString("\nendobj\n")
+}
+
+func (u *pdfUpdate) finish() []byte {
+	xref := u.b.Len()
+	u.b.WriteString("xref\n")
+	for _, num := range u.order {
+		fmt.Fprintf(u.b, "%d 1\n%010d 00000 n \n", num, u.offsets[num])
+	}
+	info := ""
+	if m := reInfo.FindSubmatch(u.trailer); m != nil {
+		info = fmt.Sprintf(" /Info %s 0 R", m[1])
+	}
+	fmt.Fprintf(u.b, "trailer\n<< /Size %d /Root %d 0 R%s /Prev %d >>\nstartxref\n%d\n%%%%EOF\n",
+		u.next, u.root, info, u.prev, xref)
+	return u.b.Bytes()
+}
+
+// lastTrailer returns the trailer dictionary of pdf and the offset of its
+// cross-reference table.
+func lastTrailer(pdf []byte) (trailer []byte, xref int, err error) {
+	i := bytes.LastIndex(pdf, []byte("trailer"))
+	j := bytes.LastIndex(pdf, []byte("startxref"))
+	if i < 0 || j < i {
+		return nil, 0, errors.New("no trailer")
+	}
+	fields := bytes.Fields(pdf[j+len("startxref"):])
+	if len(fields) == 0 {
+		return nil, 0, errors.New("no startxref offset")
+	}
+	xref, err = strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return nil, 0, fmt.Errorf("startxref: %w", err)
+	}
+	return pdf[i:j], xref, nil
+}
+
+// objectDict returns the contents of the dictionary of object num,
+// without the outer brackets.
+func objectDict(pdf []byte, num int) ([]byte, error) {
+	i := bytes.Index(pdf, fmt.Appendf(nil, "\n%d 0 obj", num))
+	if i < 0 {
+		return nil, fmt.Errorf("object %d not found", num)
+	}
+	start := bytes.Index(pdf[i:], []byte("<<"))
+	if start < 0 {
+		return nil, fmt.Errorf("object %d is not a dictionary", num)
+	}
+	start += i
+	depth := 0
+	for k := start; k < len(pdf)-1; k++ {
+		switch {
+		case pdf[k] == '<' && pdf[k+1] == '<':
+			depth++
+			k++
+		case pdf[k] == '>' && pdf[k+1] == '>':
+			depth--
+			k++
+			if depth == 0 {
+				return pdf[start+2 : k-1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("object %d: unterminated dictionary", num)
+}
+
+func ref(re *regexp.Regexp, dict []byte) (int, error) {
+	m := re.FindSubmatch(dict)
+	if m == nil {
+		return 0, fmt.Errorf("%s missing", re)
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+// pdfString encodes s as a UTF-16 PDF text string.
+func pdfString(s string) string {
+	var b bytes.Buffer
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Plan describes what Generate would do for a record, without doing it.
+type Plan struct {
+	Name       string   `json:"name"`
+	RegNumber  string   `json:"reg_number"`
+	OutputPath string   `json:"output_path"`
+	Skip       bool     `json:"skip,omitempty"` // existing file kept (OUTPUT_EXISTS=skip)
+	VerifyURL  string   `json:"verify_url"`
+	PageWidth  float64  `json:"page_width_mm"`
+	PageHeight float64  `json:"page_height_mm"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// Plan resolves the output path, QR payload and page layout for rec and
+// checks that every field lands on the page. Nothing is written; the
+// returned error joins every problem that would make Generate fail or
+// produce a broken certificate.
+func (g *Generator) Plan(rec Record, outputDir string) (Plan, error) {
+	g, rec, err := g.forRecord(rec)
+	if err != nil {
+		return Plan{Name: rec.Name, RegNumber: rec.RegNumber}, err
+	}
+	cfg := g.cfg
+	w, h := cfg.PageSize()
+	p := Plan{
+		Name:       cfg.NameRules.formatName(rec.Name),
+		RegNumber:  rec.RegNumber,
+		VerifyURL:  cfg.VerificationURL(rec.RegNumber),
+		PageWidth:  w,
+		PageHeight: h,
+	}
+
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	dir, err := outputDirFor(outputDir, cfg.OutputDirTemplate, rec, cfg.Location())
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		p.OutputPath, p.Skip, err = resolveOutputPath(dir, sanitize(rec.RegNumber+".pdf"), cfg.OutputExists)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.OutputPath != "" && !p.Skip {
+		if _, err := os.Stat(filepath.Dir(p.OutputPath)); err != nil && !os.IsNotExist(err) {
+			fail("output directory: %v", err)
+		}
+	}
+
+	payload := g.QRPayload(rec)
+	if _, err := g.code(payload); err != nil {
+		fail("QR payload %q: %v", payload, err)
+	}
+
+	if cfg.NameRules.tooLong(p.Name) {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("name is longer than NAME_MAX_LENGTH (%d characters)", cfg.NameRules.MaxLength))
+	}
+
+	boxes, err := g.FieldBoxes(rec)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, b := range boxes {
+		if b.Field == "QR" {
+			if !inside(b.X, b.Y, w, h) || !inside(b.X+b.W, b.Y+b.H, w, h) {
+				fail("QR_LEFT/QR_TOP/QR_SIZE: %.1f mm code at (%g, %g) does not fit on the %.2fx%.2f mm page",
+					b.W, b.X, b.Y, w, h)
+			}
+			continue
+		}
+		if b.Field == "IMAGE" {
+			if !inside(b.X, b.Y, w, h) || !inside(b.X+b.W, b.Y+b.H, w, h) {
+				fail("IMAGE_LEFT/IMAGE_TOP/IMAGE_WIDTH/IMAGE_HEIGHT: %.1fx%.1f mm image at (%g, %g) does not fit on the %.2fx%.2f mm page",
+					b.W, b.H, b.X, b.Y, w, h)
+			}
+			continue
+		}
+		if !inside(b.X, b.Y, w, h) {
+			fail("%s_LEFT/%s_TOP: (%g, %g) mm is outside the %.2fx%.2f mm page", b.Field, b.Field, b.X, b.Y, w, h)
+			continue
+		}
+		if right := b.X + b.W; right > w {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s text runs %.1f mm past the right edge", b.Field, right-w))
+		}
+		if bottom := b.Y + b.H; bottom > h {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s cell runs %.1f mm past the bottom edge", b.Field, bottom-h))
+		}
+	}
+
+	return p, errors.Join(errs...)
+}
+
+func inside(x, y, w, h float64) bool {
+	return x >= 0 && y >= 0 && x <= w && y <= h
+}
@@ -0,0 +1,206 @@
+package certificate
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Module shapes for QR_STYLE.
+const (
+	QRStyleSquare  = "square"  // plain square modules (default)
+	QRStyleRounded = "rounded" // squares whose free corners are rounded, so runs join smoothly
+	QRStyleDots    = "dots"    // a circle per module
+)
+
+// Finder pattern ("eye") shapes for QR_EYE_STYLE.
+const (
+	QREyeSquare  = "square"
+	QREyeRounded = "rounded"
+	QREyeCircle  = "circle"
+)
+
+// Gradients for QR_GRADIENT, from QR_FG to QR_FG_END, and for
+// BACKGROUND_GRADIENT.
+const (
+	GradientNone       = "none"
+	GradientHorizontal = "horizontal" // left to right
+	GradientVertical   = "vertical"   // top to bottom
+	GradientDiagonal   = "diagonal"   // top-left to bottom-right
+	GradientRadial     = "radial"     // center outwards
+)
+
+// qrQuietZone is the border go-qrcode leaves around the symbol, in modules.
+const qrQuietZone = 4
+
+// styled reports whether the code is drawn with any styling rather than as
+// go-qrcode renders it.
+func (q QRConfig) styled() bool {
+	return !isDefault(q.Style, QRStyleSquare) || !isDefault(q.EyeStyle, QREyeSquare) ||
+		!isDefault(q.Gradient, GradientNone) || q.EyeColor.A != 0
+}
+
+func isDefault(v, def string) bool {
+	return v == "" || strings.EqualFold(v, def)
+}
+
+// recoveryLevel is the error correction level codes are encoded with.
+// Styled modules are harder to read than squares, so styling raises
+// QR_ERROR_CORRECTION to at least Q.
+func (q QRConfig) recoveryLevel() qrcode.RecoveryLevel {
+	level := getQRLevel(q.Level)
+	if q.styled() && level < qrcode.High {
+		level = qrcode.High
+	}
+	return level
+}
+
+// styledImage draws bitmap, the symbol's modules including the quiet zone,
+// as a size × size image with the configured shapes and colors, and the
+// finder patterns at eyes in the eye style. Edges are antialiased by
+// sampling each pixel on a 4 × 4 grid.
+func (q QRConfig) styledImage(bitmap [][]bool, eyes [][2]int, size int) *image.NRGBA {
+	const samples = 4
+	n := len(bitmap)
+	perModule := float64(size) / float64(n)
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && y < n && x < len(bitmap[y]) && bitmap[y][x]
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for py := range size {
+		for px := range size {
+			var modules, eye int
+			for s := range samples * samples {
+				u := (float64(px) + (float64(s%samples)+0.5)/samples) / perModule
+				v := (float64(py) + (float64(s/samples)+0.5)/samples) / perModule
+				mx, my := int(u), int(v)
+				if e, ok := eyeAt(eyes, mx, my); ok {
+					if q.eyeCovers(u-float64(e[0]), v-float64(e[1])) {
+						eye++
+					}
+				} else if dark(mx, my) && q.moduleCovers(dark, mx, my, u-float64(mx), v-float64(my)) {
+					modules++
+				}
+			}
+
+			fg := q.gradientAt((float64(px)+0.5)/float64(size), (float64(py)+0.5)/float64(size))
+			eyeColor := fg
+			if q.EyeColor.A != 0 {
+				eyeColor = q.EyeColor
+			}
+			c := over(premultiply(q.Background, 1), fg, float64(modules)/samples/samples)
+			c = over(c, eyeColor, float64(eye)/samples/samples)
+			img.SetNRGBA(px, py, c.nrgba())
+		}
+	}
+	return img
+}
+
+func eyeAt(eyes [][2]int, mx, my int) ([2]int, bool) {
+	for _, e := range eyes {
+		if mx >= e[0] && mx < e[0]+7 && my >= e[1] && my < e[1]+7 {
+			return e, true
+		}
+	}
+	return [2]int{}, false
+}
+
+// moduleCovers reports whether the point (fx, fy) within dark module
+// (mx, my), both from 0 to 1, is inside its shape.
+func (q QRConfig) moduleCovers(dark func(x, y int) bool, mx, my int, fx, fy float64) bool {
+	switch strings.ToLower(q.Style) {
+	case QRStyleDots:
+		return math.Hypot(fx-0.5, fy-0.5) <= 0.45
+	case QRStyleRounded:
+		// A corner is rounded only where neither neighbor beside it is dark
+		const r = 0.5
+		left, right, up, down := dark(mx-1, my), dark(mx+1, my), dark(mx, my-1), dark(mx, my+1)
+		switch {
+		case fx < r && fy < r && !left && !up:
+			return math.Hypot(fx-r, fy-r) <= r
+		case fx >= 1-r && fy < r && !right && !up:
+			return math.Hypot(fx-(1-r), fy-r) <= r
+		case fx < r && fy >= 1-r && !left && !down:
+			return math.Hypot(fx-r, fy-(1-r)) <= r
+		case fx >= 1-r && fy >= 1-r && !right && !down:
+			return math.Hypot(fx-(1-r), fy-(1-r)) <= r
+		}
+	}
+	return true
+}
+
+// eyeCovers reports whether the point (x, y), from 0 to 7 within a finder
+// pattern, is inside its ring or its center.
+func (q QRConfig) eyeCovers(x, y float64) bool {
+	switch strings.ToLower(q.EyeStyle) {
+	case QREyeCircle:
+		d := math.Hypot(x-3.5, y-3.5)
+		return (d <= 3.5 && d >= 2.5) || d <= 1.5
+	case QREyeRounded:
+		ring := inRoundedRect(x, y, 0, 7, 2) && !inRoundedRect(x, y, 1, 5, 1.2)
+		return ring || inRoundedRect(x, y, 2, 3, 0.8)
+	}
+	ring := !(x >= 1 && x < 6 && y >= 1 && y < 6)
+	return ring || (x >= 2 && x < 5 && y >= 2 && y < 5)
+}
+
+// inRoundedRect reports whether (x, y) is inside the square at (at, at)
+// with edge size and corner radius r.
+func inRoundedRect(x, y, at, size, r float64) bool {
+	if x < at || y < at || x >= at+size || y >= at+size {
+		return false
+	}
+	cx := math.Max(at+r, math.Min(x, at+size-r))
+	cy := math.Max(at+r, math.Min(y, at+size-r))
+	return math.Hypot(x-cx, y-cy) <= r
+}
+
+// gradientAt is the module color at (x, y), from 0 to 1 across the code.
+func (q QRConfig) gradientAt(x, y float64) color.RGBA {
+	var t float64
+	switch strings.ToLower(q.Gradient) {
+	case GradientHorizontal:
+		t = x
+	case GradientVertical:
+		t = y
+	case GradientDiagonal:
+		t = (x + y) / 2
+	case GradientRadial:
+		t = math.Hypot(x-0.5, y-0.5) * math.Sqrt2 // 1 at the corners
+	default:
+		return q.Foreground
+	}
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	a, b := q.Foreground, q.GradientEnd
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// rgbaf is a premultiplied color with components from 0 to 1.
+type rgbaf struct{ r, g, b, a float64 }
+
+// premultiply converts c, a non-premultiplied color as in the config, with
+// its alpha scaled by coverage.
+func premultiply(c color.RGBA, coverage float64) rgbaf {
+	a := float64(c.A) / 255 * coverage
+	return rgbaf{float64(c.R) / 255 * a, float64(c.G) / 255 * a, float64(c.B) / 255 * a, a}
+}
+
+// over composites c, covering the given fraction of the pixel, over dst.
+func over(dst rgbaf, c color.RGBA, coverage float64) rgbaf {
+	src := premultiply(c, coverage)
+	k := 1 - src.a
+	return rgbaf{src.r + dst.r*k, src.g + dst.g*k, src.b + dst.b*k, src.a + dst.a*k}
+}
+
+func (c rgbaf) nrgba() color.NRGBA {
+	if c.a == 0 {
+		return color.NRGBA{}
+	}
+	ch := func(v float64) uint8 { return uint8(math.Round(v / c.a * 255)) }
+	return color.NRGBA{ch(c.r), ch(c.g), ch(c.b), uint8(math.Round(c.a * 255))}
+}
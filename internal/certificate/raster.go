@@ -0,0 +1,591 @@
+package certificate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// RenderImage rasterizes the certificate for rec at dpi, through the same
+// layout code as its PDF and on a page of the same PAGE_SIZE, for previews
+// and layout regression tests. The output is deterministic for a record
+// with IssuedAt set.
+//
+// Custom fonts are drawn as embedded; the built-in PDF fonts are replaced
+// by the Go fonts, whose metrics differ slightly, so the image is close
+// to, not identical with, what a PDF viewer shows.
+func (g *Generator) RenderImage(rec Record, dpi float64) (image.Image, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("raster DPI must be greater than zero, got %g", dpi)
+	}
+	g, rec, err := g.forRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+	w, h := g.cfg.PaperSize()
+	r := newRasterRenderer(w, h, dpi)
+	if err := g.cfg.addFonts(r); err != nil {
+		return nil, err
+	}
+	locale, err := g.locale(rec)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.drawPage(context.Background(), r, rec, locale, "qr"); err != nil {
+		return nil, err
+	}
+	return r.img, r.Error()
+}
+
+// ── Raster ──────────────────────────────────────────────────────────────────
+
+// rasterRenderer draws a page into an RGBA image instead of a PDF. Output
+// encodes it as a PNG.
+type rasterRenderer struct {
+	img   *image.RGBA
+	scale float64 // pixels per mm
+	err   error
+
+	fonts    map[string]*opentype.Font // family/style added with AddFont
+	font     *opentype.Font            // SetFont's
+	fontSize float64
+	faces    map[faceKey]font.Face
+	images   map[string]image.Image
+
+	text, stroke, fill color.RGBA
+	lineWidth          float64 // mm
+	textMode           int
+
+	// alpha and blend are SetAlpha's. While they are not opaque Normal,
+	// everything is drawn onto layer and then composited onto img.
+	alpha float64
+	blend string
+	layer *image.RGBA
+
+	states []rasterState // TransformBegin and ClipRect push, the Ends pop
+	z      vector.Rasterizer
+}
+
+// rasterState is the part of the graphics state the renderer supports: a
+// uniform scale, a translation and a clip rectangle, in pixels.
+type rasterState struct {
+	k      float64 // user space scale, 1 for mm
+	dx, dy float64
+	clip   image.Rectangle
+}
+
+type faceKey struct {
+	font *opentype.Font
+	size float64
+}
+
+func newRasterRenderer(width, height, dpi float64) *rasterRenderer {
+	scale := dpi / 25.4
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Round(width*scale)), int(math.Round(height*scale))))
+	xdraw.Draw(img, img.Bounds(), image.White, image.Point{}, xdraw.Src)
+	return &rasterRenderer{
+		img:       img,
+		scale:     scale,
+		fonts:     map[string]*opentype.Font{},
+		faces:     map[faceKey]font.Face{},
+		images:    map[string]image.Image{},
+		text:      color.RGBA{A: 255},
+		stroke:    color.RGBA{A: 255},
+		fill:      color.RGBA{A: 255},
+		lineWidth: 0.2,
+		alpha:     1,
+		blend:     "Normal",
+		states:    []rasterState{{k: 1, clip: img.Bounds()}},
+	}
+}
+
+func (p *rasterRenderer) state() *rasterState { return &p.states[len(p.states)-1] }
+
+// unit is the pixels per unit of user space: per mm, scaled by the
+// transform in effect.
+func (p *rasterRenderer) unit() float64 { return p.scale * p.state().k }
+
+// pt converts user space coordinates to pixels.
+func (p *rasterRenderer) pt(x, y float64) (float32, float32) {
+	s, u := p.state(), p.unit()
+	return float32(x*u + s.dx), float32(y*u + s.dy)
+}
+
+func (p *rasterRenderer) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *rasterRenderer) Error() error { return p.err }
+
+// ── Graphics state ──
+
+func (p *rasterRenderer) SetTextColor(r, g, b int) { p.text = rgb(r, g, b) }
+func (p *rasterRenderer) SetDrawColor(r, g, b int) { p.stroke = rgb(r, g, b) }
+func (p *rasterRenderer) SetFillColor(r, g, b int) { p.fill = rgb(r, g, b) }
+func (p *rasterRenderer) SetLineWidth(width float64) {
+	p.lineWidth = width
+}
+
+// Joins are not drawn: lines are single segments and circles smooth.
+func (p *rasterRenderer) SetLineJoinStyle(style string) {}
+
+func (p *rasterRenderer) SetTextRenderingMode(mode int) { p.textMode = mode }
+
+func (p *rasterRenderer) SetAlpha(alpha float64, blendMode string) {
+	if _, ok := rasterBlends[blendMode]; !ok {
+		p.fail(fmt.Errorf("raster renderer: unsupported blend mode %q", blendMode))
+		return
+	}
+	p.alpha, p.blend = alpha, blendMode
+}
+
+func rgb(r, g, b int) color.RGBA {
+	return color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
+}
+
+func (p *rasterRenderer) AddPage() {
+	p.fail(errors.New("raster renderer: only one page"))
+}
+
+func (p *rasterRenderer) TransformBegin() { p.states = append(p.states, *p.state()) }
+
+func (p *rasterRenderer) TransformTranslate(tx, ty float64) {
+	u, s := p.unit(), p.state()
+	s.dx += tx * u
+	s.dy += ty * u
+}
+
+// TransformScale scales by sx and sy percent around (x, y). Only uniform
+// scales are supported, as fitting a page to PAGE_SIZE needs.
+func (p *rasterRenderer) TransformScale(sx, sy, x, y float64) {
+	if sx != sy {
+		p.fail(errors.New("raster renderer: only uniform scaling is supported"))
+		return
+	}
+	k := sx / 100
+	u, s := p.unit(), p.state()
+	s.dx += x * u * (1 - k)
+	s.dy += y * u * (1 - k)
+	s.k *= k
+}
+
+func (p *rasterRenderer) ClipRect(x, y, w, h float64, outline bool) {
+	x0, y0 := p.pt(x, y)
+	x1, y1 := p.pt(x+w, y+h)
+	clip := image.Rect(int(math.Floor(float64(x0))), int(math.Floor(float64(y0))),
+		int(math.Ceil(float64(x1))), int(math.Ceil(float64(y1))))
+	s := *p.state()
+	s.clip = s.clip.Intersect(clip)
+	p.states = append(p.states, s)
+}
+
+func (p *rasterRenderer) ClipEnd()      { p.pop() }
+func (p *rasterRenderer) TransformEnd() { p.pop() }
+
+func (p *rasterRenderer) pop() {
+	if len(p.states) == 1 {
+		p.fail(errors.New("raster renderer: unbalanced graphics state"))
+		return
+	}
+	p.states = p.states[:len(p.states)-1]
+}
+
+// ── Text ──
+
+// goFonts stand in for the built-in PDF fonts, by family and style.
+var goFonts = sync.OnceValues(func() (map[string]*opentype.Font, error) {
+	out := map[string]*opentype.Font{}
+	for key, data := range map[string][]byte{
+		"sans": goregular.TTF, "sansB": gobold.TTF,
+		"mono": gomono.TTF, "monoB": gomonobold.TTF,
+	} {
+		f, err := opentype.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = f
+	}
+	return out, nil
+})
+
+func (p *rasterRenderer) AddFont(family, style string, data []byte) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		p.fail(fmt.Errorf("%w: %w", ErrFontLoad, err))
+		return
+	}
+	p.fonts[strings.ToLower(family)+strings.ToUpper(style)] = f
+}
+
+func (p *rasterRenderer) SetFont(family, style string, size float64) {
+	style = strings.ToUpper(strings.ReplaceAll(style, "I", ""))
+	f, ok := p.fonts[strings.ToLower(family)+style]
+	if !ok {
+		builtin, err := goFonts()
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		kind := "sans"
+		if strings.EqualFold(family, "courier") {
+			kind = "mono"
+		}
+		f = builtin[kind+style]
+		if f == nil {
+			f = builtin[kind]
+		}
+	}
+	p.font, p.fontSize = f, size
+}
+
+// face is the face of SetFont's font at the scale in effect, or nil
+// before a font is set.
+func (p *rasterRenderer) face() font.Face {
+	if p.font == nil {
+		return nil
+	}
+	key := faceKey{p.font, p.fontSize * p.state().k}
+	face, ok := p.faces[key]
+	if !ok {
+		var err error
+		face, err = opentype.NewFace(p.font, &opentype.FaceOptions{Size: key.size, DPI: p.scale * 25.4, Hinting: font.HintingNone})
+		if err != nil {
+			p.fail(err)
+			return nil
+		}
+		p.faces[key] = face
+	}
+	return face
+}
+
+func (p *rasterRenderer) StringWidth(s string) float64 {
+	face := p.face()
+	if face == nil {
+		return 0
+	}
+	return float64(font.MeasureString(face, s)) / 64 / p.unit()
+}
+
+func (p *rasterRenderer) Text(x, y float64, text string) {
+	face := p.face()
+	if face == nil {
+		p.fail(errors.New("raster renderer: no font set"))
+		return
+	}
+	px, py := p.pt(x, y)
+	dst := p.canvas()
+	draw := func(c color.RGBA, dx, dy float32) {
+		d := font.Drawer{
+			Dst:  dst.SubImage(p.state().clip).(*image.RGBA),
+			Src:  image.NewUniform(c),
+			Face: face,
+			Dot:  fixed.Point26_6{X: fixed.Int26_6((px + dx) * 64), Y: fixed.Int26_6((py + dy) * 64)},
+		}
+		d.DrawString(text)
+	}
+	var ring float64
+	if p.textMode == textFillStroke {
+		// A stroke, approximated by the glyphs stamped in a ring as wide
+		ring = float64(p.strokeWidth()) / 2
+		for r := ring; r > 0; r -= 1 {
+			for i := range 16 {
+				a := 2 * math.Pi * float64(i) / 16
+				draw(p.stroke, float32(r*math.Cos(a)), float32(r*math.Sin(a)))
+			}
+		}
+	}
+	draw(p.text, 0, 0)
+
+	if dst != p.img {
+		b, _ := font.BoundString(face, text)
+		pad := int(math.Ceil(ring)) + 1
+		p.flatten(image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil()).
+			Add(image.Pt(int(px), int(py))).Inset(-pad))
+	}
+}
+
+// Translator is the identity: the raster fonts take UTF-8.
+func (p *rasterRenderer) Translator() func(string) string {
+	return func(s string) string { return s }
+}
+
+// ── Shapes ──
+
+// path fills the polygons pts, each a closed list of pixel coordinates,
+// with c. Polygons wound the other way cut holes.
+func (p *rasterRenderer) path(c color.RGBA, polys ...[][2]float32) {
+	bounds := image.Rectangle{}
+	for _, poly := range polys {
+		for _, q := range poly {
+			pt := image.Pt(int(math.Floor(float64(q[0]))), int(math.Floor(float64(q[1]))))
+			bounds = bounds.Union(image.Rectangle{Min: pt, Max: pt.Add(image.Pt(2, 2))})
+		}
+	}
+	r := bounds.Intersect(p.state().clip)
+	if r.Empty() {
+		return
+	}
+	p.z.Reset(r.Dx(), r.Dy())
+	p.z.DrawOp = xdraw.Over
+	ox, oy := float32(r.Min.X), float32(r.Min.Y)
+	for _, poly := range polys {
+		p.z.MoveTo(poly[0][0]-ox, poly[0][1]-oy)
+		for _, q := range poly[1:] {
+			p.z.LineTo(q[0]-ox, q[1]-oy)
+		}
+		p.z.ClosePath()
+	}
+	p.z.Draw(p.canvas(), r, image.NewUniform(c), image.Point{})
+	p.flatten(r)
+}
+
+// strokeWidth is the line width in pixels; hairlines stay one pixel wide,
+// as viewers draw them.
+func (p *rasterRenderer) strokeWidth() float32 {
+	return float32(math.Max(p.lineWidth*p.unit(), 1))
+}
+
+func (p *rasterRenderer) Line(x1, y1, x2, y2 float64) {
+	ax, ay := p.pt(x1, y1)
+	bx, by := p.pt(x2, y2)
+	dx, dy := bx-ax, by-ay
+	n := float32(math.Hypot(float64(dx), float64(dy)))
+	if n == 0 {
+		return
+	}
+	w := p.strokeWidth() / 2
+	nx, ny := -dy/n*w, dx/n*w
+	p.path(p.stroke, [][2]float32{{ax + nx, ay + ny}, {bx + nx, by + ny}, {bx - nx, by - ny}, {ax - nx, ay - ny}})
+}
+
+func (p *rasterRenderer) Rect(x, y, w, h float64, style string) {
+	style = strings.ToUpper(style)
+	if strings.Contains(style, "F") {
+		x0, y0 := p.pt(x, y)
+		x1, y1 := p.pt(x+w, y+h)
+		p.path(p.fill, [][2]float32{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}})
+	}
+	if style == "" || strings.Contains(style, "D") {
+		// The outline as a ring, so the corners are mitred
+		x0, y0 := p.pt(x, y)
+		x1, y1 := p.pt(x+w, y+h)
+		sw := p.strokeWidth() / 2
+		p.path(p.stroke,
+			[][2]float32{{x0 - sw, y0 - sw}, {x1 + sw, y0 - sw}, {x1 + sw, y1 + sw}, {x0 - sw, y1 + sw}},
+			[][2]float32{{x0 + sw, y0 + sw}, {x0 + sw, y1 - sw}, {x1 - sw, y1 - sw}, {x1 - sw, y0 + sw}})
+	}
+}
+
+func (p *rasterRenderer) Circle(x, y, r float64, style string) {
+	ring := func(radius float32, reverse bool) [][2]float32 {
+		const steps = 48
+		cx, cy := p.pt(x, y)
+		out := make([][2]float32, steps)
+		for i := range steps {
+			a := 2 * math.Pi * float64(i) / steps
+			if reverse {
+				a = -a
+			}
+			out[i] = [2]float32{cx + radius*float32(math.Cos(a)), cy + radius*float32(math.Sin(a))}
+		}
+		return out
+	}
+	rad := float32(r * p.unit())
+	style = strings.ToUpper(style)
+	if strings.Contains(style, "F") {
+		p.path(p.fill, ring(rad, false))
+	}
+	if style == "" || strings.Contains(style, "D") {
+		w := p.strokeWidth() / 2
+		p.path(p.stroke, ring(rad+w, false), ring(max(rad-w, 0), true))
+	}
+}
+
+func (p *rasterRenderer) LinearGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2 float64) {
+	vx, vy := x2-x1, y2-y1
+	n := vx*vx + vy*vy
+	p.gradient(x, y, w, h, rgb(r1, g1, b1), rgb(r2, g2, b2), func(u, v float64) float64 {
+		if n == 0 {
+			return 0
+		}
+		return ((u-x1)*vx + (v-y1)*vy) / n
+	})
+}
+
+// RadialGradient blends outward from the circle's center, as it does when
+// the focus is the center.
+func (p *rasterRenderer) RadialGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2, r float64) {
+	p.gradient(x, y, w, h, rgb(r1, g1, b1), rgb(r2, g2, b2), func(u, v float64) float64 {
+		if r == 0 {
+			return 1
+		}
+		return math.Hypot(u-x2, v-y2) / r
+	})
+}
+
+// gradient fills the rectangle pixel by pixel with a blend of c1 into c2;
+// at takes the rectangle coordinates of a pixel, from (0, 0) at the
+// lower-left to (1, 1) at the upper-right, to how far along the blend it is.
+func (p *rasterRenderer) gradient(x, y, w, h float64, c1, c2 color.RGBA, at func(u, v float64) float64) {
+	x0, y0 := p.pt(x, y)
+	x1, y1 := p.pt(x+w, y+h)
+	r := image.Rect(int(math.Round(float64(x0))), int(math.Round(float64(y0))),
+		int(math.Round(float64(x1))), int(math.Round(float64(y1))))
+	dst := r.Intersect(p.state().clip)
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	canvas := p.canvas()
+	for py := dst.Min.Y; py < dst.Max.Y; py++ {
+		v := 1 - (float64(py-r.Min.Y)+0.5)/float64(r.Dy())
+		for px := dst.Min.X; px < dst.Max.X; px++ {
+			u := (float64(px-r.Min.X) + 0.5) / float64(r.Dx())
+			t := math.Max(0, math.Min(1, at(u, v)))
+			canvas.SetRGBA(px, py, color.RGBA{lerp(c1.R, c2.R, t), lerp(c1.G, c2.G, t), lerp(c1.B, c2.B, t), 255})
+		}
+	}
+	p.flatten(dst)
+}
+
+// ── Images ──
+
+func (p *rasterRenderer) ImageFile(path string, x, y, w, h float64) {
+	img, ok := p.images[path]
+	if !ok {
+		f, err := os.Open(path)
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		img, _, err = image.Decode(f)
+		f.Close()
+		if err != nil {
+			p.fail(fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		p.images[path] = img
+	}
+	p.drawImage(img, x, y, w, h)
+}
+
+func (p *rasterRenderer) Image(name string, r io.Reader, x, y, w, h float64) {
+	img, ok := p.images[name]
+	if !ok {
+		var err error
+		if img, err = png.Decode(r); err != nil {
+			p.fail(fmt.Errorf("image %s: %w", name, err))
+			return
+		}
+		p.images[name] = img
+	}
+	p.drawImage(img, x, y, w, h)
+}
+
+func (p *rasterRenderer) drawImage(img image.Image, x, y, w, h float64) {
+	x0, y0 := p.pt(x, y)
+	x1, y1 := p.pt(x+w, y+h)
+	dst := image.Rect(int(math.Round(float64(x0))), int(math.Round(float64(y0))),
+		int(math.Round(float64(x1))), int(math.Round(float64(y1))))
+	// Scaled into the rectangle, then cut to the clip
+	xdraw.BiLinear.Scale(p.canvas().SubImage(p.state().clip).(*image.RGBA), dst, img, img.Bounds(), xdraw.Over, nil)
+	p.flatten(dst)
+}
+
+// ── Opacity and blending ──
+
+// rasterBlends are the blend functions of the PDF blend modes the raster
+// renderer supports, on color components from 0 to 1: b is the backdrop,
+// s the source painted over it.
+var rasterBlends = map[string]func(b, s float64) float64{
+	"":         func(b, s float64) float64 { return s },
+	"Normal":   func(b, s float64) float64 { return s },
+	"Multiply": func(b, s float64) float64 { return b * s },
+	"Screen":   func(b, s float64) float64 { return b + s - b*s },
+	"Overlay": func(b, s float64) float64 {
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	},
+	"Darken":  math.Min,
+	"Lighten": math.Max,
+}
+
+// canvas is the image to draw on: img itself, or the layer while drawing
+// translucent or blended.
+func (p *rasterRenderer) canvas() *image.RGBA {
+	if p.alpha >= 1 && (p.blend == "" || p.blend == "Normal") {
+		return p.img
+	}
+	if p.layer == nil {
+		p.layer = image.NewRGBA(p.img.Bounds())
+	}
+	return p.layer
+}
+
+// flatten composites the part r of the layer onto img at the current
+// alpha and blend mode, and clears it. It does nothing when drawing went to
+// img directly.
+func (p *rasterRenderer) flatten(r image.Rectangle) {
+	if p.layer == nil || p.canvas() == p.img {
+		return
+	}
+	fn := rasterBlends[p.blend]
+	r = r.Intersect(p.img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			s := p.layer.RGBAAt(x, y)
+			if s.A == 0 {
+				continue
+			}
+			p.layer.SetRGBA(x, y, color.RGBA{})
+			// The page is opaque, so only the source has coverage
+			a := float64(s.A) / 255 * p.alpha
+			b := p.img.RGBAAt(x, y)
+			mix := func(bc, sc uint8) uint8 {
+				cb, cs := float64(bc)/255, float64(sc)/float64(s.A)
+				return uint8(math.Round(255 * ((1-a)*cb + a*fn(cb, cs))))
+			}
+			p.img.SetRGBA(x, y, color.RGBA{mix(b.R, s.R), mix(b.G, s.G), mix(b.B, s.B), 255})
+		}
+	}
+}
+
+// ── Document ──
+
+// The raster has no document structure, metadata or attachments.
+func (p *rasterRenderer) SetTagged(lang string)                            {}
+func (p *rasterRenderer) BeginTag(tag, alt string)                         {}
+func (p *rasterRenderer) EndTag()                                          {}
+func (p *rasterRenderer) SetMetadata(xmp []byte)                           {}
+func (p *rasterRenderer) Attach(filename, description string, data []byte) {}
+func (p *rasterRenderer) SetCreationDate(t time.Time)                      {}
+func (p *rasterRenderer) SetModificationDate(t time.Time)                  {}
+
+func (p *rasterRenderer) Output(w io.Writer) error {
+	if p.err != nil {
+		return p.err
+	}
+	return png.Encode(w, p.img)
+}
@@ -0,0 +1,39 @@
+package certificate
+
+import "time"
+
+// Record holds the per-recipient values a certificate is generated from.
+type Record struct {
+	Name      string
+	RegNumber string
+	Course    string
+	IssuedAt  time.Time         // zero means "now"
+	ExpiresAt time.Time         // zero means VALIDITY_MONTHS after issue, or never
+	Fields    map[string]string // extra columns, available to templates
+}
+
+func (r Record) issuedAt() time.Time {
+	if r.IssuedAt.IsZero() {
+		return time.Now()
+	}
+	return r.IssuedAt
+}
+
+// issued is when rec's certificate is issued, in TIMEZONE.
+func (g *Generator) issued(rec Record) time.Time {
+	return rec.issuedAt().In(g.cfg.Location())
+}
+
+// ExpiresAt is when rec's certificate expires, in TIMEZONE: its own
+// ExpiresAt, else VALIDITY_MONTHS after it was issued. Zero means it never
+// expires.
+func (g *Generator) ExpiresAt(rec Record) time.Time {
+	switch {
+	case !rec.ExpiresAt.IsZero():
+		return rec.ExpiresAt.In(g.cfg.Location())
+	case g.cfg.ValidityMonths > 0:
+		// Months are counted on the local calendar
+		return g.issued(rec).AddDate(0, g.cfg.ValidityMonths, 0)
+	}
+	return time.Time{}
+}
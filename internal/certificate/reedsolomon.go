@@ -0,0 +1,53 @@
+package certificate
+
+// galoisField is GF(2^m), in which DataMatrix and Aztec compute their
+// Reed-Solomon error correction.
+type galoisField struct {
+	exp []int // exp[i] is α^i
+	log []int
+}
+
+// newGaloisField builds the field of size elements generated by the
+// primitive polynomial poly.
+func newGaloisField(poly, size int) *galoisField {
+	f := &galoisField{exp: make([]int, size-1), log: make([]int, size)}
+	x := 1
+	for i := range size - 1 {
+		f.exp[i], f.log[x] = x, i
+		if x <<= 1; x >= size {
+			x ^= poly
+		}
+	}
+	return f
+}
+
+func (f *galoisField) mul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return f.exp[(f.log[a]+f.log[b])%len(f.exp)]
+}
+
+// checkWords returns the n Reed-Solomon check words of data, for the
+// generator polynomial with the roots α^1 to α^n both symbologies use.
+func (f *galoisField) checkWords(data []int, n int) []int {
+	gen := []int{1}
+	for i := 1; i <= n; i++ {
+		next := make([]int, len(gen)+1)
+		for j, c := range gen {
+			next[j] ^= c
+			next[j+1] ^= f.mul(c, f.exp[i%len(f.exp)])
+		}
+		gen = next
+	}
+	check := make([]int, n)
+	for _, d := range data {
+		k := d ^ check[0]
+		copy(check, check[1:])
+		check[n-1] = 0
+		for j := range n {
+			check[j] ^= f.mul(gen[j+1], k)
+		}
+	}
+	return check
+}
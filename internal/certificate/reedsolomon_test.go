@@ -0,0 +1,50 @@
+package certificate
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestGaloisField checks that each field's powers of α visit every nonzero
+// element once, and the start of the Data Matrix table ISO/IEC 16022
+// lists.
+func TestGaloisField(t *testing.T) {
+	fields := map[string]*galoisField{"datamatrix": dataMatrixField}
+	for wordSize, f := range aztecFields {
+		fields[fmt.Sprintf("aztec GF(2^%d)", wordSize)] = f
+	}
+	for name, f := range fields {
+		seen := make([]bool, len(f.log))
+		for i, x := range f.exp {
+			if x <= 0 || x >= len(f.log) || seen[x] {
+				t.Fatalf("%s: α^%d = %d repeats or is out of the field", name, i, x)
+			}
+			seen[x] = true
+			if f.log[x] != i {
+				t.Errorf("%s: log(%d) = %d, want %d", name, x, f.log[x], i)
+			}
+		}
+	}
+
+	want := []int{1, 2, 4, 8, 16, 32, 64, 128, 45, 90, 180, 69, 138, 57, 114, 228}
+	for i, x := range want {
+		if got := dataMatrixField.exp[i]; got != x {
+			t.Errorf("datamatrix: α^%d = %d, want %d", i, got, x)
+		}
+	}
+}
+
+// checkSyndromes fails unless code, data words followed by n check words,
+// evaluates to zero at α^1 to α^n, the roots of the generator polynomial.
+func checkSyndromes(t *testing.T, f *galoisField, code []int, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		s := 0
+		for _, c := range code {
+			s = f.mul(s, f.exp[i%len(f.exp)]) ^ c
+		}
+		if s != 0 {
+			t.Errorf("syndrome %d of %v is %d, want 0", i, code, s)
+		}
+	}
+}
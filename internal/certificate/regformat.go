@@ -0,0 +1,82 @@
+package certificate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RegFormat controls how the registration number is printed. Only the
+// printed line is formatted: the QR code, the registry, file names and
+// verification links keep the raw RegNumber a record was issued with.
+type RegFormat struct {
+	// Pad zero-pads the last run of digits to at least Pad digits, so
+	// "2024-123" prints as "2024-000123" with 6; 0 leaves it.
+	Pad int
+	// Groups splits the padded number into groups of so many characters,
+	// such as "4,6", joined by Separator: "2024000123" prints as
+	// "2024-000123". What is left after the last group is one more group.
+	Groups    string
+	Separator string
+	Prefix    string
+	Suffix    string
+}
+
+// parseGroups parses REG_GROUPS; an empty string is no grouping.
+func parseGroups(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var sizes []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q is not a list of positive group sizes such as 4,6", s)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// format returns reg as printed. Groups must have been validated.
+func (f RegFormat) format(reg string) string {
+	if f.Pad > 0 {
+		end := strings.LastIndexFunc(reg, isDigit) + 1
+		start := strings.LastIndexFunc(reg[:end], func(r rune) bool { return !isDigit(r) }) + 1
+		if n := end - start; end > 0 && n < f.Pad {
+			reg = reg[:start] + strings.Repeat("0", f.Pad-n) + reg[start:]
+		}
+	}
+	if sizes, _ := parseGroups(f.Groups); len(sizes) > 0 {
+		var groups []string
+		rest := reg
+		for _, n := range sizes {
+			if rest == "" {
+				break
+			}
+			i := len(rest)
+			if utf8.RuneCountInString(rest) > n {
+				i = len(string([]rune(rest)[:n]))
+			}
+			groups = append(groups, rest[:i])
+			rest = rest[i:]
+		}
+		if rest != "" {
+			groups = append(groups, rest)
+		}
+		reg = strings.Join(groups, f.Separator)
+	}
+	return f.Prefix + reg + f.Suffix
+}
+
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+
+func (f RegFormat) validate(fail func(string, ...any)) {
+	if f.Pad < 0 {
+		fail("REG_PAD: must not be negative, got %d", f.Pad)
+	}
+	if _, err := parseGroups(f.Groups); err != nil {
+		fail("REG_GROUPS: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package certificate
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDF engines for PDF_ENGINE.
+const (
+	EngineFpdf   = "fpdf"   // github.com/go-pdf/fpdf, the maintained fork (default)
+	EngineGofpdf = "gofpdf" // github.com/jung-kurt/gofpdf, archived; kept as a fallback
+)
+
+// renderer is the drawing surface a certificate is laid out on: one
+// document with one page, or a sheet per page when imposing, in mm with the
+// origin at the top-left corner.
+// Everything the generator draws goes through it, so the PDF engine can be
+// replaced without touching the layout.
+type renderer interface {
+	SetFont(family, style string, size float64)
+	SetTextColor(r, g, b int)
+	SetDrawColor(r, g, b int)
+	SetFillColor(r, g, b int)
+	SetLineWidth(width float64)
+	// SetLineJoinStyle sets how strokes turn corners: "miter", "round" or
+	// "bevel".
+	SetLineJoinStyle(style string)
+	// SetTextRenderingMode sets how Text paints glyphs, as PDF's Tr
+	// operator: 0 fills them, 2 fills then strokes them with the draw
+	// color and line width.
+	SetTextRenderingMode(mode int)
+	// SetAlpha sets the opacity, 0 to 1, and the blend mode, by its PDF
+	// name such as "Multiply", of everything drawn after it.
+	SetAlpha(alpha float64, blendMode string)
+	StringWidth(s string) float64
+
+	// Text prints text with its baseline at (x, y).
+	Text(x, y float64, text string)
+	Line(x1, y1, x2, y2 float64)
+	Rect(x, y, w, h float64, style string)
+	Circle(x, y, r float64, style string)
+	// LinearGradient fills a rectangle blending color 1 into color 2 along
+	// the vector (x1, y1)–(x2, y2), in coordinates where the rectangle's
+	// lower-left corner is (0, 0) and its upper-right (1, 1).
+	// RadialGradient blends from (x1, y1) out to the circle of radius r
+	// around (x2, y2).
+	LinearGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2 float64)
+	RadialGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2, r float64)
+
+	// ImageFile draws the image at path, its type taken from the extension.
+	ImageFile(path string, x, y, w, h float64)
+	// Image draws a PNG read from r; name identifies it within the document.
+	Image(name string, r io.Reader, x, y, w, h float64)
+
+	// AddPage starts a new page the size of the first.
+	AddPage()
+	// TransformBegin saves the graphics state, which TransformEnd restores;
+	// in between, TransformTranslate moves the origin, TransformScale
+	// scales by sx and sy percent about (x, y), and ClipRect limits
+	// drawing to a rectangle.
+	TransformBegin()
+	TransformTranslate(tx, ty float64)
+	TransformScale(sx, sy, x, y float64)
+	ClipRect(x, y, w, h float64, outline bool)
+	ClipEnd()
+	TransformEnd()
+
+	// AddFont registers a TrueType font for family and style ("" or "B").
+	AddFont(family, style string, data []byte)
+	// Translator converts UTF-8 to the cp1252 the built-in fonts print.
+	Translator() func(string) string
+
+	// SetTagged makes the document a tagged PDF in language lang (a
+	// BCP 47 tag), whose reading order is the order content is tagged in.
+	SetTagged(lang string)
+	// BeginTag marks what is drawn until EndTag as a structure element of
+	// type tag, "P" or "Figure" with alt text, or as "Artifact" for
+	// decoration outside the structure. Both do nothing when untagged.
+	BeginTag(tag, alt string)
+	EndTag()
+	// SetMetadata embeds xmp, a complete XMP packet, as the document's
+	// metadata stream.
+	SetMetadata(xmp []byte)
+	// Attach embeds data as a file attachment of the document.
+	Attach(filename, description string, data []byte)
+	// SetCreationDate and SetModificationDate date the document, instead
+	// of the time it is written out.
+	SetCreationDate(t time.Time)
+	SetModificationDate(t time.Time)
+
+	Output(w io.Writer) error
+	// Error is the first error any call ran into; later calls are no-ops.
+	Error() error
+}
+
+// newRenderer starts a document on a page of width × height mm with the
+// engine named by PDF_ENGINE.
+func newRenderer(engine string, width, height float64) renderer {
+	if strings.EqualFold(engine, EngineGofpdf) {
+		return newGofpdfRenderer(width, height)
+	}
+	return newFpdfRenderer(width, height)
+}
+
+// ── go-pdf/fpdf ─────────────────────────────────────────────────────────────
+
+type fpdfRenderer struct {
+	*fpdf.Fpdf
+	extras
+	attachments []fpdf.Attachment
+}
+
+func newFpdfRenderer(width, height float64) renderer {
+	// Portrait with the page's own size gives the page exactly width ×
+	// height, whichever is larger
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           fpdf.SizeType{Wd: width, Ht: height},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	// Fonts and images in a fixed order, so equal documents are equal bytes
+	pdf.SetCatalogSort(true)
+	pdf.AddPage()
+	return &fpdfRenderer{Fpdf: pdf, extras: extras{tagging: tagging{raw: pdf.RawWriteStr}}}
+}
+
+func (p *fpdfRenderer) StringWidth(s string) float64 { return p.GetStringWidth(s) }
+
+func (p *fpdfRenderer) ImageFile(path string, x, y, w, h float64) {
+	p.ImageOptions(path, x, y, w, h, false, fpdf.ImageOptions{}, 0, "")
+}
+
+func (p *fpdfRenderer) Image(name string, r io.Reader, x, y, w, h float64) {
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	p.RegisterImageOptionsReader(name, opts, r)
+	p.ImageOptions(name, x, y, w, h, false, opts, 0, "")
+}
+
+func (p *fpdfRenderer) AddFont(family, style string, data []byte) {
+	p.AddUTF8FontFromBytes(family, style, data)
+}
+
+func (p *fpdfRenderer) Translator() func(string) string {
+	return p.UnicodeTranslatorFromDescriptor("")
+}
+
+func (p *fpdfRenderer) Attach(filename, description string, data []byte) {
+	p.attachments = append(p.attachments, fpdf.Attachment{Content: data, Filename: filename, Description: description})
+	p.SetAttachments(p.attachments)
+}
+
+func (p *fpdfRenderer) Output(w io.Writer) error {
+	return p.output(w, p.Fpdf.Output)
+}
+
+// ── jung-kurt/gofpdf ────────────────────────────────────────────────────────
+
+type gofpdfRenderer struct {
+	*gofpdf.Fpdf
+	extras
+	attachments []gofpdf.Attachment
+}
+
+func newGofpdfRenderer(width, height float64) renderer {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: width, Ht: height},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	// Fonts and images in a fixed order, so equal documents are equal bytes
+	pdf.SetCatalogSort(true)
+	pdf.AddPage()
+	return &gofpdfRenderer{Fpdf: pdf, extras: extras{tagging: tagging{raw: pdf.RawWriteStr}}}
+}
+
+func (p *gofpdfRenderer) StringWidth(s string) float64 { return p.GetStringWidth(s) }
+
+func (p *gofpdfRenderer) ImageFile(path string, x, y, w, h float64) {
+	p.ImageOptions(path, x, y, w, h, false, gofpdf.ImageOptions{}, 0, "")
+}
+
+func (p *gofpdfRenderer) Image(name string, r io.Reader, x, y, w, h float64) {
+	opts := gofpdf.ImageOptions{ImageType: "PNG"}
+	p.RegisterImageOptionsReader(name, opts, r)
+	p.ImageOptions(name, x, y, w, h, false, opts, 0, "")
+}
+
+func (p *gofpdfRenderer) AddFont(family, style string, data []byte) {
+	p.AddUTF8FontFromBytes(family, style, data)
+}
+
+func (p *gofpdfRenderer) Translator() func(string) string {
+	return p.UnicodeTranslatorFromDescriptor("")
+}
+
+func (p *gofpdfRenderer) Attach(filename, description string, data []byte) {
+	p.attachments = append(p.attachments, gofpdf.Attachment{Content: data, Filename: filename, Description: description})
+	p.SetAttachments(p.attachments)
+}
+
+func (p *gofpdfRenderer) Output(w io.Writer) error {
+	return p.output(w, p.Fpdf.Output)
+}
@@ -0,0 +1,113 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// GenerateResult describes a generated certificate, so callers need not
+// re-read the PDF or recompute what the generator already knew.
+type GenerateResult struct {
+	Name      string // after NAME_CASE and NAME_HONORIFICS, before transliteration
+	Path      string // empty for Render, which writes to a caller's writer
+	Size      int64  // bytes of PDF
+	SHA256    string // hex digest of the PDF
+	VerifyURL string
+	QRPayload string    // content encoded in the QR code
+	LinkedIn  string    // LinkedIn "Add to Profile" link; empty if not configured
+	ExpiresAt time.Time // zero when the certificate does not expire
+
+	PageWidthMM  float64
+	PageHeightMM float64
+
+	Duration time.Duration
+	// Skipped is set when OUTPUT_EXISTS=skip kept an existing file; Size
+	// and SHA256 then describe that file.
+	Skipped bool
+
+	SidecarPath string // the Sidecar written with SIDECAR_JSON; empty without
+
+	ConfigVersion string // CONFIG_VERSION
+	ConfigHash    string // see Generator.ConfigHash
+}
+
+// newResult fills in what is known about rec's certificate before it is
+// rendered.
+func (g *Generator) newResult(rec Record) GenerateResult {
+	w, h := g.cfg.PaperSize()
+	return GenerateResult{
+		Name:         g.cfg.NameRules.formatName(rec.Name),
+		VerifyURL:    g.cfg.VerificationURL(rec.RegNumber),
+		QRPayload:    g.QRPayload(rec),
+		LinkedIn:     g.LinkedInURL(rec),
+		ExpiresAt:    g.ExpiresAt(rec),
+		PageWidthMM:  w,
+		PageHeightMM: h,
+
+		ConfigVersion: g.cfg.Version,
+		ConfigHash:    g.configHash,
+	}
+}
+
+// QRPayload is the content of rec's QR code: the verification URL, with
+// the expiry date as a query parameter for certificates that expire so a
+// scan shows it without a lookup.
+func (g *Generator) QRPayload(rec Record) string {
+	exp := g.ExpiresAt(rec)
+	if exp.IsZero() {
+		return g.cfg.VerificationURL(rec.RegNumber)
+	}
+	base := strings.TrimRight(g.cfg.VerificationBaseURL, "/")
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "expires=" + exp.Format(time.DateOnly) + "#" + rec.RegNumber
+}
+
+// digest counts and hashes what is written through it.
+type digest struct {
+	w io.Writer
+	h hash.Hash
+	n int64
+}
+
+func newDigest(w io.Writer) *digest {
+	return &digest{w: w, h: sha256.New()}
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.h.Write(p[:n])
+	d.n += int64(n)
+	return n, err
+}
+
+func (d *digest) sum() string {
+	return hex.EncodeToString(d.h.Sum(nil))
+}
+
+// FileSHA256 returns the hex SHA-256 of the file at path.
+func FileSHA256(path string) (string, error) {
+	sum, _, err := fileDigest(path)
+	return sum, err
+}
+
+func fileDigest(path string) (sum string, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	d := newDigest(io.Discard)
+	if _, err := io.Copy(d, f); err != nil {
+		return "", 0, err
+	}
+	return d.sum(), d.n, nil
+}
@@ -0,0 +1,141 @@
+package certificate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sidecar is the "digital twin" of a generated certificate: everything its
+// PDF prints and encodes and what it was made from, written with the PDF
+// with SIDECAR_JSON for systems that want the certificate as data. Unlike
+// the attached Credential, it carries every record field.
+type Sidecar struct {
+	RegNumber string            `json:"reg_number"`
+	Name      string            `json:"name"` // as in the record
+	Course    string            `json:"course,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	Locale    string            `json:"locale"`
+
+	Printed   SidecarText `json:"printed"`
+	VerifyURL string      `json:"verify_url"`
+	QR        SidecarCode `json:"qr"`
+	LinkedIn  string      `json:"linkedin_url,omitempty"`
+
+	PDF      SidecarFile     `json:"pdf"`
+	Template SidecarFile     `json:"template"`
+	Profile  *SidecarProfile `json:"profile,omitempty"` // for records of a COURSE_CATALOG course
+	Config   SidecarConfig   `json:"config"`
+}
+
+// SidecarText is the text printed on a certificate, after NAME_CASE,
+// REG_TEMPLATE, grade rules and transliteration.
+type SidecarText struct {
+	Name         string   `json:"name"`
+	Registration string   `json:"registration,omitempty"`
+	Grade        string   `json:"grade,omitempty"`
+	Expiry       string   `json:"expiry,omitempty"`
+	Signatories  []string `json:"signatories,omitempty"`
+}
+
+// SidecarCode is the printed 2D code.
+type SidecarCode struct {
+	Payload   string `json:"payload"`
+	Symbology string `json:"symbology"`
+}
+
+// SidecarFile identifies a file by its digest, which versions a template
+// however it is named.
+type SidecarFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// SidecarProfile is the course a certificate was laid out for, and the
+// digest of the course's profile file.
+type SidecarProfile struct {
+	CourseCode string `json:"course_code"`
+	File       string `json:"file,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
+}
+
+// SidecarConfig identifies the configuration a certificate was laid out
+// with, as the registry records it.
+type SidecarConfig struct {
+	Version string `json:"version,omitempty"`
+	Hash    string `json:"hash"`
+}
+
+// sidecar describes rec's certificate, generated as res says.
+func (g *Generator) sidecar(rec Record, res GenerateResult) (Sidecar, error) {
+	cfg := g.cfg
+	text, err := g.lines(rec)
+	if err != nil {
+		return Sidecar{}, err
+	}
+	locale, err := g.locale(rec)
+	if err != nil {
+		return Sidecar{}, err
+	}
+	s := Sidecar{
+		RegNumber: rec.RegNumber,
+		Name:      rec.Name,
+		Course:    rec.Course,
+		Fields:    rec.Fields,
+		IssuedAt:  g.issued(rec),
+		Locale:    locale,
+		Printed: SidecarText{
+			Name:         text.name,
+			Registration: text.reg,
+			Grade:        text.grade,
+			Expiry:       text.expiry,
+		},
+		VerifyURL: res.VerifyURL,
+		QR:        SidecarCode{Payload: res.QRPayload, Symbology: strings.ToLower(cfg.QR.Symbology)},
+		LinkedIn:  res.LinkedIn,
+		PDF:       SidecarFile{Path: res.Path, Size: res.Size, SHA256: res.SHA256},
+		Template:  SidecarFile{Path: cfg.TemplateImage, SHA256: g.assets[cfg.TemplateImage]},
+		Profile:   g.profile,
+		Config:    SidecarConfig{Version: res.ConfigVersion, Hash: res.ConfigHash},
+	}
+	if !res.ExpiresAt.IsZero() {
+		s.ExpiresAt = &res.ExpiresAt
+	}
+	for _, sig := range cfg.Signatories {
+		s.Printed.Signatories = append(s.Printed.Signatories, sig.Name)
+	}
+	return s, nil
+}
+
+// writeSidecar writes the Sidecar of rec's certificate, generated as res
+// says, and returns its path: the PDF's name with a .json extension, in
+// SIDECAR_DIR or beside the PDF.
+func (g *Generator) writeSidecar(rec Record, res GenerateResult) (string, error) {
+	s, err := g.sidecar(rec, res)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(res.Path)
+	if g.cfg.SidecarDir != "" {
+		dir = g.cfg.SidecarDir
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("cannot create sidecar directory: %w", err)
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(res.Path), filepath.Ext(res.Path)) + ".json"
+	return writeAtomic(filepath.Join(dir, name), nil, func(w io.Writer) error {
+		_, err := w.Write(append(b, '\n'))
+		return err
+	})
+}
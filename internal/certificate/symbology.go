@@ -0,0 +1,96 @@
+package certificate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Symbologies for QR_SYMBOLOGY, for venues whose scanners read other 2D
+// codes than QR. All of them carry the same payload and take the same
+// colors, module style, gradient and size; the eye settings only shape
+// QR finder patterns.
+const (
+	SymbologyQR         = "qr"         // QR code (default)
+	SymbologyDataMatrix = "datamatrix" // ECC 200 Data Matrix, square symbols
+	SymbologyAztec      = "aztec"      // Aztec code
+)
+
+// symbolQuietZone is the light border around Data Matrix and Aztec
+// symbols, in modules. Neither needs more than one.
+const symbolQuietZone = 1
+
+// symbol is an encoded 2D code.
+type symbol struct {
+	name    string   // for messages, e.g. "QR code"
+	level   string   // QR_ERROR_CORRECTION it is encoded at; empty for Data Matrix
+	modules [][]bool // by row, true for dark, including the quiet zone
+	eyes    [][2]int // top-left modules of the QR finder patterns
+	qr      *qrcode.QRCode
+}
+
+// encodeSymbol encodes content in the configured symbology.
+func (q QRConfig) encodeSymbol(content string) (symbol, error) {
+	level := q.recoveryLevel()
+	switch strings.ToLower(q.Symbology) {
+	case SymbologyDataMatrix:
+		modules, err := encodeDataMatrix([]byte(content))
+		if err != nil {
+			return symbol{}, fmt.Errorf("Data Matrix creation failed: %w", err)
+		}
+		return symbol{name: "Data Matrix", modules: withQuietZone(modules, symbolQuietZone)}, nil
+	case SymbologyAztec:
+		percent := aztecCheckPercent[level]
+		modules, err := encodeAztec([]byte(content), percent)
+		if err != nil {
+			return symbol{}, fmt.Errorf("Aztec code creation failed: %w", err)
+		}
+		return symbol{name: "Aztec code", level: qrLevelName(level), modules: withQuietZone(modules, symbolQuietZone)}, nil
+	}
+	qr, err := qrcode.New(content, level)
+	if err != nil {
+		return symbol{}, fmt.Errorf("QR creation failed: %w", err)
+	}
+	bitmap := qr.Bitmap()
+	far := len(bitmap) - qrQuietZone - 7
+	return symbol{
+		name:    "QR code",
+		level:   qrLevelName(level),
+		modules: bitmap,
+		eyes:    [][2]int{{qrQuietZone, qrQuietZone}, {far, qrQuietZone}, {qrQuietZone, far}},
+		qr:      qr,
+	}, nil
+}
+
+// aztecCheckPercent is the share of an Aztec symbol's capacity given to
+// check words at each QR_ERROR_CORRECTION level, from the 23% the
+// specification recommends as a minimum.
+var aztecCheckPercent = map[qrcode.RecoveryLevel]int{
+	qrcode.Low:     23,
+	qrcode.Medium:  33,
+	qrcode.High:    50,
+	qrcode.Highest: 66,
+}
+
+// withQuietZone returns modules surrounded by a light border n modules wide.
+func withQuietZone(modules [][]bool, n int) [][]bool {
+	size := len(modules) + 2*n
+	out := make([][]bool, size)
+	for y := range out {
+		out[y] = make([]bool, size)
+		if y >= n && y < size-n {
+			copy(out[y][n:], modules[y-n])
+		}
+	}
+	return out
+}
+
+// newModules returns an empty size × size symbol.
+func newModules(size int) [][]bool {
+	modules := make([][]bool, size)
+	for y := range modules {
+		modules[y] = make([]bool, size)
+	}
+	return modules
+}
@@ -0,0 +1,83 @@
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// structure is the logical structure of a tagged PDF page: the elements
+// marked while drawing, in reading order.
+type structure struct {
+	lang  string
+	elems []structElem
+}
+
+type structElem struct {
+	tag string // standard structure type: P, Figure
+	alt string // alternate description, for figures
+}
+
+// tagging adds marked content to an engine's page through raw, which
+// writes operators into the page's content stream. A nil tree leaves the
+// document untagged; otherwise the tree is written with the document's
+// extras.
+type tagging struct {
+	raw  func(string)
+	tree *structure
+	open bool
+}
+
+func (t *tagging) SetTagged(lang string) {
+	t.tree = &structure{lang: lang}
+}
+
+func (t *tagging) BeginTag(tag, alt string) {
+	if t.tree == nil {
+		return
+	}
+	if tag == "Artifact" {
+		t.raw("/Artifact BMC")
+	} else {
+		t.raw(fmt.Sprintf("/%s <</MCID %d>> BDC", tag, len(t.tree.elems)))
+		t.tree.elems = append(t.tree.elems, structElem{tag: tag, alt: alt})
+	}
+	t.open = true
+}
+
+func (t *tagging) EndTag() {
+	if t.open {
+		t.raw("EMC")
+		t.open = false
+	}
+}
+
+// write adds the structure tree to u, tying it to the page, and returns
+// the catalog entries declaring it.
+func (s *structure) write(u *pdfUpdate) (string, error) {
+	page, err := u.dict(u.page)
+	if err != nil {
+		return "", err
+	}
+	treeRoot, doc := u.reserve(), u.reserve()
+	var kids bytes.Buffer
+	for i, e := range s.elems {
+		alt := ""
+		if e.alt != "" {
+			alt = " /Alt " + pdfString(e.alt)
+		}
+		num := u.add("<< /Type /StructElem /S /%s /P %d 0 R /Pg %d 0 R /K %d%s >>", e.tag, doc, u.page, i, alt)
+		fmt.Fprintf(&kids, " %d 0 R", num)
+	}
+	u.replace(doc, "<< /Type /StructElem /S /Document /P %d 0 R /K [%s ] >>", treeRoot, kids.String())
+	// MCIDs count from 0 in drawing order, so the page's entry in the
+	// parent tree lists the elements in the same order
+	parentTree := u.add("<< /Nums [0 [%s ]] >>", kids.String())
+	u.replace(treeRoot, "<< /Type /StructTreeRoot /K %d 0 R /ParentTree %d 0 R /ParentTreeNextKey 1 >>", doc, parentTree)
+	u.replace(u.page, "<<%s /StructParents 0 /Tabs /S >>", page)
+
+	entries := fmt.Sprintf(" /MarkInfo << /Marked true >> /StructTreeRoot %d 0 R /Lang %s", treeRoot, pdfString(s.lang))
+	if u.version < 4 {
+		entries += " /Version /1.4" // marked content needs PDF 1.4
+	}
+	return entries, nil
+}
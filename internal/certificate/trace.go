@@ -0,0 +1,20 @@
+package certificate
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span for each stage of generation. It uses the global
+// provider, so spans are dropped unless the program installs one.
+var tracer = otel.Tracer("github.com/Sathimantha/certificate_generator_go/internal/certificate")
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
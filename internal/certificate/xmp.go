@@ -0,0 +1,52 @@
+package certificate
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// XMPNamespace is the namespace of the certificate properties in the XMP
+// metadata of every PDF, with the preferred prefix "cert".
+const XMPNamespace = "https://github.com/Sathimantha/certificate_generator_go/ns/certificate/1.0/"
+
+// xmp returns the XMP packet describing rec's certificate, so document
+// management systems can index it without reading the page. Properties
+// without a value are left out.
+func (g *Generator) xmp(rec Record) []byte {
+	issued := g.issued(rec)
+	props := []struct{ name, value string }{
+		{"cert:Recipient", g.cfg.NameRules.formatName(rec.Name)},
+		{"cert:RegistrationNumber", rec.RegNumber},
+		{"cert:Course", rec.Course},
+		{"cert:Issuer", g.cfg.IssuerName},
+		{"cert:IssueDate", issued.Format(time.RFC3339)},
+		{"cert:VerificationURL", g.cfg.VerificationURL(rec.RegNumber)},
+	}
+	if exp := g.ExpiresAt(rec); !exp.IsZero() {
+		props = append(props, struct{ name, value string }{"cert:ExpiryDate", exp.Format(time.RFC3339)})
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:cert="` + XMPNamespace + `">
+   <dc:format>application/pdf</dc:format>
+`)
+	fmt.Fprintf(&b, "   <xmp:CreateDate>%s</xmp:CreateDate>\n", issued.Format(time.RFC3339))
+	for _, p := range props {
+		if p.value == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "   <%s>", p.name)
+		xml.EscapeText(&b, []byte(p.value))
+		fmt.Fprintf(&b, "</%s>\n", p.name)
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"r\"?>")
+	return b.Bytes()
+}
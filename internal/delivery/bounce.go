@@ -0,0 +1,218 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+// emailSink is the sink name the Email sink records its deliveries under.
+const emailSink = "email"
+
+// EmailEvent is a provider's report on one sent email.
+type EmailEvent struct {
+	MessageID string    // Message-ID header of the email, if the provider reports it
+	To        string    // recipient address
+	Status    string    // a registry delivery status
+	Reason    string    // bounce diagnostic
+	At        time.Time // when the provider saw it; zero if not reported
+}
+
+// ErrSubscription is returned by ParseEmailEvents for an SNS subscription
+// confirmation. It is not confirmed automatically: the subscription URL is
+// in the error for an operator to visit.
+var ErrSubscription = errors.New("SNS subscription confirmation")
+
+// ParseEmailEvents reads the body of an email provider's webhook:
+//
+//   - SendGrid event arrays (bounce, dropped, delivered, spamreport)
+//   - Amazon SES notifications delivered through SNS (Bounce, Delivery,
+//     Complaint)
+//   - a generic object or array of objects with message_id, email,
+//     status (a registry delivery status), reason and timestamp
+//
+// Events the registry does not track, such as opens, clicks and transient
+// bounces the provider still retries, are skipped.
+func ParseEmailEvents(body []byte) ([]EmailEvent, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] == '[' {
+		var events []genericEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, fmt.Errorf("email events: %w", err)
+		}
+		return convertGeneric(events), nil
+	}
+
+	var probe struct {
+		Type         string
+		Message      string
+		SubscribeURL string
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("email events: %w", err)
+	}
+	switch probe.Type {
+	case "":
+		var e genericEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, fmt.Errorf("email events: %w", err)
+		}
+		return convertGeneric([]genericEvent{e}), nil
+	case "Notification":
+		return parseSES([]byte(probe.Message))
+	case "SubscriptionConfirmation":
+		return nil, fmt.Errorf("%w: confirm at %s", ErrSubscription, probe.SubscribeURL)
+	default:
+		return nil, nil
+	}
+}
+
+// ApplyEmailEvents records events as the email delivery status of the
+// certificates they are about, found by Message-ID or else by recipient.
+// It returns how many events matched a certificate and how many did not.
+func ApplyEmailEvents(reg *registry.Registry, events []EmailEvent) (updated, unmatched int, err error) {
+	for _, ev := range events {
+		e, ok := reg.FindDelivery(emailSink, ev.MessageID, ev.To)
+		if !ok {
+			unmatched++
+			continue
+		}
+		s := e.Deliveries[emailSink]
+		s.Status, s.Error, s.At = ev.Status, ev.Reason, ev.At
+		if s.At.IsZero() {
+			s.At = time.Now()
+		}
+		if _, err := reg.SetDelivery(e.RegNumber, emailSink, s); err != nil {
+			return updated, unmatched, err
+		}
+		updated++
+	}
+	return updated, unmatched, nil
+}
+
+// ── SendGrid and generic events ────────────────────────────────────────────
+
+type genericEvent struct {
+	MessageID string          `json:"message_id"`
+	SMTPID    string          `json:"smtp-id"` // SendGrid
+	Email     string          `json:"email"`
+	Status    string          `json:"status"`
+	Event     string          `json:"event"` // SendGrid
+	Reason    string          `json:"reason"`
+	Timestamp json.RawMessage `json:"timestamp"` // RFC 3339, or Unix seconds from SendGrid
+}
+
+// sendgridStatus maps SendGrid event types to delivery statuses.
+var sendgridStatus = map[string]string{
+	"bounce":     registry.DeliveryBounced,
+	"dropped":    registry.DeliveryFailed,
+	"delivered":  registry.DeliveryDelivered,
+	"spamreport": registry.DeliveryComplained,
+}
+
+var knownStatus = map[string]bool{
+	registry.DeliverySent:       true,
+	registry.DeliveryFailed:     true,
+	registry.DeliveryDelivered:  true,
+	registry.DeliveryBounced:    true,
+	registry.DeliveryComplained: true,
+}
+
+func convertGeneric(events []genericEvent) []EmailEvent {
+	var out []EmailEvent
+	for _, e := range events {
+		status := strings.ToLower(e.Status)
+		if e.Event != "" {
+			status = sendgridStatus[strings.ToLower(e.Event)]
+		}
+		if !knownStatus[status] {
+			continue
+		}
+		out = append(out, EmailEvent{
+			MessageID: orDefault(e.MessageID, e.SMTPID),
+			To:        e.Email,
+			Status:    status,
+			Reason:    e.Reason,
+			At:        parseTimestamp(e.Timestamp),
+		})
+	}
+	return out
+}
+
+func parseTimestamp(raw json.RawMessage) time.Time {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	if secs, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return time.Unix(secs, 0)
+	}
+	return time.Time{}
+}
+
+// ── Amazon SES ──────────────────────────────────────────────────────────────
+
+type sesRecipient struct {
+	EmailAddress   string `json:"emailAddress"`
+	DiagnosticCode string `json:"diagnosticCode"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	Mail             struct {
+		CommonHeaders struct {
+			MessageID string `json:"messageId"`
+		} `json:"commonHeaders"`
+	} `json:"mail"`
+	Bounce struct {
+		BounceType        string         `json:"bounceType"`
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+		Timestamp         string         `json:"timestamp"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+		Timestamp            string         `json:"timestamp"`
+	} `json:"complaint"`
+	Delivery struct {
+		Recipients []string `json:"recipients"`
+		Timestamp  string   `json:"timestamp"`
+	} `json:"delivery"`
+}
+
+func parseSES(msg []byte) ([]EmailEvent, error) {
+	var n sesNotification
+	if err := json.Unmarshal(msg, &n); err != nil {
+		return nil, fmt.Errorf("SES notification: %w", err)
+	}
+	id := n.Mail.CommonHeaders.MessageID
+	var out []EmailEvent
+	add := func(to, status, reason, at string) {
+		t, _ := time.Parse(time.RFC3339, at)
+		out = append(out, EmailEvent{MessageID: id, To: to, Status: status, Reason: reason, At: t})
+	}
+	switch n.NotificationType {
+	case "Bounce":
+		// Transient bounces are still being retried by SES
+		if n.Bounce.BounceType == "Transient" {
+			return nil, nil
+		}
+		for _, r := range n.Bounce.BouncedRecipients {
+			add(r.EmailAddress, registry.DeliveryBounced, r.DiagnosticCode, n.Bounce.Timestamp)
+		}
+	case "Complaint":
+		for _, r := range n.Complaint.ComplainedRecipients {
+			add(r.EmailAddress, registry.DeliveryComplained, "", n.Complaint.Timestamp)
+		}
+	case "Delivery":
+		for _, to := range n.Delivery.Recipients {
+			add(to, registry.DeliveryDelivered, "", n.Delivery.Timestamp)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package delivery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+// TestParseEmailEvents parses webhook bodies in the formats SendGrid and
+// SES through SNS document, in testdata, and the generic one.
+func TestParseEmailEvents(t *testing.T) {
+	const msgID = "<R-1.1736933400@certgen.example.com>"
+	tests := []struct {
+		name string
+		body string // file in testdata, or the body itself
+		want []EmailEvent
+	}{
+		{
+			name: "sendgrid",
+			body: "sendgrid.json", // processed and open are not tracked
+			want: []EmailEvent{
+				{MessageID: msgID, To: "ada@example.org", Status: registry.DeliveryDelivered, At: time.Unix(1736933404, 0)},
+				{MessageID: "<R-2.1736933400@certgen.example.com>", To: "grace@example.org", Status: registry.DeliveryBounced,
+					Reason: "550 5.1.1 The email account that you tried to reach does not exist.", At: time.Unix(1736933405, 0)},
+				{MessageID: "<R-3.1736933400@certgen.example.com>", To: "alan@example.org", Status: registry.DeliveryFailed,
+					Reason: "Bounced Address", At: time.Unix(1736933406, 0)},
+				{MessageID: msgID, To: "ada@example.org", Status: registry.DeliveryComplained, At: time.Unix(1736940000, 0)},
+			},
+		},
+		{
+			name: "ses bounce",
+			body: "ses-bounce.json",
+			want: []EmailEvent{{MessageID: msgID, To: "ada@example.org", Status: registry.DeliveryBounced,
+				Reason: "smtp; 550 5.1.1 user unknown", At: time.Date(2025, 1, 15, 9, 30, 4, 512e6, time.UTC)}},
+		},
+		{
+			name: "ses transient bounce",
+			body: "ses-transient.json", // SES still retries
+		},
+		{
+			name: "ses complaint",
+			body: "ses-complaint.json",
+			want: []EmailEvent{{MessageID: msgID, To: "ada@example.org", Status: registry.DeliveryComplained,
+				At: time.Date(2025, 1, 16, 11, 2, 0, 0, time.UTC)}},
+		},
+		{
+			name: "ses delivery",
+			body: "ses-delivery.json",
+			want: []EmailEvent{{MessageID: msgID, To: "ada@example.org", Status: registry.DeliveryDelivered,
+				At: time.Date(2025, 1, 15, 9, 30, 3, 290e6, time.UTC)}},
+		},
+		{
+			name: "generic object",
+			body: `{"message_id":"<R-1@x>","email":"ada@example.org","status":"Bounced","reason":"mailbox unknown","timestamp":"2025-01-15T15:00:04+05:30"}`,
+			want: []EmailEvent{{MessageID: "<R-1@x>", To: "ada@example.org", Status: registry.DeliveryBounced,
+				Reason: "mailbox unknown", At: time.Date(2025, 1, 15, 9, 30, 4, 0, time.UTC)}},
+		},
+		{
+			name: "generic array",
+			body: ` [{"email":"ada@example.org","status":"delivered","timestamp":1736933404},{"email":"ada@example.org","status":"opened"},{"email":"grace@example.org","status":"sent"}]`,
+			want: []EmailEvent{
+				{To: "ada@example.org", Status: registry.DeliveryDelivered, At: time.Unix(1736933404, 0)},
+				{To: "grace@example.org", Status: registry.DeliverySent},
+			},
+		},
+		{
+			name: "sns unsubscribe confirmation",
+			body: `{"Type":"UnsubscribeConfirmation","Message":"You have chosen to deactivate subscription"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(tt.body)
+			if filepath.Ext(tt.body) == ".json" {
+				var err error
+				if body, err = os.ReadFile(filepath.Join("testdata", tt.body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ParseEmailEvents(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d events %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if !got[i].At.Equal(tt.want[i].At) {
+					t.Errorf("event %d at %v, want %v", i, got[i].At, tt.want[i].At)
+				}
+				got[i].At, tt.want[i].At = time.Time{}, time.Time{}
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("event %d is %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseEmailEventsErrors(t *testing.T) {
+	_, err := ParseEmailEvents([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}`))
+	if !errors.Is(err, ErrSubscription) {
+		t.Errorf("subscription confirmation: %v, want ErrSubscription", err)
+	}
+	for _, body := range []string{`[{"email":`, `not json`, `{"Type":"Notification","Message":"{"}`} {
+		if _, err := ParseEmailEvents([]byte(body)); err == nil {
+			t.Errorf("%s: parsed without error", body)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package delivery implements issuer sinks that send certificates out of
+// the output directory: by email over SMTP, and by HTTP PUT to object
+// storage. It also reads the bounce and delivery reports email providers
+// post back.
+package delivery
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// templateData is what subject, body and URL templates are executed
+// against.
+type templateData struct {
+	Name      string // after NAME_CASE and NAME_HONORIFICS, as issued
+	RegNumber string
+	Course    string
+	VerifyURL string
+	LinkedIn  string // "Add to Profile" link, if configured
+	Fields    map[string]string
+	// Links are where the certificate was delivered by the sinks before
+	// this one, by sink name, e.g. {{.Links.googlewallet}}.
+	Links map[string]string
+}
+
+func newTemplateData(rec certificate.Record, res issuer.Result) templateData {
+	links := map[string]string{}
+	for _, d := range res.Deliveries {
+		if d.OK {
+			links[d.Sink] = d.Location
+		}
+	}
+	return templateData{
+		Name:      res.Name,
+		RegNumber: rec.RegNumber,
+		Course:    rec.Course,
+		VerifyURL: res.VerifyURL,
+		LinkedIn:  res.LinkedIn,
+		Fields:    rec.Fields,
+		Links:     links,
+	}
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+func execute(t *template.Template, data templateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%s template: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
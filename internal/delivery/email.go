@@ -0,0 +1,199 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// Default email templates.
+const (
+	DefaultSubject = "Your certificate {{.RegNumber}}"
+	DefaultBody    = "Dear {{.Name}},\n\nYour certificate is attached. It can be verified at {{.VerifyURL}}\n"
+)
+
+// Email mails each certificate as a PDF attachment to the address in the
+// record's "email" field.
+type Email struct {
+	addr    string
+	from    *mail.Address
+	auth    smtp.Auth
+	subject *template.Template
+	body    *template.Template
+
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// EmailConfig configures an Email sink.
+type EmailConfig struct {
+	Addr     string // SMTP server host:port
+	Username string // with Password, enables PLAIN auth
+	Password string
+	From     string
+	Subject  string // text/template; DefaultSubject if empty
+	Body     string // text/template; DefaultBody if empty
+}
+
+// NewEmail returns an Email sink for cfg.
+func NewEmail(cfg EmailConfig) (*Email, error) {
+	var errs []error
+	if cfg.Addr == "" {
+		errs = append(errs, errors.New("SMTP_ADDR is required"))
+	}
+	host, _, _ := strings.Cut(cfg.Addr, ":")
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("SMTP_FROM: %w", err))
+	}
+	subject, err := parseTemplate("EMAIL_SUBJECT", orDefault(cfg.Subject, DefaultSubject))
+	errs = append(errs, err)
+	body, err := parseTemplate("EMAIL_BODY", orDefault(cfg.Body, DefaultBody))
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	e := &Email{addr: cfg.Addr, from: from, subject: subject, body: body, send: smtp.SendMail}
+	if cfg.Username != "" {
+		e.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return e, nil
+}
+
+// Name implements issuer.Sink.
+func (e *Email) Name() string { return emailSink }
+
+// Recipient implements issuer.Addressed. It returns the bare address, as
+// bounce notifications name it.
+func (e *Email) Recipient(rec certificate.Record) string {
+	if to, err := mail.ParseAddress(rec.Fields["email"]); err == nil {
+		return to.Address
+	}
+	return rec.Fields["email"]
+}
+
+// WithRecipient implements issuer.Addressed.
+func (e *Email) WithRecipient(rec certificate.Record, to string) certificate.Record {
+	rec.Fields = maps.Clone(rec.Fields)
+	if rec.Fields == nil {
+		rec.Fields = map[string]string{}
+	}
+	rec.Fields["email"] = to
+	return rec
+}
+
+// Deliver implements issuer.Sink. It returns the Message-ID.
+func (e *Email) Deliver(ctx context.Context, rec certificate.Record, res issuer.Result) (string, error) {
+	to, err := mail.ParseAddress(rec.Fields["email"])
+	if err != nil {
+		return "", retry.Permanent(fmt.Errorf("recipient email: %w", err))
+	}
+	msg, id, err := e.message(to, rec, res)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	if err := e.send(e.addr, e.auth, e.from.Address, []string{to.Address}, msg); err != nil {
+		// 5xx replies are rejections; anything else may be transient.
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+			return "", retry.Permanent(err)
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+// message builds a multipart/mixed message with a text part and the PDF.
+func (e *Email) message(to *mail.Address, rec certificate.Record, res issuer.Result) (msg []byte, id string, err error) {
+	data := newTemplateData(rec, res)
+	subject, err := execute(e.subject, data)
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := execute(e.body, data)
+	if err != nil {
+		return nil, "", err
+	}
+	pdf, err := os.ReadFile(res.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nonce [8]byte
+	rand.Read(nonce[:])
+	_, domain, _ := strings.Cut(e.from.Address, "@")
+	id = fmt.Sprintf("<%s.%s@%s>", sanitizeID(rec.RegNumber), hex.EncodeToString(nonce[:]), domain)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	hdr := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	hdr("From", e.from.String())
+	hdr("To", to.String())
+	hdr("Subject", mime.QEncoding.Encode("utf-8", subject))
+	hdr("Date", time.Now().Format(time.RFC1123Z))
+	hdr("Message-ID", id)
+	hdr("MIME-Version", "1.0")
+	hdr("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	// Quoted-printable keeps lines within SMTP's limit even when the body
+	// holds long links, such as wallet save links
+	text, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qp := quotedprintable.NewWriter(text)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+
+	name := filepath.Base(res.Path)
+	att, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/pdf", map[string]string{"name": name})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	enc := base64.StdEncoding.EncodeToString(pdf)
+	for len(enc) > 76 {
+		att.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	att.Write([]byte(enc + "\r\n"))
+	mw.Close()
+	return buf.Bytes(), id, nil
+}
+
+func sanitizeID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' || strings.ContainsRune("<>@\"\\()[],;:", r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/template"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// Upload PUTs each certificate to a URL derived from the record, which
+// suits S3 and GCS buckets behind a gateway or presigning proxy, WebDAV and
+// similar object stores.
+type Upload struct {
+	url           *template.Template
+	authorization string
+	client        *http.Client
+}
+
+// NewUpload returns an Upload sink. urlTemplate is a text/template such as
+// "https://certs.example.org/{{.RegNumber}}.pdf"; authorization, if set,
+// is sent as the Authorization header.
+func NewUpload(urlTemplate, authorization string) (*Upload, error) {
+	t, err := parseTemplate("UPLOAD_URL", urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &Upload{url: t, authorization: authorization, client: &http.Client{}}, nil
+}
+
+// Name implements issuer.Sink.
+func (u *Upload) Name() string { return "upload" }
+
+// Deliver implements issuer.Sink. It returns the URL uploaded to.
+func (u *Upload) Deliver(ctx context.Context, rec certificate.Record, res issuer.Result) (string, error) {
+	url, err := execute(u.url, newTemplateData(rec, res))
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	f, err := os.Open(res.Path)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, f)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	req.ContentLength = fi.Size()
+	req.Header.Set("Content-Type", "application/pdf")
+	if u.authorization != "" {
+		req.Header.Set("Authorization", u.authorization)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return url, nil
+	case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500:
+		return "", fmt.Errorf("upload to %s: %s", url, resp.Status)
+	default:
+		return "", retry.Permanent(fmt.Errorf("upload to %s: %s", url, resp.Status))
+	}
+}
@@ -0,0 +1,164 @@
+// Package hook implements issuer hooks that hand each certificate to code
+// outside certgen: an external command, or a Go plugin.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// exitTempFail is EX_TEMPFAIL from sysexits.h: the command could not do
+// its job right now and should be asked again.
+const exitTempFail = 75
+
+// Command runs external programs before and after each certificate. Each
+// gets the event as JSON on stdin, {"event": "before", "record": {...}} or
+// {"event": "after", "record": {...}, "result": {...}}, and the main
+// values in CERTGEN_* environment variables.
+//
+// The before command accepts the record by exiting 0 and rejects it with
+// any other status, what it printed on stderr being the reason; 75
+// (EX_TEMPFAIL) fails the record without rejecting it. The after command
+// is retried when it exits 75 or times out, and fails the result for any
+// other non-zero status.
+type Command struct {
+	before, after []string
+	timeout       time.Duration
+}
+
+// NewCommand returns a hook running the command lines before and after,
+// split at spaces; either may be empty. Each run is killed after timeout,
+// if positive.
+func NewCommand(before, after string, timeout time.Duration) (*Command, error) {
+	c := &Command{before: strings.Fields(before), after: strings.Fields(after), timeout: timeout}
+	for _, argv := range [][]string{c.before, c.after} {
+		if len(argv) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Name implements issuer.Hook.
+func (c *Command) Name() string { return "exec" }
+
+// record is the JSON form of a certificate.Record, named like the API's.
+type record struct {
+	Name      string            `json:"name"`
+	RegNumber string            `json:"reg_number"`
+	Course    string            `json:"course,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+type event struct {
+	Event  string         `json:"event"`
+	Record record         `json:"record"`
+	Result *issuer.Result `json:"result,omitempty"`
+}
+
+func newEvent(name string, rec certificate.Record) event {
+	e := event{Event: name, Record: record{
+		Name:      rec.Name,
+		RegNumber: rec.RegNumber,
+		Course:    rec.Course,
+		IssuedAt:  rec.IssuedAt,
+		Fields:    rec.Fields,
+	}}
+	if !rec.ExpiresAt.IsZero() {
+		e.Record.ExpiresAt = &rec.ExpiresAt
+	}
+	return e
+}
+
+// Before implements issuer.Hook.
+func (c *Command) Before(ctx context.Context, rec certificate.Record) error {
+	if len(c.before) == 0 {
+		return nil
+	}
+	err := c.run(ctx, c.before, newEvent("before", rec), nil)
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() != exitTempFail {
+		return fmt.Errorf("%w: %w", issuer.ErrRejected, err)
+	}
+	return err
+}
+
+// After implements issuer.Hook.
+func (c *Command) After(ctx context.Context, rec certificate.Record, res issuer.Result) error {
+	if len(c.after) == 0 {
+		return nil
+	}
+	e := newEvent("after", rec)
+	e.Result = &res
+	err := c.run(ctx, c.after, e, []string{
+		"CERTGEN_PATH=" + res.Path,
+		"CERTGEN_SHA256=" + res.SHA256,
+		"CERTGEN_VERIFY_URL=" + res.VerifyURL,
+	})
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() != exitTempFail && ctx.Err() == nil {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// commandError is a failed run, with what the command said about it.
+type commandError struct {
+	err    error
+	stderr string
+}
+
+func (e *commandError) Error() string {
+	if e.stderr == "" {
+		return e.err.Error()
+	}
+	return e.stderr
+}
+
+func (e *commandError) Unwrap() error { return e.err }
+
+func (c *Command) run(ctx context.Context, argv []string, e event, env []string) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	in, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Env = append(os.Environ(),
+		"CERTGEN_HOOK="+e.Event,
+		"CERTGEN_NAME="+e.Record.Name,
+		"CERTGEN_REG_NUMBER="+e.Record.RegNumber,
+		"CERTGEN_COURSE="+e.Record.Course,
+	)
+	cmd.Env = append(cmd.Env, env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: %w", argv[0], ctx.Err())
+		}
+		return &commandError{err: fmt.Errorf("%s: %w", argv[0], err), stderr: strings.TrimSpace(stderr.String())}
+	}
+	return nil
+}
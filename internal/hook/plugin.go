@@ -0,0 +1,36 @@
+package hook
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// PluginSymbol is the variable a hook plugin exports.
+const PluginSymbol = "Hook"
+
+// OpenPlugin loads the hook exported as Hook by the Go plugin at path,
+// e.g. "var Hook issuer.Hook = legacyStamp{}". Plugins must be built with
+// -buildmode=plugin from this module, by the same Go release as certgen,
+// and only load on platforms with cgo.
+func OpenPlugin(path string) (issuer.Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch h := sym.(type) {
+	case *issuer.Hook:
+		if *h == nil {
+			return nil, fmt.Errorf("%s: %s is nil", path, PluginSymbol)
+		}
+		return *h, nil
+	case issuer.Hook:
+		return h, nil
+	}
+	return nil, fmt.Errorf("%s: %s is a %T, not an issuer.Hook", path, PluginSymbol, sym)
+}
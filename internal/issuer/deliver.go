@@ -0,0 +1,140 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sink delivers an issued certificate somewhere outside the output
+// directory, such as object storage or a recipient's mailbox, or derives a
+// companion from it, such as a wallet pass.
+type Sink interface {
+	// Name identifies the sink in results, e.g. "email".
+	Name() string
+	// Deliver sends the certificate at res.Path and returns where it went,
+	// such as a URL or message ID. Errors that retrying cannot fix should
+	// be wrapped with retry.Permanent.
+	Deliver(ctx context.Context, rec certificate.Record, res Result) (location string, err error)
+}
+
+// Addressed is implemented by sinks that send each certificate to a
+// recipient named in its record, such as an email address. The recipient
+// is recorded with the delivery status.
+type Addressed interface {
+	Sink
+	// Recipient returns who rec's certificate is sent to.
+	Recipient(rec certificate.Record) string
+	// WithRecipient returns rec addressed to to instead.
+	WithRecipient(rec certificate.Record, to string) certificate.Record
+}
+
+// Delivery is the outcome of one sink for one certificate.
+type Delivery struct {
+	Sink     string `json:"sink"`
+	OK       bool   `json:"ok"`
+	To       string `json:"to,omitempty"`
+	Location string `json:"location,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// deliver runs every sink under the retry policy. The first failed sink
+// fails the result, but every sink is still attempted.
+func (i *Issuer) deliver(ctx context.Context, rec certificate.Record, res *Result) {
+	for _, sink := range i.Sinks {
+		d, err := i.deliverTo(ctx, sink, rec, *res)
+		if err != nil && res.OK() {
+			res.fail(StageDeliver, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+		res.Deliveries = append(res.Deliveries, d)
+	}
+}
+
+// deliverTo runs one sink under the retry policy and records the outcome
+// in the registry.
+func (i *Issuer) deliverTo(ctx context.Context, sink Sink, rec certificate.Record, res Result) (Delivery, error) {
+	ctx, span := tracer.Start(ctx, "issuer.deliver",
+		trace.WithAttributes(attribute.String("certgen.sink", sink.Name())))
+	defer span.End()
+
+	d := Delivery{Sink: sink.Name()}
+	if a, ok := sink.(Addressed); ok {
+		d.To = a.Recipient(rec)
+	}
+	var err error
+	d.Attempts, err = i.Retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		d.Location, err = sink.Deliver(ctx, rec, res)
+		return err
+	})
+	span.SetAttributes(attribute.Int("certgen.attempts", d.Attempts))
+	status := registry.DeliveryStatus{Status: registry.DeliverySent, To: d.To, Location: d.Location, At: time.Now()}
+	if err != nil {
+		d.Error = err.Error()
+		span.SetStatus(codes.Error, d.Error)
+		status.Status, status.Error = registry.DeliveryFailed, d.Error
+	} else {
+		d.OK = true
+	}
+
+	if i.Registry != nil {
+		// OK stays true: the certificate went out, only its status is lost
+		if _, rerr := i.Registry.SetDelivery(rec.RegNumber, sink.Name(), status); rerr != nil && err == nil {
+			err = fmt.Errorf("recording delivery: %w", rerr)
+			d.Error = err.Error()
+		}
+	}
+	return d, err
+}
+
+// ErrNoSink is returned by Resend for a sink the issuer does not have.
+var ErrNoSink = errors.New("no such delivery sink")
+
+// Resend delivers the issued certificate regNumber through the sink named
+// sink again, e.g. after its email bounced, and records the new status.
+// A non-empty to readdresses it for an Addressed sink.
+func (i *Issuer) Resend(ctx context.Context, regNumber, sink, to string) (Delivery, error) {
+	var s Sink
+	for _, cand := range i.Sinks {
+		if cand.Name() == sink {
+			s = cand
+		}
+	}
+	if s == nil {
+		return Delivery{}, fmt.Errorf("%w: %s", ErrNoSink, sink)
+	}
+	e, ok := i.Registry.Get(regNumber)
+	if !ok {
+		return Delivery{}, fmt.Errorf("%w: %s", registry.ErrNotFound, regNumber)
+	}
+	if e.Revoked() {
+		return Delivery{}, fmt.Errorf("certificate %s is revoked", regNumber)
+	}
+
+	rec := recordOf(e)
+	if a, ok := s.(Addressed); ok {
+		if to == "" {
+			to = e.Deliveries[sink].To
+		}
+		rec = a.WithRecipient(rec, to)
+	}
+	res := Result{
+		Name:      e.Name,
+		RegNumber: e.RegNumber,
+		Path:      e.Path,
+		SHA256:    e.SHA256,
+		VerifyURL: i.Gen.Config().VerificationURL(e.RegNumber),
+		QRPayload: i.Gen.QRPayload(rec),
+		LinkedIn:  i.Gen.LinkedInURL(rec),
+		ExpiresAt: e.ExpiresAt,
+	}
+	return i.deliverTo(ctx, s, rec, res)
+}
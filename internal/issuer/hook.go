@@ -0,0 +1,70 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Hook runs around the issuance of every certificate, for rules and
+// bookkeeping kept outside certgen: Before can refuse a record, such as a
+// recipient who is not eligible, and After hears of every certificate
+// issued, to stamp it into another system.
+type Hook interface {
+	// Name identifies the hook in errors and traces.
+	Name() string
+	// Before runs before rec's certificate is generated. An error refuses
+	// the record and nothing is written; wrap the reason with ErrRejected
+	// for a refusal, as opposed to a hook that could not decide.
+	Before(ctx context.Context, rec certificate.Record) error
+	// After runs once the certificate is registered and the sinks have
+	// run, whether or not they succeeded, under the retry policy. Errors
+	// that retrying cannot fix should be wrapped with retry.Permanent.
+	After(ctx context.Context, rec certificate.Record, res Result) error
+}
+
+// ErrRejected marks a record a Before hook refused.
+var ErrRejected = errors.New("rejected")
+
+// before runs every Before hook in order and stops at the first error.
+func (i *Issuer) before(ctx context.Context, rec certificate.Record) error {
+	for _, h := range i.Hooks {
+		_, span := tracer.Start(ctx, "issuer.hook.before",
+			trace.WithAttributes(attribute.String("certgen.hook", h.Name())))
+		err := h.Before(ctx, rec)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		if err != nil {
+			return fmt.Errorf("%s: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
+
+// after runs every After hook under the retry policy. The first failure
+// fails the result, but every hook is still run: the certificate is issued
+// either way.
+func (i *Issuer) after(ctx context.Context, rec certificate.Record, res *Result) {
+	for _, h := range i.Hooks {
+		ctx, span := tracer.Start(ctx, "issuer.hook.after",
+			trace.WithAttributes(attribute.String("certgen.hook", h.Name())))
+		attempts, err := i.Retry.Do(ctx, func(ctx context.Context) error {
+			return h.After(ctx, rec, *res)
+		})
+		span.SetAttributes(attribute.Int("certgen.attempts", attempts))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			if res.OK() {
+				res.fail(StageAfter, fmt.Errorf("%s: %w", h.Name(), err))
+			}
+		}
+		span.End()
+	}
+}
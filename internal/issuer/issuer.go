@@ -0,0 +1,310 @@
+// Package issuer turns records into issued certificates: it generates the
+// PDF, hashes it and records it in the registry. Every entry point (single
+// generation, batches, the HTTP API) issues through it.
+package issuer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Sathimantha/certificate_generator_go/internal/issuer")
+
+// Result is the machine-readable outcome of issuing one certificate.
+type Result struct {
+	Name       string     `json:"name"`
+	RegNumber  string     `json:"reg_number"`
+	Path       string     `json:"path,omitempty"`
+	Sidecar    string     `json:"sidecar,omitempty"` // JSON mirror of the PDF, with SIDECAR_JSON
+	SHA256     string     `json:"sha256,omitempty"`
+	DurationMS float64    `json:"duration_ms"`
+	VerifyURL  string     `json:"verify_url"`
+	QRPayload  string     `json:"qr_payload,omitempty"` // content of the QR code
+	LinkedIn   string     `json:"linkedin_url,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Warnings   []string   `json:"warnings,omitempty"` // issued, but with something to look into
+	Skipped    bool       `json:"skipped,omitempty"`  // OUTPUT_EXISTS=skip kept an earlier issue, which was not delivered again
+	Error      string     `json:"error,omitempty"`
+	Stage      string     `json:"stage,omitempty"` // pipeline stage that failed
+	Code       string     `json:"code,omitempty"`  // failure category, see Code
+
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+
+	// ConfigVersion and ConfigHash identify the configuration the PDF was
+	// laid out with, as the registry records them.
+	ConfigVersion string `json:"config_version,omitempty"`
+	ConfigHash    string `json:"config_hash,omitempty"`
+
+	// Err is the error behind Error, for errors.Is against the
+	// certificate package's sentinels. It is not serialized.
+	Err error `json:"-"`
+}
+
+// Pipeline stages reported in Result.Stage when issuing fails.
+const (
+	StageInput    = "input"    // the record itself was malformed
+	StageBefore   = "before"   // a Before hook refused the record or failed
+	StageGenerate = "generate" // rendering, writing or hashing the PDF
+	StageRegister = "register"
+	StageDeliver  = "deliver" // a sink failed after its retries
+	StageAfter    = "after"   // an After hook failed; the certificate is issued
+)
+
+// OK reports whether the certificate was issued.
+func (r Result) OK() bool {
+	return r.Error == ""
+}
+
+func (r *Result) fail(stage string, err error) {
+	r.Err, r.Error, r.Stage, r.Code = err, err.Error(), stage, Code(err)
+}
+
+// Failure categories reported in Result.Code, for failures a report reader
+// or API client can act on.
+const (
+	CodeInvalidConfig    = "invalid_config"
+	CodeTemplateNotFound = "template_not_found"
+	CodeFontLoad         = "font_load"
+	CodeOutputExists     = "output_exists"
+	CodeMissingGlyph     = "missing_glyph"
+	CodeRejected         = "rejected"
+	CodeUnknownCourse    = "unknown_course"
+	CodeConfigChanged    = "config_changed"
+	CodeRevoked          = "revoked"
+)
+
+// Code returns the failure category of err, or "" when it has none.
+func Code(err error) string {
+	switch {
+	case errors.Is(err, ErrRejected):
+		return CodeRejected
+	case errors.Is(err, certificate.ErrOutputExists):
+		return CodeOutputExists
+	case errors.Is(err, certificate.ErrMissingGlyph):
+		return CodeMissingGlyph
+	case errors.Is(err, certificate.ErrUnknownCourse):
+		return CodeUnknownCourse
+	case errors.Is(err, certificate.ErrConfigChanged):
+		return CodeConfigChanged
+	case errors.Is(err, registry.ErrRevoked):
+		return CodeRevoked
+	case errors.Is(err, certificate.ErrTemplateNotFound):
+		return CodeTemplateNotFound
+	case errors.Is(err, certificate.ErrFontLoad):
+		return CodeFontLoad
+	case errors.Is(err, certificate.ErrInvalidConfig):
+		return CodeInvalidConfig
+	}
+	return ""
+}
+
+// Issuer generates certificates into OutputDir and, when Registry is set,
+// records each one there. Each certificate is then handed to every sink in
+// Sinks, retried according to Retry. Hooks run before generation and after
+// delivery. OnResult, if set, is called with every Result, for metrics.
+type Issuer struct {
+	Gen       *certificate.Generator
+	Registry  *registry.Registry
+	OutputDir string
+	Sinks     []Sink
+	Hooks     []Hook
+	Retry     retry.Policy
+	OnResult  func(Result)
+}
+
+// Issue generates, hashes, registers and delivers the certificate for rec. Failures
+// are reported in Result.Error so batches can carry on.
+func (i *Issuer) Issue(rec certificate.Record) Result {
+	return i.IssueContext(context.Background(), rec)
+}
+
+// IssueContext is Issue traced as a child span of the span in ctx, so the
+// issuance of one certificate can be followed from the request that asked
+// for it.
+func (i *Issuer) IssueContext(ctx context.Context, rec certificate.Record) Result {
+	ctx, span := tracer.Start(ctx, "issuer.Issue",
+		trace.WithAttributes(attribute.String("certgen.reg_number", rec.RegNumber)))
+	res := i.issue(ctx, rec)
+	if !res.OK() {
+		span.SetStatus(codes.Error, res.Error)
+		span.SetAttributes(attribute.String("certgen.stage", res.Stage))
+	}
+	span.End()
+
+	i.Report(res)
+	return res
+}
+
+// Report passes a result produced outside Issue, such as an unreadable batch
+// row, to OnResult.
+func (i *Issuer) Report(res Result) {
+	if i.OnResult != nil {
+		i.OnResult(res)
+	}
+}
+
+func (i *Issuer) issue(ctx context.Context, rec certificate.Record) Result {
+	// Fixed up front so the PDF, its expiry and the registry agree.
+	if rec.IssuedAt.IsZero() {
+		rec.IssuedAt = time.Now().In(i.Gen.Config().Location())
+	}
+	res := Result{
+		Name:      rec.Name,
+		RegNumber: rec.RegNumber,
+		VerifyURL: i.Gen.Config().VerificationURL(rec.RegNumber),
+	}
+	// The course's title and expiry are the record's from here on, for
+	// the registry, hooks and sinks as for the PDF
+	rec, err := i.Gen.Resolve(rec)
+	if err != nil {
+		res.fail(StageInput, err)
+		return res
+	}
+
+	if err := i.checkReissue(rec, &res); err != nil {
+		res.fail(StageInput, err)
+		return res
+	}
+
+	if err := i.before(ctx, rec); err != nil {
+		res.fail(StageBefore, err)
+		return res
+	}
+
+	start := time.Now()
+	gen, err := i.Gen.GenerateContext(ctx, rec, i.OutputDir)
+	res.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		res.fail(StageGenerate, err)
+		return res
+	}
+	res.Name, res.Path, res.Sidecar, res.SHA256 = gen.Name, gen.Path, gen.SidecarPath, gen.SHA256
+	res.QRPayload, res.LinkedIn = gen.QRPayload, gen.LinkedIn
+	res.ConfigVersion, res.ConfigHash = gen.ConfigVersion, gen.ConfigHash
+	res.Skipped = gen.Skipped
+	if !gen.ExpiresAt.IsZero() {
+		res.ExpiresAt = &gen.ExpiresAt
+	}
+
+	_, span := tracer.Start(ctx, "issuer.register")
+	err = i.register(rec, res)
+	span.End()
+	if err != nil {
+		res.fail(StageRegister, err)
+		return res
+	}
+	// A kept file was delivered and followed up when it was issued
+	if res.Skipped {
+		return res
+	}
+
+	i.deliver(ctx, rec, &res)
+	i.after(ctx, rec, &res)
+	return res
+}
+
+func (i *Issuer) register(rec certificate.Record, res Result) error {
+	if i.Registry == nil {
+		return nil
+	}
+	// A file kept by OUTPUT_EXISTS=skip is already registered as it is.
+	if prev, ok := i.Registry.Get(rec.RegNumber); ok && prev.SHA256 == res.SHA256 {
+		return nil
+	}
+
+	return i.Registry.Put(registry.Entry{
+		RegNumber: rec.RegNumber,
+		Name:      res.Name,
+		Course:    rec.Course,
+		Path:      res.Path,
+		SHA256:    res.SHA256,
+		IssuedAt:  rec.IssuedAt,
+		ExpiresAt: res.ExpiresAt,
+
+		ConfigVersion: res.ConfigVersion,
+		ConfigHash:    res.ConfigHash,
+	})
+}
+
+// checkReissue refuses a record whose certificate was revoked, and applies
+// CONFIG_CHANGE to one issued before with another configuration than its
+// certificate is about to be laid out with. A file OUTPUT_EXISTS=skip
+// keeps is not issued again.
+func (i *Issuer) checkReissue(rec certificate.Record, res *Result) error {
+	if i.Registry == nil {
+		return nil
+	}
+	prev, ok := i.Registry.Get(rec.RegNumber)
+	if !ok {
+		return nil
+	}
+	if prev.Revoked() {
+		return fmt.Errorf("%w: %s was revoked on %s", registry.ErrRevoked, rec.RegNumber, prev.RevokedAt.Format(time.DateOnly))
+	}
+	cfg := i.Gen.Config()
+	if strings.EqualFold(cfg.OutputExists, certificate.ExistsSkip) {
+		return nil
+	}
+	hash, err := i.Gen.ConfigHash(rec)
+	if err != nil {
+		return nil // reported by generation
+	}
+	warning, err := i.configChanged(prev, cfg.Version, hash)
+	if warning != "" {
+		res.Warnings = append(res.Warnings, warning)
+	}
+	return err
+}
+
+// configChanged compares the configuration version and hash a certificate
+// is about to be laid out with to those its registry entry e was laid out
+// with. When they differ, CONFIG_CHANGE decides between a warning and an
+// error wrapping certificate.ErrConfigChanged.
+func (i *Issuer) configChanged(e registry.Entry, version, hash string) (warning string, err error) {
+	if e.ConfigHash == "" || e.ConfigHash == hash {
+		return "", nil
+	}
+	msg := fmt.Sprintf("certificate %s was issued with config %s, now %s",
+		e.RegNumber, describeConfig(e.ConfigVersion, e.ConfigHash), describeConfig(version, hash))
+	switch strings.ToLower(i.Gen.Config().ConfigChange) {
+	case certificate.ConfigChangeAllow:
+		return "", nil
+	case certificate.ConfigChangeRefuse:
+		return "", fmt.Errorf("%w: %s; set CONFIG_CHANGE=allow to reissue it", certificate.ErrConfigChanged, msg)
+	}
+	return msg + ", so it may look different", nil
+}
+
+// describeConfig names a configuration by its version, when it has one,
+// and the start of its hash.
+func describeConfig(version, hash string) string {
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	if version == "" {
+		return hash
+	}
+	return fmt.Sprintf("version %q (%s)", version, hash)
+}
+
+// CheckOutputDir reports whether certificates can be written to OutputDir.
+func (i *Issuer) CheckOutputDir() error {
+	f, err := os.CreateTemp(i.OutputDir, ".certgen-check-*")
+	if err != nil {
+		return fmt.Errorf("output directory not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
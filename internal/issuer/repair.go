@@ -0,0 +1,93 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+// Damage Inspect finds in an issued certificate's PDF.
+const (
+	DamageMissing  = "missing"  // no file at the registered path
+	DamageMismatch = "mismatch" // the file's SHA-256 is not the registered one
+)
+
+// Inspect checks the PDF of the registry entry e against the digest it was
+// registered with, and returns the damage found, or "" when it is intact.
+// An error means the file could not be read at all.
+func Inspect(e registry.Entry) (string, error) {
+	sum, err := certificate.FileSHA256(e.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return DamageMissing, nil
+	case err != nil:
+		return "", err
+	case sum != e.SHA256:
+		return DamageMismatch, nil
+	}
+	return "", nil
+}
+
+// Repair is the outcome of regenerating one issued certificate.
+type Repair struct {
+	RegNumber string `json:"reg_number"`
+	Path      string `json:"path"`
+	Damage    string `json:"damage"`
+	SHA256    string `json:"sha256,omitempty"` // of the regenerated PDF
+	// Restored reports that the regenerated PDF is the one issued, byte
+	// for byte. Otherwise the record or configuration has changed since,
+	// and the registry now has the new PDF's digest.
+	Restored bool   `json:"restored"`
+	Warning  string `json:"warning,omitempty"` // the configuration has changed, see CONFIG_CHANGE
+	Error    string `json:"error,omitempty"`
+}
+
+// Repair regenerates the PDF of the registry entry e, which Inspect found
+// damaged, at its registered path. The registry does not keep a record's
+// extra fields, so fields gives them, as the record was issued with; nil
+// regenerates it without any.
+func (i *Issuer) Repair(ctx context.Context, e registry.Entry, damage string, fields map[string]string) Repair {
+	rep := Repair{RegNumber: e.RegNumber, Path: e.Path, Damage: damage}
+	rec := recordOf(e)
+	if fields != nil {
+		rec.Fields = fields
+	}
+	if hash, err := i.Gen.ConfigHash(rec); err == nil {
+		if rep.Warning, err = i.configChanged(e, i.Gen.Config().Version, hash); err != nil {
+			rep.Error = err.Error()
+			return rep
+		}
+	}
+	gen, err := i.Gen.Regenerate(ctx, rec, e.Path)
+	if err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+	rep.SHA256, rep.Restored = gen.SHA256, gen.SHA256 == e.SHA256
+	if !rep.Restored {
+		e.SHA256, e.ConfigVersion, e.ConfigHash = gen.SHA256, gen.ConfigVersion, gen.ConfigHash
+		if err := i.Registry.Put(e); err != nil {
+			rep.Error = "recording the new digest: " + err.Error()
+		}
+	}
+	return rep
+}
+
+// recordOf rebuilds the record the registry entry e was issued from, as
+// far as the registry knows it.
+func recordOf(e registry.Entry) certificate.Record {
+	rec := certificate.Record{
+		Name:      e.Name,
+		RegNumber: e.RegNumber,
+		Course:    e.Course,
+		IssuedAt:  e.IssuedAt,
+		Fields:    map[string]string{},
+	}
+	if e.ExpiresAt != nil {
+		rec.ExpiresAt = *e.ExpiresAt
+	}
+	return rec
+}
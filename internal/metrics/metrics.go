@@ -0,0 +1,109 @@
+// Package metrics exposes issuance metrics in the Prometheus text format.
+//
+//	certgen_certificates_generated_total          certificates issued
+//	certgen_certificate_failures_total{reason}    failures by pipeline stage
+//	certgen_generation_duration_seconds           time to issue one certificate
+//	certgen_queue_depth                           certificates waiting or in progress
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// Metrics holds the issuance collectors on a private registry, so several
+// instances never collide.
+type Metrics struct {
+	reg *prometheus.Registry
+
+	generated prometheus.Counter
+	failures  *prometheus.CounterVec
+	duration  prometheus.Histogram
+	queue     prometheus.Gauge
+}
+
+// New returns Metrics with all collectors registered, along with the Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		reg: prometheus.NewRegistry(),
+		generated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "certgen_certificates_generated_total",
+			Help: "Certificates generated and registered.",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "certgen_certificate_failures_total",
+			Help: "Certificates that failed, by the pipeline stage that failed.",
+		}, []string{"reason"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "certgen_generation_duration_seconds",
+			Help:    "Time to generate one certificate.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12), // 5ms .. ~10s
+		}),
+		queue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "certgen_queue_depth",
+			Help: "Certificates waiting to be generated or in progress.",
+		}),
+	}
+	m.reg.MustRegister(
+		m.generated, m.failures, m.duration, m.queue,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	// Export the failure series at zero so alerts can use rate() from the
+	// first scrape.
+	for _, reason := range []string{issuer.StageInput, issuer.StageBefore, issuer.StageGenerate, issuer.StageRegister, issuer.StageDeliver, issuer.StageAfter} {
+		m.failures.WithLabelValues(reason)
+	}
+	return m
+}
+
+// Observe records the outcome of one certificate. It has the signature of
+// issuer.Issuer.OnResult.
+func (m *Metrics) Observe(res issuer.Result) {
+	if res.OK() {
+		m.generated.Inc()
+	} else {
+		reason := res.Stage
+		if reason == "" {
+			reason = "unknown"
+		}
+		m.failures.WithLabelValues(reason).Inc()
+	}
+	if res.DurationMS > 0 {
+		m.duration.Observe(res.DurationMS / 1000)
+	}
+}
+
+// Enqueue counts n more certificates as queued. The returned function takes
+// one off the queue; call it once per certificate when it is done.
+func (m *Metrics) Enqueue(n int) (done func()) {
+	m.queue.Add(float64(n))
+	return func() { m.queue.Dec() }
+}
+
+// SetQueueDepth reports the depth of an external queue, such as a message
+// stream's pending jobs plus those in progress.
+func (m *Metrics) SetQueueDepth(n int) {
+	m.queue.Set(float64(n))
+}
+
+// Handler serves the metrics for scraping.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{Registry: m.reg})
+}
+
+// Serve serves /metrics on addr until the process exits, for commands that
+// do not otherwise listen, such as batch.
+func (m *Metrics) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
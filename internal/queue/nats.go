@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSConfig selects a NATS JetStream stream to consume jobs from.
+type NATSConfig struct {
+	URL      string // e.g. nats://localhost:4222
+	Stream   string // existing stream holding the jobs
+	Subject  string // subject filter within the stream
+	Consumer string // durable consumer name, shared by all workers
+	// DeadLetterSubject receives jobs that will not be retried, with the
+	// reason in a Certgen-Error header. It must be captured by a stream.
+	DeadLetterSubject string
+	// AckWait is how long a job may run before the server redelivers it.
+	AckWait time.Duration
+}
+
+// NATS is a Source backed by a durable JetStream pull consumer.
+type NATS struct {
+	nc   *nats.Conn
+	js   jetstream.JetStream
+	iter jetstream.MessagesContext
+	dlq  string
+}
+
+// OpenNATS connects and creates or updates the durable consumer.
+// Redelivery is driven by the worker, so the consumer itself has no
+// delivery limit.
+func OpenNATS(ctx context.Context, cfg NATSConfig) (*NATS, error) {
+	nc, err := nats.Connect(cfg.URL, nats.Name("certgen worker"))
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       cfg.Consumer,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    -1,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats consumer %s on stream %s: %w", cfg.Consumer, cfg.Stream, err)
+	}
+	// Fetch one at a time so jobs are spread across workers.
+	iter, err := cons.Messages(jetstream.PullMaxMessages(1))
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	return &NATS{nc: nc, js: js, iter: iter, dlq: cfg.DeadLetterSubject}, nil
+}
+
+// Next implements Source.
+func (n *NATS) Next(ctx context.Context) (Message, error) {
+	m, err := n.iter.Next(jetstream.NextContext(ctx))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	msg := &natsMessage{Msg: m, n: n, attempt: 1, pending: -1}
+	if md, err := m.Metadata(); err == nil {
+		msg.attempt, msg.pending = int(md.NumDelivered), int(md.NumPending)
+	}
+	return msg, nil
+}
+
+// Close stops consuming and closes the connection after flushing acks.
+func (n *NATS) Close() error {
+	n.iter.Stop()
+	return n.nc.Drain()
+}
+
+type natsMessage struct {
+	jetstream.Msg
+	n                *NATS
+	attempt, pending int
+}
+
+func (m *natsMessage) Attempt() int { return m.attempt }
+func (m *natsMessage) Pending() int { return m.pending }
+
+func (m *natsMessage) Ack() error {
+	return m.Msg.DoubleAck(context.Background())
+}
+
+func (m *natsMessage) Retry(delay time.Duration) error {
+	return m.NakWithDelay(delay)
+}
+
+// DeadLetter publishes the job to the dead-letter subject, if configured,
+// and terminates it.
+func (m *natsMessage) DeadLetter(ctx context.Context, reason string) error {
+	if m.n.dlq != "" {
+		out := &nats.Msg{Subject: m.n.dlq, Data: m.Data(), Header: nats.Header{}}
+		out.Header.Set("Certgen-Error", reason)
+		out.Header.Set("Certgen-Attempts", strconv.Itoa(m.attempt))
+		out.Header.Set("Certgen-Subject", m.Subject())
+		if _, err := m.n.js.PublishMsg(ctx, out); err != nil {
+			return fmt.Errorf("publishing to %s: %w", m.n.dlq, err)
+		}
+	}
+	return m.TermWithReason(reason)
+}
@@ -0,0 +1,178 @@
+// Package queue issues certificates for jobs consumed from a message queue,
+// so an event-driven pipeline can drive issuance.
+//
+// A job is the JSON body the HTTP API accepts on POST /certificates. Jobs
+// that can never succeed (malformed JSON, missing fields, unknown tenant)
+// are dead-lettered at once; jobs that fail for other reasons are
+// redelivered with backoff and dead-lettered after MaxAttempts.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/metrics"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// Job is one generation request.
+type Job struct {
+	Name      string            `json:"name"`
+	RegNumber string            `json:"reg_number"`
+	Course    string            `json:"course,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+}
+
+// Message is a delivered job and the means to settle it.
+type Message interface {
+	Data() []byte
+	// Attempt is 1 on first delivery and counts redeliveries after that.
+	Attempt() int
+	// Pending is the number of jobs still queued behind this one, or -1.
+	Pending() int
+	Ack() error
+	// Retry asks for redelivery after delay.
+	Retry(delay time.Duration) error
+	// DeadLetter moves the job aside with reason so it is not redelivered.
+	DeadLetter(ctx context.Context, reason string) error
+}
+
+// Source yields messages. Next blocks until a message arrives or ctx is
+// done.
+type Source interface {
+	Next(ctx context.Context) (Message, error)
+	Close() error
+}
+
+// Worker consumes jobs from Source and issues them.
+type Worker struct {
+	Source Source
+	// Issuer returns the issuer for a job's tenant, "" for the default.
+	Issuer      func(tenant string) (*issuer.Issuer, error)
+	MaxAttempts int
+	Backoff     retry.Policy // redelivery delays; Attempts is ignored
+	Concurrency int
+	Logger      *slog.Logger
+	Metrics     *metrics.Metrics // optional
+}
+
+// Run consumes jobs until ctx is done, then waits for the jobs in progress
+// to finish and be settled before returning.
+func (w *Worker) Run(ctx context.Context) error {
+	slots := make(chan struct{}, max(w.Concurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		// Take a slot before fetching, so nothing is pulled off the queue
+		// that cannot be worked on straight away.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		msg, err := w.Source.Next(ctx)
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if w.Metrics != nil && msg.Pending() >= 0 {
+			w.Metrics.SetQueueDepth(msg.Pending() + len(slots)) // queued + in progress
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// The job runs to completion even during shutdown so a
+			// certificate is never left half-written.
+			w.handle(context.WithoutCancel(ctx), msg)
+		}()
+	}
+}
+
+// errPermanent marks jobs that redelivery cannot fix.
+var errPermanent = errors.New("permanent failure")
+
+func (w *Worker) handle(ctx context.Context, msg Message) {
+	log := w.Logger.With("attempt", msg.Attempt())
+
+	res, err := w.issue(ctx, msg)
+	if err == nil {
+		log.Info("job done", "reg_number", res.RegNumber, "path", res.Path)
+		if err := msg.Ack(); err != nil {
+			log.Error("ack failed", "reg_number", res.RegNumber, "err", err)
+		}
+		return
+	}
+
+	log = log.With("reg_number", res.RegNumber, "err", err)
+	if errors.Is(err, errPermanent) || msg.Attempt() >= max(w.MaxAttempts, 1) {
+		log.Error("job dead-lettered")
+		if err := msg.DeadLetter(ctx, err.Error()); err != nil {
+			log.Error("dead-lettering failed", "dlq_err", err)
+		}
+		return
+	}
+	delay := w.Backoff.Delay(msg.Attempt())
+	log.Warn("job failed, will retry", "delay", delay)
+	if err := msg.Retry(delay); err != nil {
+		log.Error("nak failed", "nak_err", err)
+	}
+}
+
+func (w *Worker) issue(ctx context.Context, msg Message) (issuer.Result, error) {
+	var job Job
+	if err := json.Unmarshal(msg.Data(), &job); err != nil {
+		return issuer.Result{}, fmt.Errorf("%w: invalid job: %v", errPermanent, err)
+	}
+	res := issuer.Result{Name: job.Name, RegNumber: job.RegNumber}
+	if job.Name == "" || job.RegNumber == "" {
+		return res, fmt.Errorf("%w: name and reg_number are required", errPermanent)
+	}
+	iss, err := w.Issuer(job.Tenant)
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", errPermanent, err)
+	}
+
+	res = iss.IssueContext(ctx, certificate.Record{
+		Name:      job.Name,
+		RegNumber: job.RegNumber,
+		Course:    job.Course,
+		IssuedAt:  job.IssuedAt,
+		ExpiresAt: job.ExpiresAt,
+		Fields:    job.Fields,
+	})
+	if res.OK() {
+		return res, nil
+	}
+	if res.Stage == issuer.StageInput || permanentCodes[issuer.Code(res.Err)] {
+		return res, fmt.Errorf("%w: %s", errPermanent, res.Error)
+	}
+	return res, res.Err
+}
+
+// permanentCodes are the failure categories of records that would fail the
+// same way on every redelivery: a hook vetoed them or they conflict with
+// what was issued before.
+var permanentCodes = map[string]bool{
+	issuer.CodeOutputExists:  true,
+	issuer.CodeMissingGlyph:  true,
+	issuer.CodeRejected:      true,
+	issuer.CodeUnknownCourse: true,
+	issuer.CodeConfigChanged: true,
+	issuer.CodeRevoked:       true,
+}
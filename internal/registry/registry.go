@@ -0,0 +1,251 @@
+// Package registry records issued certificates so they can later be
+// verified or revoked.
+//
+// The registry is an append-only JSON Lines file: every issuance or
+// revocation appends one entry, and the latest entry for a registration
+// number wins when the file is replayed on Open.
+package registry
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for registration numbers that were never issued.
+var ErrNotFound = errors.New("registration number not found")
+
+// ErrRevoked is returned for registration numbers whose certificate was
+// revoked, which may not be issued again.
+var ErrRevoked = errors.New("certificate is revoked")
+
+// Entry is the registry record of one issued certificate.
+type Entry struct {
+	RegNumber    string     `json:"reg_number"`
+	Name         string     `json:"name"`
+	Course       string     `json:"course,omitempty"`
+	Path         string     `json:"path"`
+	SHA256       string     `json:"sha256"`
+	IssuedAt     time.Time  `json:"issued_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+
+	// ConfigVersion and ConfigHash identify the configuration the PDF was
+	// laid out with; entries from before they were recorded have neither.
+	ConfigVersion string `json:"config_version,omitempty"`
+	ConfigHash    string `json:"config_hash,omitempty"`
+
+	// Deliveries is the latest status of every sink the certificate was
+	// sent through, by sink name.
+	Deliveries map[string]DeliveryStatus `json:"deliveries,omitempty"`
+}
+
+// Delivery statuses. A sent certificate can later be reported delivered,
+// bounced or complained about by the provider.
+const (
+	DeliverySent       = "sent"
+	DeliveryFailed     = "failed" // the sink gave up, or the provider dropped it
+	DeliveryDelivered  = "delivered"
+	DeliveryBounced    = "bounced"
+	DeliveryComplained = "complained" // marked as spam by the recipient
+)
+
+// DeliveryStatus is how far a certificate got through one sink.
+type DeliveryStatus struct {
+	Status   string    `json:"status"`
+	To       string    `json:"to,omitempty"`       // recipient, for sinks that have one
+	Location string    `json:"location,omitempty"` // message ID, URL
+	Error    string    `json:"error,omitempty"`    // why it failed or bounced
+	At       time.Time `json:"at"`
+}
+
+// Revoked reports whether the certificate has been revoked.
+func (e Entry) Revoked() bool {
+	return e.RevokedAt != nil
+}
+
+// Expired reports whether the certificate had expired at t.
+func (e Entry) Expired(t time.Time) bool {
+	return e.ExpiresAt != nil && !t.Before(*e.ExpiresAt)
+}
+
+// Registry is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]Entry
+}
+
+// Open loads the registry at path, creating the file if needed.
+func Open(path string) (*Registry, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open registry: %w", err)
+	}
+
+	r := &Registry{f: f, entries: map[string]Entry{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("registry %s line %d: %w", path, line, err)
+		}
+		r.entries[e.RegNumber] = e
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot read registry: %w", err)
+	}
+	return r, nil
+}
+
+// Check reports whether the registry file is still open and is still the
+// file at its path, so a deleted or replaced registry is noticed.
+func (r *Registry) Check() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	open, err := r.f.Stat()
+	if err != nil {
+		return fmt.Errorf("registry: %w", err)
+	}
+	cur, err := os.Stat(r.f.Name())
+	if err != nil {
+		return fmt.Errorf("registry: %w", err)
+	}
+	if !os.SameFile(open, cur) {
+		return fmt.Errorf("registry %s was replaced since it was opened", r.f.Name())
+	}
+	return nil
+}
+
+// Close flushes the underlying file to disk and closes it.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.f.Sync()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Get returns the current entry for regNumber.
+func (r *Registry) Get(regNumber string) (Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[regNumber]
+	return e, ok
+}
+
+// All returns every current entry, ordered by registration number.
+func (r *Registry) All() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RegNumber < out[j].RegNumber })
+	return out
+}
+
+// Put records e, replacing any previous entry for the same number. The
+// previous entry's revocation and delivery statuses are kept, so issuing a
+// number again cannot undo them.
+func (r *Registry) Put(e Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.entries[e.RegNumber]; ok {
+		if prev.Revoked() {
+			e.RevokedAt, e.RevokeReason = prev.RevokedAt, prev.RevokeReason
+		}
+		if e.Deliveries == nil {
+			e.Deliveries = prev.Deliveries
+		}
+	}
+	return r.append(e)
+}
+
+// Revoke marks regNumber as revoked at the given time. Revoking twice keeps
+// the first revocation.
+func (r *Registry) Revoke(regNumber, reason string, at time.Time) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[regNumber]
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, regNumber)
+	}
+	if e.Revoked() {
+		return e, nil // keep the original revocation
+	}
+	e.RevokedAt = &at
+	e.RevokeReason = reason
+	return e, r.append(e)
+}
+
+// SetDelivery records s as the status of regNumber's certificate in sink.
+func (r *Registry) SetDelivery(regNumber, sink string, s DeliveryStatus) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[regNumber]
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, regNumber)
+	}
+	e.Deliveries = maps.Clone(e.Deliveries)
+	if e.Deliveries == nil {
+		e.Deliveries = map[string]DeliveryStatus{}
+	}
+	e.Deliveries[sink] = s
+	return e, r.append(e)
+}
+
+// FindDelivery returns the entry delivered through sink as location, such
+// as an email's Message-ID, or failing that the latest one delivered to
+// the recipient to. Either may be empty.
+func (r *Registry) FindDelivery(sink, location, to string) (Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found Entry
+	ok := false
+	for _, e := range r.entries {
+		d, sent := e.Deliveries[sink]
+		switch {
+		case !sent:
+		case location != "" && d.Location == location:
+			return e, true
+		case to != "" && strings.EqualFold(d.To, to) && (!ok || d.At.After(found.Deliveries[sink].At)):
+			found, ok = e, true
+		}
+	}
+	return found, ok
+}
+
+// append writes e to the log and applies it. r.mu must be held.
+func (r *Registry) append(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := r.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("cannot write registry: %w", err)
+	}
+	r.entries[e.RegNumber] = e
+	return nil
+}
@@ -0,0 +1,48 @@
+package registry
+
+import "time"
+
+// Verification statuses.
+const (
+	StatusValid    = "valid"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"  // past its expiry date
+	StatusUnknown  = "unknown"  // never issued
+	StatusMismatch = "mismatch" // issued, but the presented file differs
+)
+
+// Verification is the outcome of checking a registration number, and
+// optionally a presented file, against the registry.
+type Verification struct {
+	RegNumber string `json:"reg_number"`
+	Status    string `json:"status"`
+	Entry     *Entry `json:"entry,omitempty"`
+}
+
+// Valid reports whether the certificate checks out.
+func (v Verification) Valid() bool {
+	return v.Status == StatusValid
+}
+
+// Verify checks regNumber. When sha256 is non-empty it must also match the
+// hash recorded at issuance.
+func (r *Registry) Verify(regNumber, sha256 string) Verification {
+	v := Verification{RegNumber: regNumber, Status: StatusUnknown}
+	e, ok := r.Get(regNumber)
+	if !ok {
+		return v
+	}
+	v.Entry = &e
+
+	switch {
+	case e.Revoked():
+		v.Status = StatusRevoked
+	case e.Expired(time.Now()):
+		v.Status = StatusExpired
+	case sha256 != "" && sha256 != e.SHA256:
+		v.Status = StatusMismatch
+	default:
+		v.Status = StatusValid
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+// Package retry runs operations against flaky external services with
+// exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy says how often and how patiently to retry. The zero Policy tries
+// once.
+type Policy struct {
+	Attempts int           // total tries, including the first
+	Initial  time.Duration // wait before the second try
+	Max      time.Duration // cap on a single wait; 0 means no cap
+	Jitter   float64       // each wait varies by up to ±Jitter of itself, 0..1
+}
+
+// Default is used where no policy is configured.
+var Default = Policy{Attempts: 3, Initial: time.Second, Max: 30 * time.Second, Jitter: 0.2}
+
+// permanent marks an error that retrying cannot fix.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent wraps err so Do gives up at once, e.g. for a rejected address
+// or an HTTP 4xx response.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanent
+	return errors.As(err, &p)
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the attempts
+// are used up or ctx is done. It returns the number of calls made and the
+// last error.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) (attempts int, err error) {
+	for {
+		attempts++
+		err = fn(ctx)
+		if err == nil || IsPermanent(err) || attempts >= max(p.Attempts, 1) {
+			return attempts, err
+		}
+
+		t := time.NewTimer(p.Delay(attempts))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempts, errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// Delay returns how long to wait after the given failed attempt: Initial
+// doubled for every attempt after the first, capped at Max, with jitter.
+func (p Policy) Delay(attempt int) time.Duration {
+	d := p.Initial
+	for i := 1; i < attempt && (p.Max == 0 || d < p.Max); i++ {
+		d *= 2
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	if p.Jitter <= 0 {
+		return d
+	}
+	f := 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(d) * f)
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	_ "embed"
+	"html/template"
+	"maps"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/auth"
+	"github.com/Sathimantha/certificate_generator_go/internal/batch"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+// The admin dashboard is a few server-rendered pages for operations staff:
+// search the registry, preview or download a certificate, send it again,
+// revoke it, and look over recent batch runs. It takes an admin API key
+// as the password of the browser's login prompt.
+//
+//	GET  /admin/                            search, with ?q= and ?status=
+//	GET  /admin/certificates/{reg}/pdf      preview; ?download=1 to save it
+//	POST /admin/certificates/{reg}/resend   sink and optional to
+//	POST /admin/certificates/{reg}/revoke   optional reason
+//
+// Every page takes ?tenant= like the API.
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"date": func(t any) string {
+		switch t := t.(type) {
+		case time.Time:
+			if !t.IsZero() {
+				return t.Format("2006-01-02 15:04")
+			}
+		case *time.Time:
+			if t != nil && !t.IsZero() {
+				return t.Format("2006-01-02 15:04")
+			}
+		}
+		return ""
+	},
+	"path": url.PathEscape,
+}).Parse(dashboardHTML))
+
+// Dashboard limits.
+const (
+	dashboardResults = 100 // registry entries listed for a search
+	dashboardRuns    = 20  // batch runs listed
+)
+
+// Search filters for ?status=.
+const (
+	filterValid   = "valid"
+	filterRevoked = "revoked"
+	filterExpired = "expired"
+	filterUnsent  = "undelivered" // a delivery failed or bounced
+)
+
+// dashboardRoutes adds the dashboard to mux.
+func (s *Server) dashboardRoutes(mux *http.ServeMux) {
+	// Browsers send the login with every request, so forms are only
+	// accepted from the dashboard's own pages
+	csrf := http.NewCrossOriginProtection()
+	page := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, s.keys.RequireLogin(auth.ScopeAdmin, csrf.Handler(h)))
+	}
+	page("GET /admin/{$}", s.handleDashboard)
+	page("GET /admin/certificates/{reg}/pdf", s.handleDashboardPDF)
+	page("POST /admin/certificates/{reg}/resend", s.handleDashboardResend)
+	page("POST /admin/certificates/{reg}/revoke", s.handleDashboardRevoke)
+}
+
+// dashboardEntry is a registry entry as the dashboard lists it.
+type dashboardEntry struct {
+	registry.Entry
+	Status string
+	Sinks  []string // delivery sinks, sorted
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	status := r.URL.Query().Get("status")
+
+	now := time.Now()
+	var found []dashboardEntry
+	for _, e := range iss.Registry.All() {
+		de := dashboardEntry{Entry: e, Status: filterValid, Sinks: slices.Sorted(maps.Keys(e.Deliveries))}
+		switch {
+		case e.Revoked():
+			de.Status = filterRevoked
+		case e.Expired(now):
+			de.Status = filterExpired
+		}
+		if q != "" && !containsFold(e.RegNumber, q) && !containsFold(e.Name, q) && !containsFold(e.Course, q) {
+			continue
+		}
+		if status == filterUnsent {
+			if !undelivered(e) {
+				continue
+			}
+		} else if status != "" && status != de.Status {
+			continue
+		}
+		found = append(found, de)
+	}
+	slices.SortStableFunc(found, func(a, b dashboardEntry) int { return b.IssuedAt.Compare(a.IssuedAt) })
+
+	runs, err := batch.ReadHistory(batch.HistoryPath(iss.OutputDir), dashboardRuns)
+	data := map[string]any{
+		"Query":    q,
+		"Status":   status,
+		"Statuses": []string{filterValid, filterRevoked, filterExpired, filterUnsent},
+		"Found":    len(found),
+		"Entries":  found[:min(len(found), dashboardResults)],
+		"Sinks":    sinkNames(iss),
+		"Runs":     runs,
+		"Message":  r.URL.Query().Get("msg"),
+		"Tenant":   r.URL.Query().Get("tenant"),
+	}
+	if err != nil {
+		data["HistoryError"] = err.Error()
+	}
+	if _, tenants := s.current(); len(tenants) > 0 {
+		if k, ok := auth.FromContext(r.Context()); !ok || k.Tenant == "" {
+			data["Tenants"] = slices.Sorted(maps.Keys(tenants))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTmpl.Execute(w, data); err != nil {
+		s.logger.Error("rendering dashboard", "err", err)
+	}
+}
+
+func (s *Server) handleDashboardPDF(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	e, ok := iss.Registry.Get(r.PathValue("reg"))
+	if !ok {
+		http.Error(w, registry.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", disposition+`; filename="`+filepath.Base(e.Path)+`"`)
+	http.ServeFile(w, r, e.Path)
+}
+
+func (s *Server) handleDashboardResend(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	reg := r.PathValue("reg")
+	sink := r.FormValue("sink")
+	d, err := iss.Resend(r.Context(), reg, sink, strings.TrimSpace(r.FormValue("to")))
+	if err != nil {
+		s.logger.Error("resend failed", "reg_number", reg, "sink", sink, "err", err)
+		redirectDashboard(w, r, reg, "Resending "+reg+" failed: "+err.Error())
+		return
+	}
+	k, _ := auth.FromContext(r.Context())
+	s.logger.Info("certificate resent", "reg_number", reg, "sink", sink, "to", d.To, "key", k.ID)
+	msg := "Sent " + reg + " through " + sink
+	if d.To != "" {
+		msg += " to " + d.To
+	}
+	redirectDashboard(w, r, reg, msg)
+}
+
+func (s *Server) handleDashboardRevoke(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	reg := r.PathValue("reg")
+	e, err := iss.Registry.Revoke(reg, strings.TrimSpace(r.FormValue("reason")), time.Now())
+	if err != nil {
+		redirectDashboard(w, r, reg, "Revoking "+reg+" failed: "+err.Error())
+		return
+	}
+	k, _ := auth.FromContext(r.Context())
+	s.logger.Info("certificate revoked", "reg_number", e.RegNumber, "reason", e.RevokeReason, "key", k.ID)
+	redirectDashboard(w, r, reg, "Revoked "+reg)
+}
+
+// redirectDashboard sends the browser back to the dashboard, showing the
+// certificate reg and msg.
+func redirectDashboard(w http.ResponseWriter, r *http.Request, reg, msg string) {
+	v := url.Values{"q": {reg}, "msg": {msg}}
+	if t := r.URL.Query().Get("tenant"); t != "" {
+		v.Set("tenant", t)
+	}
+	http.Redirect(w, r, "/admin/?"+v.Encode(), http.StatusSeeOther)
+}
+
+// sinkNames lists the delivery sinks iss can resend through.
+func sinkNames(iss *issuer.Issuer) []string {
+	var names []string
+	for _, s := range iss.Sinks {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func undelivered(e registry.Entry) bool {
+	for _, d := range e.Deliveries {
+		if d.Status == registry.DeliveryFailed || d.Status == registry.DeliveryBounced {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// handleHealth is the liveness probe: the process is up and serving.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady is the readiness probe. It checks everything issuing depends
+// on, for the default issuer and every tenant, so traffic is not routed to
+// an instance whose template, fonts, registry or output directory have gone
+// missing.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	type check struct {
+		name  string
+		check func() error
+	}
+	checksFor := func(prefix string, iss *issuer.Issuer) []check {
+		return []check{
+			{prefix + "generator", iss.Gen.Check},
+			{prefix + "registry", iss.Registry.Check},
+			{prefix + "output", iss.CheckOutputDir},
+		}
+	}
+	iss, tenants := s.current()
+	checks := checksFor("", iss)
+	for _, name := range slices.Sorted(maps.Keys(tenants)) {
+		checks = append(checks, checksFor(name+"/", tenants[name])...)
+	}
+
+	status, code := "ok", http.StatusOK
+	results := make(map[string]string, len(checks))
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			s.logger.Warn("readiness check failed", "check", c.name, "err", err)
+			results[c.name] = err.Error()
+			status, code = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		results[c.name] = "ok"
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": results})
+}
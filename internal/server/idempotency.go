@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long a response is replayed for its
+// Idempotency-Key.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// WithIdempotencyTTL sets how long responses are kept for replay.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(s *Server) { s.idem.ttl = ttl }
+}
+
+// idempotency remembers the responses to issue requests that carried an
+// Idempotency-Key header, so a client retrying after a timeout gets the
+// original certificate back rather than a second one.
+type idempotency struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idemEntry
+}
+
+type idemEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{} // closed once the response is recorded
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// begin returns the entry for key and whether the caller owns it and must
+// call finish. Expired entries are dropped along the way.
+func (s *idempotency) begin(key string, fp [sha256.Size]byte) (e *idemEntry, owner bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	if e, ok := s.entries[key]; ok {
+		return e, false
+	}
+	if s.entries == nil {
+		s.entries = map[string]*idemEntry{}
+	}
+	e = &idemEntry{fingerprint: fp, done: make(chan struct{})}
+	s.entries[key] = e
+	return e, true
+}
+
+// finish records the response. Only successes are kept: after a failure the
+// key is released so the client's retry can try again.
+func (s *idempotency) finish(key string, e *idemEntry, rec *recorder) {
+	s.mu.Lock()
+	if rec.status >= 200 && rec.status < 300 {
+		e.status, e.header, e.body = rec.status, rec.Header(), rec.body.Bytes()
+		ttl := s.ttl
+		if ttl == 0 {
+			ttl = DefaultIdempotencyTTL
+		}
+		e.expires = time.Now().Add(ttl)
+	} else {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	close(e.done)
+}
+
+// idempotent runs handle at most once per key and request body. A repeat
+// with the same body waits for and replays the first response; a repeat
+// with a different body is rejected with 422.
+func (s *Server) idempotent(w http.ResponseWriter, r *http.Request, key string, req any, handle func(http.ResponseWriter)) {
+	b, _ := json.Marshal(req)
+	fp := sha256.Sum256(b)
+
+	for {
+		e, owner := s.idem.begin(key, fp)
+		if owner {
+			rec := &recorder{header: http.Header{}}
+			handle(rec)
+			s.idem.finish(key, e, rec)
+			rec.copyTo(w)
+			return
+		}
+
+		select {
+		case <-e.done:
+		case <-r.Context().Done():
+			return
+		}
+		if e.fingerprint != fp {
+			writeError(w, http.StatusUnprocessableEntity, errors.New("Idempotency-Key was already used with a different request"))
+			return
+		}
+		if e.status == 0 {
+			continue // the first attempt failed and released the key
+		}
+		for k, v := range e.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(e.status)
+		w.Write(e.body)
+		return
+	}
+}
+
+// recorder buffers a response so it can be stored and replayed.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+func (r *recorder) copyTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
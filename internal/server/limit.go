@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/auth"
+)
+
+// Limits bounds the load a Server accepts. Zero values disable a limit.
+type Limits struct {
+	Rate     float64 // requests per second across all clients
+	Burst    int
+	KeyRate  float64 // requests per second for each API key
+	KeyBurst int
+	// MaxConcurrent caps simultaneous PDF generations; further issue
+	// requests wait for a slot until the client gives up.
+	MaxConcurrent int
+}
+
+// WithLimits applies rate limits and a generation concurrency cap.
+func WithLimits(l Limits) Option {
+	return func(s *Server) {
+		s.limits = l
+		if l.Rate > 0 {
+			s.global = rate.NewLimiter(rate.Limit(l.Rate), max(l.Burst, 1))
+		}
+		if l.MaxConcurrent > 0 {
+			s.slots = make(chan struct{}, l.MaxConcurrent)
+		}
+	}
+}
+
+// limiter holds the rate-limiting state of a Server.
+type limiter struct {
+	limits Limits
+	global *rate.Limiter
+	slots  chan struct{}
+
+	mu     sync.Mutex
+	perKey map[string]*rate.Limiter
+}
+
+// limited wraps next with the global and per-key rate limits. It runs after
+// authentication so the key is known.
+func (s *Server) limited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l := s.global; l != nil {
+			if !allow(w, l) {
+				return
+			}
+		}
+		if k, ok := auth.FromContext(r.Context()); ok && s.limits.KeyRate > 0 {
+			if !allow(w, s.keyLimiter(k.ID)) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) keyLimiter(id string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.perKey == nil {
+		s.perKey = map[string]*rate.Limiter{}
+	}
+	l, ok := s.perKey[id]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(s.limits.KeyRate), max(s.limits.KeyBurst, 1))
+		s.perKey[id] = l
+	}
+	return l
+}
+
+// allow takes a token from l or answers 429 with a Retry-After hint.
+func allow(w http.ResponseWriter, l *rate.Limiter) bool {
+	res := l.Reserve()
+	if d := res.Delay(); d > 0 {
+		res.Cancel()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(d, time.Second).Seconds()))))
+		writeError(w, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+		return false
+	}
+	return true
+}
+
+// acquire waits for a generation slot. It returns false, having answered
+// the request, if the client went away first.
+func (s *Server) acquire(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	if s.slots == nil {
+		return func() {}, true
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, true
+	case <-r.Context().Done():
+		writeError(w, http.StatusServiceUnavailable, errors.New("timed out waiting for a generation slot"))
+		return nil, false
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+)
+
+// Reloader builds the issuers to serve from the configuration as it is
+// now: the default one and one per tenant, keyed like WithTenants. It is
+// how Reload picks up an edited config or tenant file.
+type Reloader func() (iss *issuer.Issuer, tenants map[string]*issuer.Issuer, err error)
+
+// errNoReloader is answered with 501.
+var errNoReloader = errors.New("configuration reload is not enabled")
+
+// WithReloader enables Reload, and POST /admin/reload, with r.
+func WithReloader(r Reloader) Option {
+	return func(s *Server) { s.reloader = r }
+}
+
+// current returns the issuers being served.
+func (s *Server) current() (*issuer.Issuer, map[string]*issuer.Issuer) {
+	s.issuersMu.RLock()
+	defer s.issuersMu.RUnlock()
+	return s.issuer, s.tenants
+}
+
+// Reload replaces the issuers with those the Reloader builds. Requests in
+// flight finish with the issuers they started with; if the new
+// configuration is invalid the old one stays in service and the error is
+// returned.
+func (s *Server) Reload() error {
+	if s.reloader == nil {
+		return errNoReloader
+	}
+	iss, tenants, err := s.reloader()
+	if err != nil {
+		return err
+	}
+	s.observe(iss, tenants)
+	s.issuersMu.Lock()
+	s.issuer, s.tenants = iss, tenants
+	s.issuersMu.Unlock()
+	s.logger.Info("configuration reloaded", "tenants", len(tenants))
+	return nil
+}
+
+// handleReload reloads the configuration on request, answering 422 with
+// the validation errors if it is invalid.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	err := s.Reload()
+	switch {
+	case errors.Is(err, errNoReloader):
+		writeError(w, http.StatusNotImplemented, err)
+	case err != nil:
+		s.logger.Error("configuration reload failed, keeping the current one", "err", err)
+		writeError(w, http.StatusUnprocessableEntity, err)
+	default:
+		_, tenants := s.current()
+		writeJSON(w, http.StatusOK, map[string]any{"status": "reloaded", "tenants": len(tenants)})
+	}
+}
@@ -0,0 +1,383 @@
+// Package server exposes certificate issuance and verification over HTTP.
+//
+//	POST /certificates               issue a certificate (JSON body)      generate
+//	                                 with Idempotency-Key, at most once
+//	GET  /certificates/{reg}         registry entry                       verify
+//	GET  /certificates/{reg}/pdf     the issued PDF                       verify
+//	POST /certificates/{reg}/revoke  revoke, optional {"reason": ...}     revoke
+//	GET  /verify/{reg}               verification status                  verify
+//	POST /webhooks/email             provider bounce/delivery reports     webhook
+//	POST /admin/reload               reload configuration, with           admin
+//	                                 WithReloader
+//	GET  /healthz                    liveness
+//	GET  /readyz                     readiness of template, fonts, registry, output
+//	GET  /metrics                    Prometheus metrics, with WithMetrics admin
+//	GET  /admin/                     dashboard for operations staff, in   admin
+//	                                 a browser; see dashboardRoutes
+//
+// With WithAuth, each route requires an API key with the scope in the last
+// column; the health probes are always open.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/auth"
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/delivery"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/metrics"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Sathimantha/certificate_generator_go/internal/server")
+
+// maxBody bounds request bodies; issuance requests are a few hundred bytes.
+const maxBody = 1 << 20
+
+// Server serves the HTTP API for a default issuer and any tenants.
+type Server struct {
+	issuersMu sync.RWMutex // guards issuer and tenants, which Reload replaces
+	issuer    *issuer.Issuer
+	tenants   map[string]*issuer.Issuer
+	reloader  Reloader
+
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	keys    *auth.Keyring
+	idem    idempotency
+	limiter
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMetrics records issuance metrics in m, by setting the issuer's
+// OnResult, and serves them on /metrics.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) { s.metrics = m }
+}
+
+// WithAuth requires API keys from keys on every route but the health
+// probes.
+func WithAuth(keys *auth.Keyring) Option {
+	return func(s *Server) { s.keys = keys }
+}
+
+// WithTenants serves each tenant's issuer alongside the default one. Every
+// issuer must have its own registry and output directory.
+func WithTenants(tenants map[string]*issuer.Issuer) Option {
+	return func(s *Server) { s.tenants = tenants }
+}
+
+// New returns a Server issuing through iss, which must have a registry.
+func New(iss *issuer.Issuer, logger *slog.Logger, opts ...Option) *Server {
+	s := &Server{issuer: iss, logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.observe(s.issuer, s.tenants)
+	return s
+}
+
+// observe records the results of iss and tenants in the server's metrics.
+func (s *Server) observe(iss *issuer.Issuer, tenants map[string]*issuer.Issuer) {
+	if s.metrics == nil {
+		return
+	}
+	iss.OnResult = s.metrics.Observe
+	for _, t := range tenants {
+		t.OnResult = s.metrics.Observe
+	}
+}
+
+// errUnknownTenant is answered with 404.
+var errUnknownTenant = errors.New("unknown tenant")
+
+// issuerFor picks the issuer for a request. A key bound to a tenant always
+// uses it and may not name another; other keys choose with the requested
+// tenant (the "tenant" body field, X-Tenant header or ?tenant=), or get the
+// default issuer. tenant is the name of the tenant picked, empty for the
+// default.
+func (s *Server) issuerFor(w http.ResponseWriter, r *http.Request, requested string) (iss *issuer.Issuer, tenant string, ok bool) {
+	if requested == "" {
+		requested = r.Header.Get("X-Tenant")
+	}
+	if requested == "" {
+		requested = r.URL.Query().Get("tenant")
+	}
+
+	def, tenants := s.current()
+	name := requested
+	if k, ok := auth.FromContext(r.Context()); ok && k.Tenant != "" {
+		if requested != "" && requested != k.Tenant {
+			writeError(w, http.StatusForbidden, fmt.Errorf("API key %q cannot act for tenant %q", k.ID, requested))
+			return nil, "", false
+		}
+		name = k.Tenant
+	}
+	if name == "" {
+		return def, "", true
+	}
+	iss, ok = tenants[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w %q", errUnknownTenant, name))
+		return nil, "", false
+	}
+	return iss, name, true
+}
+
+// Handler returns the API routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	route := func(pattern string, scope auth.Scope, h http.HandlerFunc) {
+		mux.Handle(pattern, s.keys.Require(scope, s.limited(h)))
+	}
+	route("POST /certificates", auth.ScopeGenerate, s.handleIssue)
+	route("GET /certificates/{reg}", auth.ScopeVerify, s.handleGet)
+	route("GET /certificates/{reg}/pdf", auth.ScopeVerify, s.handlePDF)
+	route("POST /certificates/{reg}/revoke", auth.ScopeRevoke, s.handleRevoke)
+	route("GET /verify/{reg}", auth.ScopeVerify, s.handleVerify)
+	route("POST /webhooks/email", auth.ScopeWebhook, s.handleEmailEvents)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	route("POST /admin/reload", auth.ScopeAdmin, s.handleReload)
+	s.dashboardRoutes(mux)
+	if s.metrics != nil {
+		mux.Handle("GET /metrics", s.keys.Require(auth.ScopeAdmin, s.metrics.Handler()))
+	}
+	return traced(mux)
+}
+
+// traced starts a server span for every request, continuing the caller's
+// trace when the request carries W3C traceparent headers.
+func traced(mux *http.ServeMux) http.Handler {
+	prop := propagation.TraceContext{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Patterns are "METHOD /path"; unmatched requests have none.
+		_, pattern := mux.Handler(r)
+		_, route, _ := strings.Cut(pattern, " ")
+		ctx := prop.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, strings.TrimSpace(r.Method+" "+route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+			))
+		defer span.End()
+		mux.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// issueRequest is the body of POST /certificates.
+type issueRequest struct {
+	Name      string            `json:"name"`
+	RegNumber string            `json:"reg_number"`
+	Course    string            `json:"course,omitempty"`
+	IssuedAt  time.Time         `json:"issued_at,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+}
+
+func (s *Server) handleIssue(w http.ResponseWriter, r *http.Request) {
+	var req issueRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Name == "" || req.RegNumber == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name and reg_number are required"))
+		return
+	}
+	iss, tenant, ok := s.issuerFor(w, r, req.Tenant)
+	if !ok {
+		return
+	}
+
+	// Keys are scoped to the API key and the tenant issued for, however it
+	// was named, so clients cannot collide.
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		k, _ := auth.FromContext(r.Context())
+		scope := k.ID + "\x00" + tenant + "\x00" + key
+		s.idempotent(w, r, scope, req, func(w http.ResponseWriter) { s.issue(w, r, iss, req) })
+		return
+	}
+	s.issue(w, r, iss, req)
+}
+
+func (s *Server) issue(w http.ResponseWriter, r *http.Request, iss *issuer.Issuer, req issueRequest) {
+	if s.metrics != nil {
+		defer s.metrics.Enqueue(1)()
+	}
+	release, ok := s.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	res := iss.IssueContext(r.Context(), certificate.Record{
+		Name:      req.Name,
+		RegNumber: req.RegNumber,
+		Course:    req.Course,
+		IssuedAt:  req.IssuedAt,
+		ExpiresAt: req.ExpiresAt,
+		Fields:    req.Fields,
+	})
+	if !res.OK() {
+		s.logger.Error("issue failed", "reg_number", req.RegNumber, "err", res.Error)
+		writeJSON(w, failureStatus(res.Err), res)
+		return
+	}
+	if res.Skipped {
+		writeJSON(w, http.StatusOK, res) // nothing new was created
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// failureStatus maps an issuance error to its HTTP status: a conflict for a
+// certificate that may not be replaced or was revoked, unprocessable for text the font
+// cannot print or a record a hook rejected, unavailable while the template or font is missing, and an
+// internal error otherwise.
+func failureStatus(err error) int {
+	switch {
+	case errors.Is(err, certificate.ErrOutputExists), errors.Is(err, registry.ErrRevoked):
+		return http.StatusConflict
+	case errors.Is(err, certificate.ErrMissingGlyph), errors.Is(err, certificate.ErrUnknownCourse), errors.Is(err, issuer.ErrRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, certificate.ErrTemplateNotFound), errors.Is(err, certificate.ErrFontLoad):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	e, ok := iss.Registry.Get(r.PathValue("reg"))
+	if !ok {
+		writeError(w, http.StatusNotFound, registry.ErrNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func (s *Server) handlePDF(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	e, ok := iss.Registry.Get(r.PathValue("reg"))
+	if !ok {
+		writeError(w, http.StatusNotFound, registry.ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	http.ServeFile(w, r, e.Path)
+}
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 && !decode(w, r, &req) {
+		return
+	}
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+
+	e, err := iss.Registry.Revoke(r.PathValue("reg"), req.Reason, time.Now())
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		k, _ := auth.FromContext(r.Context())
+		s.logger.Info("certificate revoked", "reg_number", e.RegNumber, "reason", e.RevokeReason, "key", k.ID)
+		writeJSON(w, http.StatusOK, e)
+	}
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	v := iss.Registry.Verify(r.PathValue("reg"), r.URL.Query().Get("sha256"))
+	status := http.StatusOK
+	if v.Status == registry.StatusUnknown {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, v)
+}
+
+// handleEmailEvents records the bounce, complaint and delivery reports an
+// email provider posts as the certificates' email delivery status.
+func (s *Server) handleEmailEvents(w http.ResponseWriter, r *http.Request) {
+	iss, _, ok := s.issuerFor(w, r, "")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	events, err := delivery.ParseEmailEvents(body)
+	switch {
+	case errors.Is(err, delivery.ErrSubscription):
+		s.logger.Warn("email webhook needs confirming", "err", err)
+		writeJSON(w, http.StatusOK, map[string]int{"updated": 0, "unmatched": 0})
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	updated, unmatched, err := delivery.ApplyEmailEvents(iss.Registry, events)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if unmatched > 0 {
+		s.logger.Warn("email events for unknown messages", "unmatched", unmatched)
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"updated": updated, "unmatched": unmatched})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/registry"
+)
+
+// TestIdempotencyKeyPerTenant sends one Idempotency-Key to two tenants
+// named by X-Tenant. Each must issue its own certificate rather than
+// replay the other's.
+func TestIdempotencyKeyPerTenant(t *testing.T) {
+	tenants := map[string]*issuer.Issuer{"a": newTestIssuer(t), "b": newTestIssuer(t)}
+	h := New(newTestIssuer(t), discard(), WithTenants(tenants)).Handler()
+
+	const body = `{"name":"Ada Lovelace","reg_number":"R-1"}`
+	for _, tenant := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodPost, "/certificates", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "k-1")
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("tenant %s: status %d, want %d: %s", tenant, rec.Code, http.StatusCreated, rec.Body)
+		}
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("tenant %s: response replayed", tenant)
+		}
+	}
+	for name, iss := range tenants {
+		if _, ok := iss.Registry.Get("R-1"); !ok {
+			t.Errorf("tenant %s did not register R-1", name)
+		}
+	}
+}
+
+// newTestIssuer returns an issuer for the default configuration with its
+// own registry and output directory.
+func newTestIssuer(t *testing.T) *issuer.Issuer {
+	t.Helper()
+	cfg, err := certificate.LoadConfig(certificate.MapSource(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := certificate.New(cfg, certificate.WithLogger(discard()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	reg, err := registry.Open(filepath.Join(dir, "registry.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reg.Close() })
+	return &issuer.Issuer{Gen: gen, Registry: reg, OutputDir: dir}
+}
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,128 @@
+// Package tenant loads per-organization configuration.
+//
+// Each tenant is a NAME.env file in the tenants directory. Its settings
+// overlay the deployment's configuration, so a tenant file only needs the
+// keys that differ: usually TEMPLATE_IMAGE, FONT_FAMILY,
+// VERIFICATION_BASE_URL and API_KEYS. API keys defined in a tenant file
+// can only issue for that tenant.
+//
+// Every tenant writes below its own output directory, OUTPUT_DIR/NAME
+// unless the tenant file sets OUTPUT_DIR, and keeps its own registry there.
+// Load rejects configurations in which two tenants would share either.
+package tenant
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+)
+
+// validName keeps tenant names usable as directory names and URL values.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Tenant is one organization's configuration overlay.
+type Tenant struct {
+	Name   string
+	Values map[string]string // settings from the tenant file
+}
+
+// Source layers the tenant's settings over base.
+func (t Tenant) Source(base certificate.Source) certificate.Source {
+	return certificate.Layered(certificate.MapSource(t.Values), base)
+}
+
+// OutputDir returns the tenant's output directory: its own OUTPUT_DIR, or
+// a subdirectory of the deployment's.
+func (t Tenant) OutputDir(baseOutputDir string) string {
+	if dir := t.Values["OUTPUT_DIR"]; dir != "" {
+		return dir
+	}
+	return filepath.Join(baseOutputDir, t.Name)
+}
+
+// RegistryPath returns the tenant's registry file. A REGISTRY_PATH set for
+// the whole deployment is deliberately not inherited.
+func (t Tenant) RegistryPath(baseOutputDir string) string {
+	if p := t.Values["REGISTRY_PATH"]; p != "" {
+		return p
+	}
+	return filepath.Join(t.OutputDir(baseOutputDir), "registry.jsonl")
+}
+
+// Load reads every *.env file in dir, sorted by name, and checks that no
+// two tenants share an output directory or registry with each other or
+// with the deployment's own.
+func Load(dir, baseOutputDir, baseRegistry string) ([]Tenant, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.env"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("tenants directory: %w", err)
+		}
+	}
+	slices.Sort(paths)
+
+	used := map[string]string{
+		clean(baseOutputDir): "the default output directory",
+		clean(baseRegistry):  "the default registry",
+	}
+	claim := func(path, what string) error {
+		if prev, ok := used[clean(path)]; ok {
+			return fmt.Errorf("%s %s is already %s", what, path, prev)
+		}
+		used[clean(path)] = what
+		return nil
+	}
+
+	var tenants []Tenant
+	for _, path := range paths {
+		t, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := claim(t.OutputDir(baseOutputDir), "tenant "+t.Name+" output directory"); err != nil {
+			return nil, err
+		}
+		if err := claim(t.RegistryPath(baseOutputDir), "tenant "+t.Name+" registry"); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// Find loads the tenant called name from dir.
+func Find(dir, name string) (Tenant, error) {
+	if !validName.MatchString(name) {
+		return Tenant{}, fmt.Errorf("invalid tenant name %q", name)
+	}
+	return loadFile(filepath.Join(dir, name+".env"))
+}
+
+func loadFile(path string) (Tenant, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".env")
+	if !validName.MatchString(name) {
+		return Tenant{}, fmt.Errorf("%s: tenant names are lower-case letters, digits, - and _", path)
+	}
+	vals, err := godotenv.Read(path)
+	if err != nil {
+		return Tenant{}, fmt.Errorf("tenant %s: %w", name, err)
+	}
+	return Tenant{Name: name, Values: vals}, nil
+}
+
+func clean(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,293 @@
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"time"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// Apple writes a signed Apple Wallet pass next to each certificate, with
+// the same name and a .pkpass extension. The pass shows the recipient,
+// registration number, course and expiry, and the certificate's QR code.
+type Apple struct {
+	cfg   AppleConfig
+	cert  *x509.Certificate
+	key   crypto.Signer
+	chain []*x509.Certificate
+	icons map[string][]byte // icon.png, icon@2x.png, icon@3x.png
+}
+
+// AppleConfig configures an Apple sink. The certificate and key are the
+// Pass Type ID certificate exported from the Apple Developer portal, in
+// PEM; WWDR is Apple's intermediate it was issued by.
+type AppleConfig struct {
+	PassTypeID   string // e.g. pass.org.example.certificate
+	TeamID       string
+	Organization string // shown on the lock screen and in Wallet
+	CertFile     string
+	KeyFile      string // unencrypted PKCS #1, PKCS #8 or EC key
+	WWDRFile     string
+	IconFile     string // PNG; a plain seal in the pass colors if empty
+
+	Foreground color.RGBA
+	Background color.RGBA
+	Label      color.RGBA
+}
+
+// NewApple returns an Apple sink for cfg, reading its certificates and key.
+func NewApple(cfg AppleConfig) (*Apple, error) {
+	var errs []error
+	if cfg.PassTypeID == "" {
+		errs = append(errs, errors.New("APPLE_PASS_TYPE_ID is required"))
+	}
+	if cfg.TeamID == "" {
+		errs = append(errs, errors.New("APPLE_TEAM_ID is required"))
+	}
+	a := &Apple{cfg: cfg}
+	var err error
+	if a.cert, err = readCert("APPLE_PASS_CERT", cfg.CertFile); err != nil {
+		errs = append(errs, err)
+	}
+	if a.key, err = readKey("APPLE_PASS_KEY", cfg.KeyFile); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.WWDRFile != "" {
+		wwdr, err := readCert("APPLE_WWDR_CERT", cfg.WWDRFile)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			a.chain = append(a.chain, wwdr)
+		}
+	}
+	if a.icons, err = icons(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Name implements issuer.Sink.
+func (a *Apple) Name() string { return "pkpass" }
+
+// Deliver implements issuer.Sink. It returns the path of the pass.
+func (a *Apple) Deliver(ctx context.Context, rec certificate.Record, res issuer.Result) (string, error) {
+	pass, err := a.Pass(rec, res)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	path := companionPath(res.Path, ".pkpass")
+	if err := writeFile(path, pass); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// passField is a field of pass.json.
+type passField struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"`
+	Value string `json:"value"`
+}
+
+type passBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+// Pass returns the signed .pkpass archive for rec's certificate.
+func (a *Apple) Pass(rec certificate.Record, res issuer.Result) ([]byte, error) {
+	secondary := []passField{{Key: "reg", Label: "REGISTRATION", Value: res.RegNumber}}
+	var aux []passField
+	if rec.Course != "" {
+		aux = append(aux, passField{Key: "course", Label: "COURSE", Value: rec.Course})
+	}
+	pass := map[string]any{
+		"formatVersion":      1,
+		"passTypeIdentifier": a.cfg.PassTypeID,
+		"teamIdentifier":     a.cfg.TeamID,
+		"serialNumber":       res.RegNumber,
+		"organizationName":   a.cfg.Organization,
+		"description":        "Certificate " + res.RegNumber,
+		"logoText":           a.cfg.Organization,
+		"foregroundColor":    cssColor(a.cfg.Foreground),
+		"backgroundColor":    cssColor(a.cfg.Background),
+		"labelColor":         cssColor(a.cfg.Label),
+		"barcodes": []passBarcode{{
+			Format:          "PKBarcodeFormatQR",
+			Message:         res.QRPayload,
+			MessageEncoding: "iso-8859-1",
+			AltText:         res.RegNumber,
+		}},
+	}
+	if res.ExpiresAt != nil {
+		pass["expirationDate"] = res.ExpiresAt.Format(time.RFC3339)
+		aux = append(aux, passField{Key: "expires", Label: "VALID UNTIL", Value: res.ExpiresAt.Format(time.DateOnly)})
+	}
+	pass["generic"] = map[string]any{
+		"primaryFields":   []passField{{Key: "name", Label: "RECIPIENT", Value: res.Name}},
+		"secondaryFields": secondary,
+		"auxiliaryFields": aux,
+		"backFields":      []passField{{Key: "verify", Label: "Verify this certificate", Value: res.VerifyURL}},
+	}
+	passJSON, err := json.Marshal(pass)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"pass.json": passJSON}
+	for name, b := range a.icons {
+		files[name] = b
+	}
+	manifest := map[string]string{}
+	for name, b := range files {
+		sum := sha1.Sum(b)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signDetached(manifestJSON, a.cert, a.key, a.chain, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("signing pass: %w", err)
+	}
+	files["manifest.json"] = manifestJSON
+	files["signature"] = sig
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"pass.json", "manifest.json", "signature", "icon.png", "icon@2x.png", "icon@3x.png"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
+}
+
+// icons returns the pass icons at 1×, 2× and 3×: IconFile for all of them,
+// which Wallet scales, or a drawn seal.
+func icons(cfg AppleConfig) (map[string][]byte, error) {
+	if cfg.IconFile != "" {
+		b, err := os.ReadFile(cfg.IconFile)
+		if err != nil {
+			return nil, fmt.Errorf("APPLE_PASS_ICON: %w", err)
+		}
+		if _, err := png.DecodeConfig(bytes.NewReader(b)); err != nil {
+			return nil, fmt.Errorf("APPLE_PASS_ICON: not a PNG: %w", err)
+		}
+		return map[string][]byte{"icon.png": b, "icon@2x.png": b, "icon@3x.png": b}, nil
+	}
+	out := map[string][]byte{}
+	for scale, name := range map[int]string{1: "icon.png", 2: "icon@2x.png", 3: "icon@3x.png"} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, seal(29*scale, cfg.Background, cfg.Foreground)); err != nil {
+			return nil, err
+		}
+		out[name] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// seal draws a ring in fg on bg, the default pass icon.
+func seal(size int, bg, fg color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	c, outer, inner := float64(size)/2, float64(size)*0.42, float64(size)*0.3
+	for y := range size {
+		for x := range size {
+			d := math.Hypot(float64(x)+0.5-c, float64(y)+0.5-c)
+			if d <= outer && d >= inner {
+				img.SetRGBA(x, y, fg)
+			} else {
+				img.SetRGBA(x, y, bg)
+			}
+		}
+	}
+	return img
+}
+
+func readCert(key, path string) (*x509.Certificate, error) {
+	block, err := readPEM(key, path)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return cert, nil
+}
+
+func readKey(key, path string) (crypto.Signer, error) {
+	block, err := readPEM(key, path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKey(key, block)
+}
+
+func parseKey(key string, block *pem.Block) (crypto.Signer, error) {
+	var k any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", key, k)
+	}
+	return signer, nil
+}
+
+func readPEM(key, path string) (*pem.Block, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s is required", key)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %s is not PEM", key, path)
+	}
+	return block, nil
+}
@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+
+	"github.com/Sathimantha/certificate_generator_go/internal/certificate"
+	"github.com/Sathimantha/certificate_generator_go/internal/issuer"
+	"github.com/Sathimantha/certificate_generator_go/internal/retry"
+)
+
+// googleSaveURL prefixes the signed JWT in an "Add to Google Wallet" link.
+const googleSaveURL = "https://pay.google.com/gp/v/save/"
+
+// Google creates an "Add to Google Wallet" link for each certificate: a
+// Generic pass with the same fields and QR code as the Apple pass, signed
+// into the link as a JWT so nothing is sent to Google until the recipient
+// opens it. The link is the delivery location, and email templates can
+// include it.
+type Google struct {
+	cfg   GoogleConfig
+	email string // service account that signs the links
+	key   *rsa.PrivateKey
+}
+
+// GoogleConfig configures a Google sink. The issuer ID and service account
+// come from the Google Wallet console, where the account must be allowed
+// to issue passes.
+type GoogleConfig struct {
+	IssuerID        string
+	ClassID         string // suffix of the pass class ID; created from the first link if it does not exist
+	CredentialsFile string // service-account JSON key file
+	Organization    string // card title
+	LogoURL         string // public PNG shown on the card; optional
+	Background      color.RGBA
+	Origins         []string // sites allowed to show the Save button, for links embedded in a page
+}
+
+// NewGoogle returns a Google sink for cfg, reading its service account key.
+func NewGoogle(cfg GoogleConfig) (*Google, error) {
+	var errs []error
+	if cfg.IssuerID == "" {
+		errs = append(errs, errors.New("GOOGLE_WALLET_ISSUER_ID is required"))
+	}
+	if !reWalletID.MatchString(cfg.ClassID) {
+		errs = append(errs, fmt.Errorf("GOOGLE_WALLET_CLASS_ID: %q may only contain letters, digits, '.', '_' and '-'", cfg.ClassID))
+	}
+	g := &Google{cfg: cfg}
+	if err := g.readCredentials(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Google) readCredentials() error {
+	if g.cfg.CredentialsFile == "" {
+		return errors.New("GOOGLE_WALLET_CREDENTIALS is required")
+	}
+	b, err := os.ReadFile(g.cfg.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("GOOGLE_WALLET_CREDENTIALS: %w", err)
+	}
+	jwt, err := google.JWTConfigFromJSON(b)
+	if err != nil {
+		return fmt.Errorf("GOOGLE_WALLET_CREDENTIALS %s: %w", g.cfg.CredentialsFile, err)
+	}
+	block, _ := pem.Decode(jwt.PrivateKey)
+	if block == nil {
+		return fmt.Errorf("GOOGLE_WALLET_CREDENTIALS %s: private_key is not PEM", g.cfg.CredentialsFile)
+	}
+	key, err := parseKey("GOOGLE_WALLET_CREDENTIALS", block)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("GOOGLE_WALLET_CREDENTIALS: want an RSA service account key, got %T", key)
+	}
+	g.email, g.key = jwt.Email, rsaKey
+	return nil
+}
+
+// Name implements issuer.Sink.
+func (g *Google) Name() string { return "googlewallet" }
+
+// Deliver implements issuer.Sink. It returns the save link.
+func (g *Google) Deliver(ctx context.Context, rec certificate.Record, res issuer.Result) (string, error) {
+	link, err := g.SaveLink(rec, res, time.Now())
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	return link, nil
+}
+
+// reWalletID matches what Google Wallet accepts in class and object IDs
+// after the issuer ID.
+var reWalletID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type localized struct {
+	DefaultValue struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	} `json:"defaultValue"`
+}
+
+func text(s string) localized {
+	var l localized
+	l.DefaultValue.Language, l.DefaultValue.Value = "en", s
+	return l
+}
+
+type textModule struct {
+	ID     string `json:"id"`
+	Header string `json:"header"`
+	Body   string `json:"body"`
+}
+
+// SaveLink returns the "Add to Google Wallet" link for rec's certificate,
+// signed at now.
+func (g *Google) SaveLink(rec certificate.Record, res issuer.Result, now time.Time) (string, error) {
+	classID := g.cfg.IssuerID + "." + g.cfg.ClassID
+	// Registration numbers may hold characters object IDs cannot
+	objectID := g.cfg.IssuerID + "." + strings.Map(func(r rune) rune {
+		if reWalletID.MatchString(string(r)) {
+			return r
+		}
+		return '_'
+	}, res.RegNumber)
+
+	modules := []textModule{{ID: "reg", Header: "Registration", Body: res.RegNumber}}
+	if rec.Course != "" {
+		modules = append(modules, textModule{ID: "course", Header: "Course", Body: rec.Course})
+	}
+	object := map[string]any{
+		"id":                 objectID,
+		"classId":            classID,
+		"state":              "ACTIVE",
+		"cardTitle":          text(g.cfg.Organization),
+		"subheader":          text("Recipient"),
+		"header":             text(res.Name),
+		"hexBackgroundColor": fmt.Sprintf("#%02x%02x%02x", g.cfg.Background.R, g.cfg.Background.G, g.cfg.Background.B),
+		"textModulesData":    modules,
+		"barcode": map[string]string{
+			"type":          "QR_CODE",
+			"value":         res.QRPayload,
+			"alternateText": res.RegNumber,
+		},
+		"linksModuleData": map[string]any{
+			"uris": []map[string]string{{"uri": res.VerifyURL, "description": "Verify this certificate"}},
+		},
+	}
+	if g.cfg.LogoURL != "" {
+		object["logo"] = map[string]any{"sourceUri": map[string]string{"uri": g.cfg.LogoURL}}
+	}
+	if res.ExpiresAt != nil {
+		object["validTimeInterval"] = map[string]any{"end": map[string]string{"date": res.ExpiresAt.UTC().Format(time.RFC3339)}}
+	}
+
+	origins := g.cfg.Origins
+	if origins == nil {
+		origins = []string{}
+	}
+	jwt, err := signJWT(map[string]any{
+		"iss":     g.email,
+		"aud":     "google",
+		"typ":     "savetowallet",
+		"iat":     now.Unix(),
+		"origins": origins,
+		"payload": map[string]any{
+			"genericClasses": []map[string]string{{"id": classID}},
+			"genericObjects": []any{object},
+		},
+	}, g.key)
+	if err != nil {
+		return "", err
+	}
+	return googleSaveURL + jwt, nil
+}
+
+// signJWT encodes claims as an RS256 JSON Web Token.
+func signJWT(claims any, key *rsa.PrivateKey) (string, error) {
+	enc := base64.RawURLEncoding
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(payload)
+	h := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", fmt.Errorf("signing save link: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
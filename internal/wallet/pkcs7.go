@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// The subset of PKCS #7 (RFC 2315) Wallet needs: a detached SignedData
+// over the pass manifest with one signer, its certificate chain and the
+// signing time.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// signDetached returns the DER PKCS #7 signature of content by key, whose
+// certificate is cert, carrying chain for verifiers.
+func signDetached(content []byte, cert *x509.Certificate, key crypto.Signer, chain []*x509.Certificate, at time.Time) ([]byte, error) {
+	digest := sha256.Sum256(content)
+	attrs, err := attributes(
+		attrValue{oidContentType, oidData},
+		attrValue{oidSigningTime, at.UTC()},
+		attrValue{oidMessageDigest, digest[:]},
+	)
+	if err != nil {
+		return nil, err
+	}
+	// The signature covers the attributes as a SET; they are stored with
+	// the implicit [0] tag instead
+	set, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(set)
+
+	var sigAlg pkix.AlgorithmIdentifier
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue}
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:])
+	case *ecdsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSASHA256}
+		sig, err = ecdsa.SignASN1(rand.Reader, k, h[:])
+	default:
+		return nil, fmt.Errorf("unsupported signing key %T", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{cert}, chain...) {
+		certs = append(certs, c.Raw...)
+	}
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber},
+			DigestAlgorithm:           sha256Alg,
+			AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			DigestEncryptionAlgorithm: sigAlg,
+			EncryptedDigest:           sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+type attrValue struct {
+	oid   asn1.ObjectIdentifier
+	value any
+}
+
+// attributes encodes the contents of a DER SET OF Attribute, whose members
+// are sorted by their encoding.
+func attributes(values ...attrValue) ([]byte, error) {
+	var enc [][]byte
+	for _, a := range values {
+		v, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		b, err := asn1.Marshal(attribute{Type: a.oid, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: v}})
+		if err != nil {
+			return nil, err
+		}
+		enc = append(enc, b)
+	}
+	slices.SortFunc(enc, bytes.Compare)
+	return bytes.Join(enc, nil), nil
+}
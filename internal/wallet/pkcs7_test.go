@@ -0,0 +1,155 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// TestSignDetached signs a manifest with an RSA and an ECDSA key and checks
+// the signature the way a verifier would: over the signed attributes
+// re-tagged as a SET, with messageDigest the SHA-256 of the manifest.
+func TestSignDetached(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte(`{"pass.json":"0123456789abcdef0123456789abcdef01234567"}`)
+	at := time.Date(2025, 1, 15, 9, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+
+	for name, key := range map[string]crypto.Signer{"rsa": rsaKey, "ecdsa": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			cert := newTestCert(t, key)
+			der, err := signDetached(manifest, cert, key, nil, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ci contentInfo
+			if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) > 0 {
+				t.Fatalf("content info: %v, %d trailing bytes", err, len(rest))
+			}
+			if !ci.ContentType.Equal(oidSignedData) {
+				t.Fatalf("content type %v, want signedData", ci.ContentType)
+			}
+			var sd signedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+				t.Fatal(err)
+			}
+			if len(sd.ContentInfo.Content.Bytes) != 0 {
+				t.Error("signature is not detached")
+			}
+			certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+			if err != nil || len(certs) != 1 || !certs[0].Equal(cert) {
+				t.Fatalf("certificates %v, %v, want the signer's", certs, err)
+			}
+			if len(sd.SignerInfos) != 1 {
+				t.Fatalf("%d signers, want 1", len(sd.SignerInfos))
+			}
+			si := sd.SignerInfos[0]
+			if si.IssuerAndSerialNumber.SerialNumber.Cmp(cert.SerialNumber) != 0 ||
+				!bytes.Equal(si.IssuerAndSerialNumber.Issuer.FullBytes, cert.RawIssuer) {
+				t.Error("signer does not name the certificate")
+			}
+
+			set, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: si.AuthenticatedAttributes.Bytes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := sha256.Sum256(set)
+			switch pub := cert.PublicKey.(type) {
+			case *rsa.PublicKey:
+				err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], si.EncryptedDigest)
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, h[:], si.EncryptedDigest) {
+					err = errors.New("invalid ECDSA signature")
+				}
+			}
+			if err != nil {
+				t.Fatalf("signature over the signed attributes: %v", err)
+			}
+
+			attrs := signedAttributes(t, si.AuthenticatedAttributes.Bytes)
+			var digest []byte
+			if _, err := asn1.Unmarshal(attrs[oidMessageDigest.String()], &digest); err != nil {
+				t.Fatal(err)
+			}
+			if want := sha256.Sum256(manifest); !bytes.Equal(digest, want[:]) {
+				t.Errorf("messageDigest %x, want %x", digest, want)
+			}
+			var contentType asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attrs[oidContentType.String()], &contentType); err != nil || !contentType.Equal(oidData) {
+				t.Errorf("contentType attribute %v, %v, want data", contentType, err)
+			}
+			var signed time.Time
+			if _, err := asn1.Unmarshal(attrs[oidSigningTime.String()], &signed); err != nil {
+				t.Fatal(err)
+			}
+			if !signed.Equal(at) {
+				t.Errorf("signingTime %v, want %v", signed, at)
+			}
+		})
+	}
+}
+
+func TestSignDetachedUnsupportedKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signDetached(nil, newTestCert(t, key), key, nil, time.Now()); err == nil {
+		t.Error("signing with an Ed25519 key succeeded")
+	}
+}
+
+// signedAttributes returns the single value of each attribute in attrs,
+// the contents of a SET OF Attribute, by type.
+func signedAttributes(t *testing.T, attrs []byte) map[string][]byte {
+	t.Helper()
+	values := map[string][]byte{}
+	for rest := attrs; len(rest) > 0; {
+		var a attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			t.Fatal(err)
+		}
+		values[a.Type.String()] = a.Values.Bytes
+	}
+	return values
+}
+
+// newTestCert returns a self-signed certificate for key.
+func newTestCert(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Pass Type ID: pass.example.certgen"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
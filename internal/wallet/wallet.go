@@ -0,0 +1,42 @@
+// Package wallet implements issuer sinks that turn certificates into
+// mobile wallet passes, so recipients can carry proof of certification on
+// their phone.
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// companionPath is the path of a file derived from the certificate at
+// pdfPath: the same name with ext instead of .pdf.
+func companionPath(pdfPath, ext string) string {
+	return strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + ext
+}
+
+// writeFile replaces path with data via a synced temp file in the same
+// directory, so a pass is never seen half written.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600; passes get regular file permissions, like the PDFs.
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}